@@ -1,5 +1,9 @@
 package mailosaur
 
+import (
+	"time"
+)
+
 type AnalysisService struct {
 	client *MailosaurClient
 }
@@ -67,11 +71,17 @@ type DeliverabilityReport struct {
 }
 
 func (s *AnalysisService) Spam(id string) (*SpamAnalysisResult, error) {
+	op := &Operation{Name: "analysis.spam", Target: id, Started: time.Now(), Polls: 1}
 	result, err := s.client.HttpGet(&SpamAnalysisResult{}, "api/analysis/spam/"+id)
+	op.Err = err
+	s.client.observe(op)
 	return result.(*SpamAnalysisResult), err
 }
 
 func (s *AnalysisService) Deliverability(id string) (*DeliverabilityReport, error) {
+	op := &Operation{Name: "analysis.deliverability", Target: id, Started: time.Now(), Polls: 1}
 	result, err := s.client.HttpGet(&DeliverabilityReport{}, "api/analysis/deliverability/"+id)
+	op.Err = err
+	s.client.observe(op)
 	return result.(*DeliverabilityReport), err
 }
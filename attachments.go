@@ -0,0 +1,257 @@
+package mailosaur
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type SaveAttachmentsOptions struct {
+	// MaxSize aborts any attachment larger than this many bytes. Zero means
+	// no limit.
+	MaxSize int64
+
+	// StrictContentType treats a mismatch between the declared and sniffed
+	// content type as a failure, rather than only recording it.
+	StrictContentType bool
+
+	// ManifestName is the file the manifest is written to within the
+	// directory, defaulting to manifest.json.
+	ManifestName string
+}
+
+type AttachmentManifest struct {
+	Message string                     `json:"message"`
+	Files   []*AttachmentManifestEntry `json:"files"`
+}
+
+type AttachmentManifestEntry struct {
+	Id                  string `json:"id"`
+	FileName            string `json:"fileName"`
+	Path                string `json:"path"`
+	ContentType         string `json:"contentType"`
+	DetectedContentType string `json:"detectedContentType"`
+	ContentTypeMatches  bool   `json:"contentTypeMatches"`
+	Length              int    `json:"length"`
+	Size                int64  `json:"size"`
+	Sha256              string `json:"sha256"`
+	Error               string `json:"error,omitempty"`
+}
+
+// SaveAttachments downloads every attachment of a message into dir, checks
+// each against its declared length and content type, and writes a manifest
+// of SHA-256 digests alongside them. The manifest is returned even when
+// some attachments fail verification, in which case an error describing the
+// failures is also returned.
+func (s *FilesService) SaveAttachments(message *Message, dir string, options *SaveAttachmentsOptions) (*AttachmentManifest, error) {
+	if options == nil {
+		options = &SaveAttachmentsOptions{}
+	}
+
+	manifestName := options.ManifestName
+	if len(manifestName) == 0 {
+		manifestName = "manifest.json"
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	manifest := &AttachmentManifest{Message: message.Id, Files: []*AttachmentManifestEntry{}}
+	used := map[string]bool{strings.ToLower(manifestName): true}
+	var failures []string
+
+	for _, a := range message.Attachments {
+		if a == nil {
+			continue
+		}
+
+		name := uniqueFileName(sanitizeFileName(a.FileName), used)
+		entry := &AttachmentManifestEntry{
+			Id:          a.Id,
+			FileName:    a.FileName,
+			Path:        name,
+			ContentType: a.ContentType,
+			Length:      a.Length,
+		}
+		manifest.Files = append(manifest.Files, entry)
+
+		if err := s.saveAttachment(a, filepath.Join(dir, name), entry, options); err != nil {
+			entry.Error = err.Error()
+			failures = append(failures, fmt.Sprintf("%s: %s", name, err))
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifestName), append(data, '\n'), 0644); err != nil {
+		return manifest, err
+	}
+
+	if len(failures) > 0 {
+		return manifest, &mailosaurError{
+			Message:   "Attachments failed verification: " + strings.Join(failures, "; "),
+			ErrorType: "attachment_verification",
+		}
+	}
+
+	return manifest, nil
+}
+
+func (s *FilesService) saveAttachment(a *Attachment, path string, entry *AttachmentManifestEntry, options *SaveAttachmentsOptions) error {
+	var body io.ReadCloser
+
+	// Attachments that have not been stored by Mailosaur yet carry their
+	// content inline
+	if len(a.Id) == 0 && len(a.Content) > 0 {
+		content, err := base64.StdEncoding.DecodeString(a.Content)
+		if err != nil {
+			return err
+		}
+		if options.MaxSize > 0 && int64(len(content)) > options.MaxSize {
+			return fileTooLargeError(options.MaxSize)
+		}
+		body = io.NopCloser(bytes.NewReader(content))
+	} else {
+		stream, err := s.StreamAttachment(a.Id, &FileDownloadOptions{MaxSize: options.MaxSize})
+		if err != nil {
+			return err
+		}
+		body = stream
+	}
+	defer body.Close()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	sniff := &sniffBuffer{}
+
+	written, err := io.Copy(io.MultiWriter(f, hash, sniff), body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	entry.Size = written
+	entry.Sha256 = hex.EncodeToString(hash.Sum(nil))
+	entry.DetectedContentType = http.DetectContentType(sniff.Bytes())
+	entry.ContentTypeMatches = contentTypesMatch(a.ContentType, entry.DetectedContentType)
+
+	if a.Length > 0 && int64(a.Length) != written {
+		return fmt.Errorf("expected %d bytes but downloaded %d", a.Length, written)
+	}
+
+	if options.StrictContentType && !entry.ContentTypeMatches {
+		return fmt.Errorf("declared content type %s but content looks like %s", a.ContentType, entry.DetectedContentType)
+	}
+
+	return nil
+}
+
+// sniffBuffer keeps the first 512 bytes written to it, which is all that
+// http.DetectContentType considers.
+type sniffBuffer struct {
+	bytes.Buffer
+}
+
+func (b *sniffBuffer) Write(p []byte) (int, error) {
+	if remaining := 512 - b.Len(); remaining > 0 {
+		if len(p) < remaining {
+			remaining = len(p)
+		}
+		b.Buffer.Write(p[:remaining])
+	}
+	return len(p), nil
+}
+
+func sanitizeFileName(name string) string {
+	// Treat both separators as path separators regardless of platform, then
+	// keep only the final element
+	name = strings.ReplaceAll(name, "\\", "/")
+	if idx := strings.LastIndex(name, "/"); idx >= 0 {
+		name = name[idx+1:]
+	}
+
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`<>:"|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	name = strings.TrimRight(name, ". ")
+
+	if len(name) == 0 {
+		return "attachment"
+	}
+
+	return name
+}
+
+func uniqueFileName(name string, used map[string]bool) string {
+	candidate := name
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	for i := 1; used[strings.ToLower(candidate)]; i++ {
+		candidate = base + "-" + strconv.Itoa(i) + ext
+	}
+
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+func contentTypesMatch(declared string, detected string) bool {
+	declaredType, _, err := mime.ParseMediaType(declared)
+	if err != nil {
+		declaredType = strings.ToLower(strings.TrimSpace(declared))
+	}
+	detectedType, _, _ := mime.ParseMediaType(detected)
+
+	switch {
+	case detectedType == "application/octet-stream":
+		// Sniffing was inconclusive
+		return true
+	case declaredType == detectedType:
+		return true
+	case declaredType == "image/jpg" && detectedType == "image/jpeg":
+		return true
+	case detectedType == "text/plain":
+		return strings.HasPrefix(declaredType, "text/") ||
+			strings.HasSuffix(declaredType, "+json") ||
+			strings.HasSuffix(declaredType, "+xml") ||
+			declaredType == "application/json" ||
+			declaredType == "application/xml" ||
+			declaredType == "application/javascript"
+	case detectedType == "text/xml":
+		return declaredType == "application/xml" || strings.HasSuffix(declaredType, "+xml")
+	case detectedType == "application/zip":
+		// Office documents and other container formats are zip files
+		return strings.Contains(declaredType, "zip") ||
+			strings.Contains(declaredType, "openxmlformats") ||
+			strings.Contains(declaredType, "opendocument") ||
+			declaredType == "application/java-archive"
+	}
+
+	return false
+}
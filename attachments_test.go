@@ -0,0 +1,124 @@
+package mailosaur
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeFileName(t *testing.T) {
+	assert.Equal(t, "passwd", sanitizeFileName("../../etc/passwd"))
+	assert.Equal(t, "evil.exe", sanitizeFileName("..\\..\\Windows\\evil.exe"))
+	assert.Equal(t, "report_1_.pdf", sanitizeFileName("report<1>.pdf"))
+	assert.Equal(t, "bashrc", sanitizeFileName(".bashrc"))
+	assert.Equal(t, "attachment", sanitizeFileName(".."))
+	assert.Equal(t, "attachment", sanitizeFileName(""))
+	assert.Equal(t, "a_b.txt", sanitizeFileName("a\x00b.txt"))
+}
+
+func TestUniqueFileName(t *testing.T) {
+	used := map[string]bool{}
+	assert.Equal(t, "cat.png", uniqueFileName("cat.png", used))
+	assert.Equal(t, "cat-1.png", uniqueFileName("cat.png", used))
+	assert.Equal(t, "CAT-2.png", uniqueFileName("CAT.png", used))
+}
+
+func TestContentTypesMatch(t *testing.T) {
+	assert.True(t, contentTypesMatch("image/png", "image/png"))
+	assert.True(t, contentTypesMatch("image/jpg", "image/jpeg"))
+	assert.True(t, contentTypesMatch("text/csv; charset=utf-8", "text/plain; charset=utf-8"))
+	assert.True(t, contentTypesMatch("application/vnd.openxmlformats-officedocument.wordprocessingml.document", "application/zip"))
+	assert.True(t, contentTypesMatch("application/x-custom", "application/octet-stream"))
+	assert.False(t, contentTypesMatch("application/pdf", "image/png"))
+	assert.False(t, contentTypesMatch("image/png", "text/html; charset=utf-8"))
+}
+
+func TestSaveAttachmentsInline(t *testing.T) {
+	skipUnlessLive(t)
+	dir := t.TempDir()
+	catImage, _ := os.ReadFile("testing/cat.png")
+	encoded := base64.StdEncoding.EncodeToString(catImage)
+
+	message := &Message{
+		Id: "msg",
+		Attachments: []*Attachment{
+			{FileName: "../cat.png", ContentType: "image/png", Content: encoded, Length: len(catImage)},
+			{FileName: "cat.png", ContentType: "application/pdf", Content: encoded},
+			{FileName: "manifest.json", ContentType: "image/png", Content: encoded, Length: 10},
+		},
+	}
+
+	manifest, err := client.Files.SaveAttachments(message, dir, nil)
+	assert.Error(t, err)
+	assert.Equal(t, "attachment_verification", err.(*mailosaurError).ErrorType)
+
+	assert.Equal(t, 3, len(manifest.Files))
+
+	sum := sha256.Sum256(catImage)
+	first := manifest.Files[0]
+	assert.Equal(t, "cat.png", first.Path)
+	assert.Equal(t, "../cat.png", first.FileName)
+	assert.Equal(t, int64(len(catImage)), first.Size)
+	assert.Equal(t, hex.EncodeToString(sum[:]), first.Sha256)
+	assert.Equal(t, "image/png", first.DetectedContentType)
+	assert.True(t, first.ContentTypeMatches)
+	assert.Empty(t, first.Error)
+
+	second := manifest.Files[1]
+	assert.Equal(t, "cat-1.png", second.Path)
+	assert.False(t, second.ContentTypeMatches)
+	assert.Empty(t, second.Error)
+
+	third := manifest.Files[2]
+	assert.Equal(t, "manifest-1.json", third.Path)
+	assert.Contains(t, third.Error, "expected 10 bytes")
+
+	saved, _ := os.ReadFile(filepath.Join(dir, "cat.png"))
+	assert.Equal(t, catImage, saved)
+
+	var written AttachmentManifest
+	data, _ := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	assert.NoError(t, json.Unmarshal(data, &written))
+	assert.Equal(t, "msg", written.Message)
+	assert.Equal(t, 3, len(written.Files))
+}
+
+func TestSaveAttachmentsStrictContentType(t *testing.T) {
+	skipUnlessLive(t)
+	catImage, _ := os.ReadFile("testing/cat.png")
+
+	message := &Message{
+		Attachments: []*Attachment{
+			{FileName: "invoice.pdf", ContentType: "application/pdf", Content: base64.StdEncoding.EncodeToString(catImage)},
+		},
+	}
+
+	manifest, err := client.Files.SaveAttachments(message, t.TempDir(), &SaveAttachmentsOptions{StrictContentType: true})
+	assert.Error(t, err)
+	assert.Contains(t, manifest.Files[0].Error, "declared content type application/pdf but content looks like image/png")
+}
+
+func TestSaveAttachmentsMaxSize(t *testing.T) {
+	skipUnlessLive(t)
+	catImage, _ := os.ReadFile("testing/cat.png")
+
+	message := &Message{
+		Attachments: []*Attachment{
+			{FileName: "cat.png", ContentType: "image/png", Content: base64.StdEncoding.EncodeToString(catImage)},
+		},
+	}
+
+	dir := t.TempDir()
+	manifest, err := client.Files.SaveAttachments(message, dir, &SaveAttachmentsOptions{MaxSize: 1024})
+	assert.Error(t, err)
+	assert.Contains(t, manifest.Files[0].Error, "maximum download size")
+
+	_, statErr := os.Stat(filepath.Join(dir, "cat.png"))
+	assert.True(t, os.IsNotExist(statErr))
+}
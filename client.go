@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,6 +19,12 @@ type MailosaurClient struct {
 	userAgent  string
 	httpClient *http.Client
 
+	// mu guards the settings that can be changed while requests are in flight
+	mu       sync.RWMutex
+	observer Observer
+	quota    *quotaGuard
+	limiter  *rateLimiter
+
 	Servers  *ServersService
 	Messages *MessagesService
 	Analysis *AnalysisService
@@ -79,6 +87,15 @@ func NewWithClient(apiKey string, httpClient *http.Client) *MailosaurClient {
 	return c
 }
 
+// SetBaseUrl points the client at another API endpoint, such as a local
+// mailosaurcatcher server.
+func (c *MailosaurClient) SetBaseUrl(baseUrl string) {
+	if !strings.HasSuffix(baseUrl, "/") {
+		baseUrl += "/"
+	}
+	c.baseUrl = baseUrl
+}
+
 func (c *MailosaurClient) httpRequest(method, path string, body interface{}) (*http.Request, error) {
 	u := c.baseUrl + path
 
@@ -102,62 +119,83 @@ func (c *MailosaurClient) httpRequest(method, path string, body interface{}) (*h
 	return req, nil
 }
 
-func (c *MailosaurClient) executeRequestWithDelayHeader(result interface{}, method string, path string, body interface{}, expectedStatus int) (interface{}, string, error) {
+func (c *MailosaurClient) executeStreamRequest(method string, path string, body interface{}, expectedStatus int) (*http.Response, error) {
+	if limiter := c.loadLimiter(); limiter != nil {
+		if err := limiter.wait(method, path); err != nil {
+			return nil, err
+		}
+	}
+
 	req, err := c.httpRequest(method, path, body)
 
 	if err != nil {
-		return result, "", err
+		return nil, err
 	}
 
 	req.SetBasicAuth(c.apiKey, "")
 	resp, err := c.httpClient.Do(req)
 
 	if err != nil {
-		return result, "", err
+		return nil, err
 	}
 
-	defer resp.Body.Close()
-
 	if resp.StatusCode != expectedStatus {
-		err := &mailosaurError{}
-		err.HttpStatusCode = resp.StatusCode
+		defer resp.Body.Close()
+		return nil, newMailosaurError(resp)
+	}
 
-		var bodyBytes []byte
-		if err.HttpStatusCode != 204 {
-			bodyBytes, _ = io.ReadAll(resp.Body)
-			err.HttpResponseBody = string(bodyBytes)
-		}
+	return resp, nil
+}
+
+func newMailosaurError(resp *http.Response) *mailosaurError {
+	err := &mailosaurError{}
+	err.HttpStatusCode = resp.StatusCode
 
-		message := ""
-		switch resp.StatusCode {
-		case 400:
-			var jsonResult ErrorResponse
-			json.Unmarshal(bodyBytes, &jsonResult)
-			for _, e := range jsonResult.Errors {
-				message += fmt.Sprintf("(%s) %s\r\n", e.Field, e.Detail[0].Description)
-			}
-			err.Message = message
-			err.ErrorType = "invalid_request"
-		case 401:
-			err.Message = "Authentication failed, check your API key."
-			err.ErrorType = "authentication_error"
-		case 403:
-			err.Message = "Insufficient permission to perform that task."
-			err.ErrorType = "permission_error"
-		case 404:
-			err.Message = "Not found, check input parameters."
-			err.ErrorType = "invalid_request"
-		case 410:
-			err.Message = "Permanently expired or deleted."
-			err.ErrorType = "gone"
-		default:
-			err.Message = "An API error occurred, see httpResponse for further information."
-			err.ErrorType = "api_error"
+	var bodyBytes []byte
+	if err.HttpStatusCode != 204 {
+		bodyBytes, _ = io.ReadAll(resp.Body)
+		err.HttpResponseBody = string(bodyBytes)
+	}
+
+	message := ""
+	switch resp.StatusCode {
+	case 400:
+		var jsonResult ErrorResponse
+		json.Unmarshal(bodyBytes, &jsonResult)
+		for _, e := range jsonResult.Errors {
+			message += fmt.Sprintf("(%s) %s\r\n", e.Field, e.Detail[0].Description)
 		}
+		err.Message = message
+		err.ErrorType = "invalid_request"
+	case 401:
+		err.Message = "Authentication failed, check your API key."
+		err.ErrorType = "authentication_error"
+	case 403:
+		err.Message = "Insufficient permission to perform that task."
+		err.ErrorType = "permission_error"
+	case 404:
+		err.Message = "Not found, check input parameters."
+		err.ErrorType = "invalid_request"
+	case 410:
+		err.Message = "Permanently expired or deleted."
+		err.ErrorType = "gone"
+	default:
+		err.Message = "An API error occurred, see httpResponse for further information."
+		err.ErrorType = "api_error"
+	}
 
+	return err
+}
+
+func (c *MailosaurClient) executeRequestWithDelayHeader(result interface{}, method string, path string, body interface{}, expectedStatus int) (interface{}, string, error) {
+	resp, err := c.executeStreamRequest(method, path, body, expectedStatus)
+
+	if err != nil {
 		return result, "", err
 	}
 
+	defer resp.Body.Close()
+
 	// If no result type is being marshalled, just return the bytes
 	if result == nil {
 		bodyBytes, err := io.ReadAll(resp.Body)
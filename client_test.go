@@ -3,9 +3,12 @@ package mailosaur
 import (
 	"encoding/base64"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/smtp"
 	"os"
 	"strings"
+	"testing"
 )
 
 var client *MailosaurClient
@@ -15,6 +18,29 @@ var emails []*MessageSummary
 var email *Message
 var baseUrl string
 
+// live is set when the tests that call the Mailosaur API can run.
+var live = len(os.Getenv("MAILOSAUR_API_KEY")) != 0
+
+// skipUnlessLive skips a test that needs the live API when no API key is
+// set, so that the offline tests can still run.
+func skipUnlessLive(t *testing.T) {
+	t.Helper()
+	if !live {
+		t.Skip("MAILOSAUR_API_KEY is not set")
+	}
+}
+
+// newTestClient returns a client whose requests are served by handler,
+// for tests that do not need the live API.
+func newTestClient(t *testing.T, handler http.Handler) *MailosaurClient {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := New("key")
+	client.SetBaseUrl(server.URL)
+	return client
+}
+
 func sendEmails(client *MailosaurClient, server string, quantity int) {
 	for i := 0; i < quantity; i++ {
 		sendEmail(client, server, "")
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mailosaur/mailosaur-go"
+	"github.com/mailosaur/mailosaur-go/mailosaurusage"
+)
+
+// exporter refreshes account data on an interval and serves the most
+// recent results, so that scrapes never wait on the Mailosaur API.
+type exporter struct {
+	client    *mailosaur.MailosaurClient
+	transport *instrumentedTransport
+	labels    map[string]string
+
+	mu            sync.RWMutex
+	limits        *mailosaur.UsageAccountLimits
+	projections   []*mailosaurusage.Projection
+	servers       []*mailosaur.Server
+	lastRefresh   time.Time
+	refreshes     int
+	refreshErrors int
+}
+
+func newExporter(client *mailosaur.MailosaurClient, transport *instrumentedTransport, labels map[string]string) *exporter {
+	return &exporter{client: client, transport: transport, labels: labels}
+}
+
+// run refreshes immediately, then on every interval until stop is closed.
+func (e *exporter) run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		e.refresh()
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh keeps the previous value of anything that fails to load, and
+// counts the failure.
+func (e *exporter) refresh() {
+	failed := false
+
+	limits, err := e.client.Usage.Limits()
+	if err != nil {
+		log.Printf("fetching usage limits: %s", err)
+		limits, failed = nil, true
+	}
+
+	var projections []*mailosaurusage.Projection
+	if limits != nil {
+		transactions, err := e.client.Usage.Transactions()
+		if err != nil {
+			log.Printf("fetching usage transactions: %s", err)
+			failed = true
+		} else {
+			projections = mailosaurusage.Project(transactions.Items, limits, nil)
+		}
+	}
+
+	servers, err := e.client.Servers.List()
+	if err != nil {
+		log.Printf("listing servers: %s", err)
+		servers, failed = nil, true
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.refreshes++
+	if failed {
+		e.refreshErrors++
+	} else {
+		e.lastRefresh = time.Now()
+	}
+	if limits != nil {
+		e.limits = limits
+		if projections != nil {
+			e.projections = projections
+		}
+	}
+	if servers != nil {
+		e.servers = servers.Items
+	}
+}
+
+func (e *exporter) write(w io.Writer) error {
+	e.mu.RLock()
+	metrics := mailosaurusage.UsageMetrics(e.labels, e.limits, e.projections)
+
+	messages := &mailosaurusage.Metric{Name: "mailosaur_server_messages", Help: "Number of messages held by each server.", Type: mailosaurusage.Gauge}
+	for _, s := range e.servers {
+		messages.Add(float64(s.Messages), e.withLabels(map[string]string{"server_id": s.Id, "server_name": s.Name}))
+	}
+
+	refreshes := &mailosaurusage.Metric{Name: "mailosaur_exporter_refreshes_total", Help: "Number of times account data was refreshed.", Type: mailosaurusage.Counter}
+	refreshes.Add(float64(e.refreshes), e.withLabels(nil))
+
+	refreshErrors := &mailosaurusage.Metric{Name: "mailosaur_exporter_refresh_errors_total", Help: "Number of refreshes in which any request failed.", Type: mailosaurusage.Counter}
+	refreshErrors.Add(float64(e.refreshErrors), e.withLabels(nil))
+
+	lastRefresh := &mailosaurusage.Metric{Name: "mailosaur_exporter_last_refresh_timestamp_seconds", Help: "Time of the last fully successful refresh.", Type: mailosaurusage.Gauge}
+	if !e.lastRefresh.IsZero() {
+		lastRefresh.Add(float64(e.lastRefresh.Unix()), e.withLabels(nil))
+	}
+	e.mu.RUnlock()
+
+	metrics = append(metrics, messages, refreshes, refreshErrors, lastRefresh)
+	metrics = append(metrics, e.requestMetrics()...)
+
+	return mailosaurusage.WriteMetrics(w, metrics...)
+}
+
+func (e *exporter) requestMetrics() []*mailosaurusage.Metric {
+	requests := &mailosaurusage.Metric{Name: "mailosaur_api_requests_total", Help: "Requests made to the Mailosaur API.", Type: mailosaurusage.Counter}
+	errors := &mailosaurusage.Metric{Name: "mailosaur_api_request_errors_total", Help: "Requests to the Mailosaur API that failed or returned an error status.", Type: mailosaurusage.Counter}
+	duration := &mailosaurusage.Metric{Name: "mailosaur_api_request_duration_seconds_total", Help: "Total time spent in requests to the Mailosaur API.", Type: mailosaurusage.Counter}
+
+	stats := e.transport.snapshot()
+	keys := make([]requestKey, 0, len(stats))
+	for k := range stats {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].endpoint != keys[j].endpoint {
+			return keys[i].endpoint < keys[j].endpoint
+		}
+		return keys[i].status < keys[j].status
+	})
+
+	for _, k := range keys {
+		s := stats[k]
+		labels := e.withLabels(map[string]string{"endpoint": k.endpoint, "status": k.status})
+		requests.Add(float64(s.count), labels)
+		errors.Add(float64(s.errors), labels)
+		duration.Add(s.duration.Seconds(), labels)
+	}
+
+	return []*mailosaurusage.Metric{requests, errors, duration}
+}
+
+func (e *exporter) withLabels(extra map[string]string) map[string]string {
+	result := make(map[string]string, len(e.labels)+len(extra))
+	for k, v := range e.labels {
+		result[k] = v
+	}
+	for k, v := range extra {
+		result[k] = v
+	}
+	return result
+}
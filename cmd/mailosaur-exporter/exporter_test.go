@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/mailosaur/mailosaur-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// rewriteTransport sends every request to the test server.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func testExporter(t *testing.T, handler http.HandlerFunc) *exporter {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	target, _ := url.Parse(srv.URL)
+	transport := newInstrumentedTransport(&rewriteTransport{target: target})
+	client := mailosaur.NewWithClient("key", &http.Client{Transport: transport})
+
+	return newExporter(client, transport, map[string]string{"environment": "staging"})
+}
+
+func scrape(t *testing.T, e *exporter) string {
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	body, _ := io.ReadAll(rec.Body)
+	return string(body)
+}
+
+func TestExporter(t *testing.T) {
+	failServers := false
+	e := testExporter(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/usage/limits":
+			w.Write([]byte(`{"servers":{"limit":10,"current":2},"email":{"limit":1000,"current":100}}`))
+		case "/api/usage/transactions":
+			w.Write([]byte(`{"items":[]}`))
+		case "/api/servers":
+			if failServers {
+				w.WriteHeader(500)
+				return
+			}
+			w.Write([]byte(`{"items":[{"id":"abcd1234","name":"Staging","messages":42}]}`))
+		}
+	})
+
+	e.refresh()
+	metrics := scrape(t, e)
+
+	assert.Contains(t, metrics, `mailosaur_usage_limit{environment="staging",resource="email"} 1000`)
+	assert.Contains(t, metrics, `mailosaur_usage_current{environment="staging",resource="servers"} 2`)
+	assert.Contains(t, metrics, `mailosaur_usage_projected{environment="staging",resource="email"} 100`)
+	assert.Contains(t, metrics, `mailosaur_server_messages{environment="staging",server_id="abcd1234",server_name="Staging"} 42`)
+	assert.Contains(t, metrics, `mailosaur_api_requests_total{endpoint="GET /api/servers",environment="staging",status="200"} 1`)
+	assert.Contains(t, metrics, `mailosaur_exporter_refresh_errors_total{environment="staging"} 0`)
+	assert.Contains(t, metrics, "mailosaur_exporter_last_refresh_timestamp_seconds")
+
+	// Cached values are kept when a refresh fails
+	failServers = true
+	e.refresh()
+	metrics = scrape(t, e)
+
+	assert.Contains(t, metrics, `server_name="Staging"} 42`)
+	assert.Contains(t, metrics, `mailosaur_exporter_refreshes_total{environment="staging"} 2`)
+	assert.Contains(t, metrics, `mailosaur_exporter_refresh_errors_total{environment="staging"} 1`)
+	assert.Contains(t, metrics, `mailosaur_api_request_errors_total{endpoint="GET /api/servers",environment="staging",status="500"} 1`)
+}
+
+func TestEndpoint(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/servers/abcd1234/password", nil)
+	assert.Equal(t, "GET /api/servers/{id}/password", endpoint(req))
+
+	req = httptest.NewRequest("GET", "/api/usage/limits", nil)
+	assert.Equal(t, "GET /api/usage/limits", endpoint(req))
+}
+
+func TestLabelFlags(t *testing.T) {
+	labels := labelFlags{}
+	assert.NoError(t, labels.Set("environment=staging"))
+	assert.Error(t, labels.Set("invalid"))
+	assert.True(t, strings.Contains(labels.String(), "environment=staging"))
+}
@@ -0,0 +1,82 @@
+// Command mailosaur-exporter serves Mailosaur account usage, per-server
+// message counts and API request statistics as Prometheus metrics.
+//
+// Usage:
+//
+//	MAILOSAUR_API_KEY=... mailosaur-exporter -listen :9717 -interval 1m -label environment=staging
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mailosaur/mailosaur-go"
+)
+
+type labelFlags map[string]string
+
+func (l labelFlags) String() string {
+	parts := make([]string, 0, len(l))
+	for k, v := range l {
+		parts = append(parts, k+"="+v)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l labelFlags) Set(value string) error {
+	kv := strings.SplitN(value, "=", 2)
+	if len(kv) != 2 || len(kv[0]) == 0 {
+		return fmt.Errorf("label must be in the form name=value")
+	}
+	l[kv[0]] = kv[1]
+	return nil
+}
+
+func main() {
+	labels := labelFlags{}
+
+	listen := flag.String("listen", ":9717", "address to serve metrics on")
+	interval := flag.Duration("interval", time.Minute, "how often to refresh data from the Mailosaur API")
+	timeout := flag.Duration("timeout", 30*time.Second, "timeout for each Mailosaur API request")
+	flag.Var(labels, "label", "label added to every metric, as name=value (repeatable)")
+	flag.Parse()
+
+	apiKey := os.Getenv("MAILOSAUR_API_KEY")
+	if len(apiKey) == 0 {
+		log.Fatal("MAILOSAUR_API_KEY must be set")
+	}
+
+	if *interval < time.Second {
+		log.Fatal("interval must be at least 1s")
+	}
+
+	transport := newInstrumentedTransport(http.DefaultTransport)
+	client := mailosaur.NewWithClient(apiKey, &http.Client{Timeout: *timeout, Transport: transport})
+
+	e := newExporter(client, transport, labels)
+	go e.run(*interval, nil)
+
+	http.Handle("/metrics", e)
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintln(w, `<html><body><a href="/metrics">Metrics</a></body></html>`)
+	})
+
+	log.Printf("serving metrics on %s/metrics, refreshing every %s", *listen, *interval)
+	log.Fatal(http.ListenAndServe(*listen, nil))
+}
+
+func (e *exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := e.write(w); err != nil {
+		log.Printf("writing metrics: %s", err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// instrumentedTransport records the latency and outcome of each API
+// request made by the client.
+type instrumentedTransport struct {
+	next http.RoundTripper
+
+	mu    sync.Mutex
+	stats map[requestKey]*requestStats
+}
+
+type requestKey struct {
+	endpoint string
+	status   string
+}
+
+type requestStats struct {
+	count    int
+	errors   int
+	duration time.Duration
+}
+
+var idSegment = regexp.MustCompile(`/[^/]*[0-9][^/]*`)
+
+func newInstrumentedTransport(next http.RoundTripper) *instrumentedTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &instrumentedTransport{next: next, stats: map[requestKey]*requestStats{}}
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	started := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(started)
+
+	key := requestKey{endpoint: endpoint(req)}
+	failed := err != nil
+	if err != nil {
+		key.status = "error"
+	} else {
+		key.status = strconv.Itoa(resp.StatusCode)
+		failed = resp.StatusCode >= 400
+	}
+
+	t.mu.Lock()
+	s, ok := t.stats[key]
+	if !ok {
+		s = &requestStats{}
+		t.stats[key] = s
+	}
+	s.count++
+	s.duration += elapsed
+	if failed {
+		s.errors++
+	}
+	t.mu.Unlock()
+
+	return resp, err
+}
+
+// snapshot returns a copy of the statistics gathered so far.
+func (t *instrumentedTransport) snapshot() map[requestKey]requestStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make(map[requestKey]requestStats, len(t.stats))
+	for k, v := range t.stats {
+		result[k] = *v
+	}
+	return result
+}
+
+// endpoint replaces IDs in the request path, so that the number of
+// distinct label values stays small.
+func endpoint(req *http.Request) string {
+	return req.Method + " " + idSegment.ReplaceAllString(req.URL.Path, "/{id}")
+}
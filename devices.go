@@ -1,6 +1,7 @@
 package mailosaur
 
 import (
+	"fmt"
 	"strings"
 	"time"
 )
@@ -23,6 +24,11 @@ type DeviceCreateOptions struct {
 	SharedSecret string `json:"sharedSecret"`
 }
 
+type DeviceUpdateOptions struct {
+	Name         string `json:"name,omitempty"`
+	SharedSecret string `json:"sharedSecret,omitempty"`
+}
+
 type OtpResult struct {
 	Code    string    `json:"code"`
 	Expires time.Time `json:"expires"`
@@ -38,16 +44,148 @@ func (s *DevicesService) Create(deviceCreateOptions DeviceCreateOptions) (*Devic
 	return result.(*Device), err
 }
 
+// Get finds a device by ID or, failing that, by its exact name. As with
+// Otp, an argument containing '-' is first fetched as an ID. It is an error
+// for more than one device to have the name.
+func (s *DevicesService) Get(idOrName string) (*Device, error) {
+	if strings.Contains(idOrName, "-") {
+		result, err := s.client.HttpGet(&Device{}, "api/devices/"+idOrName)
+		if err == nil {
+			return result.(*Device), nil
+		}
+		if e, ok := err.(*mailosaurError); !ok || e.HttpStatusCode != 404 {
+			return nil, err
+		}
+	}
+
+	result, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range result.Items {
+		if d.Id == idOrName {
+			return d, nil
+		}
+	}
+
+	named := devicesNamed(result.Items, idOrName)
+
+	switch len(named) {
+	case 0:
+		return nil, &mailosaurError{
+			Message:   "No device with the ID or name [" + idOrName + "] was found.",
+			ErrorType: "invalid_request",
+		}
+	case 1:
+		return named[0], nil
+	default:
+		return nil, &mailosaurError{
+			Message:   "More than one device is named [" + idOrName + "], use the device ID instead.",
+			ErrorType: "invalid_request",
+		}
+	}
+}
+
+// Update changes the name or shared secret of a device, keeping its ID.
+// Empty fields are left unchanged.
+func (s *DevicesService) Update(id string, deviceUpdateOptions DeviceUpdateOptions) (*Device, error) {
+	result, err := s.client.HttpPut(&Device{}, "api/devices/"+id, deviceUpdateOptions)
+	return result.(*Device), err
+}
+
+// Otp treats a query containing '-' as a device ID and anything else as a
+// shared secret. Prefer OtpByDevice or OtpBySecret, which do not guess.
 func (s *DevicesService) Otp(query string) (*OtpResult, error) {
 	if strings.Contains(query, "-") {
-		result, err := s.client.HttpGet(&OtpResult{}, "api/devices/"+query+"/otp")
-		return result.(*OtpResult), err
+		return s.OtpByDevice(query)
 	}
 
-	result, err := s.client.HttpPost(&OtpResult{}, "api/devices/otp", &DeviceCreateOptions{SharedSecret: query})
+	return s.OtpBySecret(query)
+}
+
+func (s *DevicesService) OtpByDevice(id string) (*OtpResult, error) {
+	result, err := s.client.HttpGet(&OtpResult{}, "api/devices/"+id+"/otp")
+	return result.(*OtpResult), err
+}
+
+func (s *DevicesService) OtpBySecret(secret string) (*OtpResult, error) {
+	result, err := s.client.HttpPost(&OtpResult{}, "api/devices/otp", &DeviceCreateOptions{SharedSecret: secret})
 	return result.(*OtpResult), err
 }
 
+// OtpByName looks up the device by its exact name, failing if there is no
+// device, or more than one, with the name.
+func (s *DevicesService) OtpByName(name string) (*OtpResult, error) {
+	result, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	named := devicesNamed(result.Items, name)
+	if len(named) != 1 {
+		return nil, &mailosaurError{
+			Message:   fmt.Sprintf("Expected one device named [%s] but found %d.", name, len(named)),
+			ErrorType: "invalid_request",
+		}
+	}
+
+	return s.OtpByDevice(named[0].Id)
+}
+
+// WaitForFreshOtp calls otp, such as a bound OtpByDevice, until it returns a
+// code valid for at least minRemaining, sleeping until the current code
+// expires when it is too close to expiry. This avoids submitting a code
+// that expires before a form can be completed.
+func (s *DevicesService) WaitForFreshOtp(otp func() (*OtpResult, error), minRemaining time.Duration) (*OtpResult, error) {
+	// Two rotations are always enough unless minRemaining is longer than
+	// the period of the code
+	const attempts = 3
+	for attempt := 0; attempt < attempts; attempt++ {
+		result, err := otp()
+		if err != nil {
+			return nil, err
+		}
+
+		remaining := result.Remaining()
+		if remaining >= minRemaining {
+			return result, nil
+		}
+
+		// There is no point waiting for a code that will not be fetched
+		if attempt == attempts-1 {
+			break
+		}
+
+		// Allow for the code to rotate on the server
+		time.Sleep(remaining + 250*time.Millisecond)
+	}
+
+	return nil, &mailosaurError{
+		Message:   "No one-time password valid for at least " + minRemaining.String() + " could be generated.",
+		ErrorType: "otp_not_fresh",
+	}
+}
+
+// Remaining returns how long the code has left before it expires.
+func (r *OtpResult) Remaining() time.Duration {
+	remaining := time.Until(r.Expires)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
 func (s *DevicesService) Delete(id string) error {
 	return s.client.HttpDelete("api/devices/" + id)
 }
+
+func devicesNamed(devices []*Device, name string) []*Device {
+	var named []*Device
+	for _, d := range devices {
+		if d.Name == name {
+			named = append(named, d)
+		}
+	}
+	return named
+}
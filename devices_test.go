@@ -3,6 +3,7 @@ package mailosaur
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -19,6 +20,7 @@ func init() {
 }
 
 func TestDevicesCrud(t *testing.T) {
+	skipUnlessLive(t)
 	deviceName := "My GO test"
 	sharedSecret := "ONSWG4TFOQYTEMY="
 
@@ -48,6 +50,7 @@ func TestDevicesCrud(t *testing.T) {
 }
 
 func TestOtpViaSharedSecret(t *testing.T) {
+	skipUnlessLive(t)
 	sharedSecret := "ONSWG4TFOQYTEMY="
 
 	otpResult, err := client.Devices.Otp(sharedSecret)
@@ -55,6 +58,76 @@ func TestOtpViaSharedSecret(t *testing.T) {
 	assert.Equal(t, 6, len(otpResult.Code))
 }
 
+func TestOtpByDeviceAndSecret(t *testing.T) {
+	skipUnlessLive(t)
+	sharedSecret := "ONSWG4TFOQYTEMY="
+
+	bySecret, err := client.Devices.OtpBySecret(sharedSecret)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, len(bySecret.Code))
+
+	device, err := client.Devices.Create(DeviceCreateOptions{Name: "My GO OTP test", SharedSecret: sharedSecret})
+	assert.NoError(t, err)
+	defer client.Devices.Delete(device.Id)
+
+	byDevice, err := client.Devices.WaitForFreshOtp(func() (*OtpResult, error) {
+		return client.Devices.OtpByDevice(device.Id)
+	}, 5*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, len(byDevice.Code))
+	assert.True(t, byDevice.Remaining() >= 4*time.Second)
+}
+
+func TestOtpByName(t *testing.T) {
+	f := &fakeDevices{devices: []*Device{{Id: "d1", Name: "Phone"}, {Id: "d2", Name: "Tablet"}, {Id: "d3", Name: "Tablet"}}}
+	client := newTestClient(t, f)
+
+	result, err := client.Devices.OtpByName("Phone")
+	assert.NoError(t, err)
+	assert.Equal(t, "/api/devices/d1/otp", result.Code)
+
+	_, err = client.Devices.OtpByName("Tablet")
+	assert.EqualError(t, err, "Expected one device named [Tablet] but found 2.")
+
+	_, err = client.Devices.OtpByName("d1")
+	assert.Error(t, err)
+}
+
+func TestWaitForFreshOtp(t *testing.T) {
+	client := New("key")
+	calls := 0
+	otp := func() (*OtpResult, error) {
+		calls++
+		if calls == 1 {
+			return &OtpResult{Code: "111111", Expires: time.Now().Add(100 * time.Millisecond)}, nil
+		}
+		return &OtpResult{Code: "222222", Expires: time.Now().Add(30 * time.Second)}, nil
+	}
+
+	result, err := client.Devices.WaitForFreshOtp(otp, 5*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "222222", result.Code)
+	assert.Equal(t, 2, calls)
+
+	// A code that is already fresh is returned straight away
+	result, err = client.Devices.WaitForFreshOtp(otp, 5*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	// Only the waits between attempts are slept, not one after the last
+	calls = 0
+	started := time.Now()
+	_, err = client.Devices.WaitForFreshOtp(func() (*OtpResult, error) {
+		calls++
+		return &OtpResult{Expires: time.Now().Add(500 * time.Millisecond)}, nil
+	}, time.Minute)
+	assert.Equal(t, "otp_not_fresh", err.(*mailosaurError).ErrorType)
+	assert.Equal(t, 3, calls)
+	assert.True(t, time.Since(started) < 2*time.Second)
+
+	assert.Equal(t, time.Duration(0), (&OtpResult{}).Remaining())
+}
+
 func contains_device(list []*Device, id string) bool {
 	for _, x := range list {
 		if id == x.Id {
@@ -0,0 +1,141 @@
+package mailosaur
+
+type DeviceSyncOptions struct {
+	// UpdateSecrets sets the shared secret of devices that already exist.
+	// Secrets cannot be read back from the API, so without this existing
+	// devices are kept as they are.
+	UpdateSecrets bool
+	// Delete removes devices that are not in the desired set.
+	Delete bool
+}
+
+type DeviceSyncAction struct {
+	SyncAction
+	// Device is the existing device, or the created one once applied
+	Device  *Device
+	Options DeviceCreateOptions
+}
+
+func (a *DeviceSyncAction) String() string {
+	id := ""
+	if a.Device != nil {
+		id = a.Device.Id
+	}
+	return a.describe(id, "shared secret")
+}
+
+type DeviceSyncPlan struct {
+	Actions []*DeviceSyncAction
+}
+
+func (p *DeviceSyncPlan) steps() []syncStep {
+	steps := make([]syncStep, len(p.Actions))
+	for i, a := range p.Actions {
+		steps[i] = a
+	}
+	return steps
+}
+
+// HasChanges reports whether applying the plan would change anything.
+func (p *DeviceSyncPlan) HasChanges() bool {
+	return syncHasChanges(p.steps())
+}
+
+func (p *DeviceSyncPlan) String() string {
+	return syncPlanString(p.steps(), true)
+}
+
+// PlanSync compares the desired devices, matched by name, with those on
+// the account and returns the actions needed to reconcile them, without
+// making any changes.
+func (s *DevicesService) PlanSync(desired []DeviceCreateOptions, options *DeviceSyncOptions) (*DeviceSyncPlan, error) {
+	if options == nil {
+		options = &DeviceSyncOptions{}
+	}
+
+	names := make([]string, len(desired))
+	for i, d := range desired {
+		names[i] = d.Name
+	}
+	seen, err := checkSyncNames("device", names)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	existingNames := make([]string, len(existing.Items))
+	for i, d := range existing.Items {
+		existingNames[i] = d.Name
+	}
+	byName, surplus := matchSyncNames(seen, existingNames)
+
+	plan := &DeviceSyncPlan{}
+	for _, d := range desired {
+		action := &DeviceSyncAction{SyncAction: SyncAction{Name: d.Name}, Options: d}
+		if i, ok := byName[d.Name]; ok {
+			action.Device = existing.Items[i]
+		}
+
+		switch {
+		case action.Device == nil:
+			action.Action = SyncCreate
+		case options.UpdateSecrets && len(d.SharedSecret) > 0:
+			action.Action = SyncUpdate
+		default:
+			action.Action = SyncKeep
+		}
+		plan.Actions = append(plan.Actions, action)
+	}
+
+	if options.Delete {
+		for _, i := range surplus {
+			d := existing.Items[i]
+			plan.Actions = append(plan.Actions, &DeviceSyncAction{SyncAction: SyncAction{Action: SyncDelete, Name: d.Name}, Device: d})
+		}
+	}
+
+	return plan, nil
+}
+
+// ApplySync carries out a plan from PlanSync, stopping at the first error.
+// Actions that completed are marked as applied.
+func (s *DevicesService) ApplySync(plan *DeviceSyncPlan) error {
+	for _, a := range plan.Actions {
+		var err error
+
+		switch a.Action {
+		case SyncCreate:
+			var device *Device
+			device, err = s.Create(a.Options)
+			if err == nil {
+				a.Device = device
+			}
+		case SyncUpdate:
+			_, err = s.Update(a.Device.Id, DeviceUpdateOptions{SharedSecret: a.Options.SharedSecret})
+		case SyncDelete:
+			err = s.Delete(a.Device.Id)
+		}
+
+		if err != nil {
+			return err
+		}
+		a.Applied = true
+	}
+
+	return nil
+}
+
+// Sync plans and applies changes in one step, returning the plan so that
+// the changes can be reported.
+func (s *DevicesService) Sync(desired []DeviceCreateOptions, options *DeviceSyncOptions) (*DeviceSyncPlan, error) {
+	plan, err := s.PlanSync(desired, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, s.ApplySync(plan)
+}
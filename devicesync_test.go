@@ -0,0 +1,151 @@
+package mailosaur
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDevices struct {
+	devices  []*Device
+	requests []string
+	// updates are the bodies of PUT requests
+	updates []map[string]interface{}
+}
+
+func (f *fakeDevices) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/api/devices":
+		json.NewEncoder(w).Encode(&DeviceListResult{Items: f.devices})
+	case r.Method == "POST" && r.URL.Path == "/api/devices":
+		var options DeviceCreateOptions
+		json.NewDecoder(r.Body).Decode(&options)
+		json.NewEncoder(w).Encode(&Device{Id: "new-" + options.Name, Name: options.Name})
+	case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/otp"):
+		json.NewEncoder(w).Encode(&OtpResult{Code: r.URL.Path, Expires: time.Now().Add(30 * time.Second)})
+	case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/api/devices/"):
+		for _, d := range f.devices {
+			if d.Id == r.URL.Path[len("/api/devices/"):] {
+				json.NewEncoder(w).Encode(d)
+				return
+			}
+		}
+		w.WriteHeader(404)
+	case r.Method == "PUT":
+		var update map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&update)
+		f.updates = append(f.updates, update)
+		name, _ := update["name"].(string)
+		json.NewEncoder(w).Encode(&Device{Id: r.URL.Path[len("/api/devices/"):], Name: name})
+	case r.Method == "DELETE":
+		w.WriteHeader(204)
+	}
+}
+
+func TestDevicesGet(t *testing.T) {
+	f := &fakeDevices{devices: []*Device{{Id: "d1", Name: "Phone"}, {Id: "d2", Name: "Tablet"}, {Id: "d3", Name: "Tablet"}}}
+	client := newTestClient(t, f)
+
+	device, err := client.Devices.Get("d2")
+	assert.NoError(t, err)
+	assert.Equal(t, "Tablet", device.Name)
+
+	device, err = client.Devices.Get("Phone")
+	assert.NoError(t, err)
+	assert.Equal(t, "d1", device.Id)
+
+	_, err = client.Devices.Get("Tablet")
+	assert.EqualError(t, err, "More than one device is named [Tablet], use the device ID instead.")
+
+	_, err = client.Devices.Get("Laptop")
+	assert.Error(t, err)
+
+	// IDs such as those of real devices are fetched directly, falling back
+	// to the name when there is no such device
+	f = &fakeDevices{devices: []*Device{{Id: "a1-b2", Name: "Phone"}, {Id: "c3-d4", Name: "Sign-in phone"}}}
+	client = newTestClient(t, f)
+
+	device, err = client.Devices.Get("a1-b2")
+	assert.NoError(t, err)
+	assert.Equal(t, "Phone", device.Name)
+	assert.Equal(t, []string{"GET /api/devices/a1-b2"}, f.requests)
+
+	device, err = client.Devices.Get("Sign-in phone")
+	assert.NoError(t, err)
+	assert.Equal(t, "c3-d4", device.Id)
+	assert.Equal(t, "GET /api/devices", f.requests[len(f.requests)-1])
+}
+
+func TestDevicesUpdate(t *testing.T) {
+	f := &fakeDevices{devices: []*Device{{Id: "d1", Name: "Phone"}}}
+	client := newTestClient(t, f)
+
+	device, err := client.Devices.Update("d1", DeviceUpdateOptions{Name: "Phone (renamed)"})
+	assert.NoError(t, err)
+	assert.Equal(t, "d1", device.Id)
+	assert.Equal(t, "Phone (renamed)", device.Name)
+	assert.Equal(t, "PUT /api/devices/d1", f.requests[0])
+
+	// Empty fields are left out, so they are unchanged
+	assert.Equal(t, map[string]interface{}{"name": "Phone (renamed)"}, f.updates[0])
+
+	_, err = client.Devices.Update("d1", DeviceUpdateOptions{SharedSecret: "ONSWG4TFOQYTEMY="})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"sharedSecret": "ONSWG4TFOQYTEMY="}, f.updates[1])
+}
+
+func TestDevicesSyncPlan(t *testing.T) {
+	f := &fakeDevices{devices: []*Device{{Id: "d1", Name: "Phone"}, {Id: "d2", Name: "Old"}, {Id: "d3", Name: "Phone"}}}
+	client := newTestClient(t, f)
+
+	desired := []DeviceCreateOptions{
+		{Name: "Phone", SharedSecret: "ONSWG4TFOQYTEMY="},
+		{Name: "Tablet", SharedSecret: "ONSWG4TFOQYTEMY="},
+	}
+
+	plan, err := client.Devices.PlanSync(desired, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "  keep   Phone (d1)\n+ create Tablet\n1 to create, 0 to update, 0 to delete, 1 unchanged", plan.String())
+
+	plan, err = client.Devices.PlanSync(desired, &DeviceSyncOptions{UpdateSecrets: true, Delete: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "~ update Phone (d1): shared secret\n"+
+		"+ create Tablet\n"+
+		"- delete Old (d2)\n"+
+		"- delete Phone (d3)\n"+
+		"1 to create, 1 to update, 2 to delete, 0 unchanged", plan.String())
+	assert.True(t, plan.HasChanges())
+
+	// Planning made no changes
+	for _, r := range f.requests {
+		assert.Equal(t, "GET /api/devices", r)
+	}
+
+	f.requests = nil
+	assert.NoError(t, client.Devices.ApplySync(plan))
+	assert.Equal(t, []string{"PUT /api/devices/d1", "POST /api/devices", "DELETE /api/devices/d2", "DELETE /api/devices/d3"}, f.requests)
+	assert.Equal(t, "new-Tablet", plan.Actions[1].Device.Id)
+	for _, a := range plan.Actions {
+		assert.True(t, a.Applied)
+	}
+}
+
+func TestDevicesSyncInvalid(t *testing.T) {
+	client := newTestClient(t, &fakeDevices{})
+
+	_, err := client.Devices.Sync([]DeviceCreateOptions{{Name: "A"}, {Name: "A"}}, nil)
+	assert.Error(t, err)
+
+	_, err = client.Devices.Sync([]DeviceCreateOptions{{SharedSecret: "x"}}, nil)
+	assert.Error(t, err)
+
+	plan, err := client.Devices.Sync(nil, nil)
+	assert.NoError(t, err)
+	assert.False(t, plan.HasChanges())
+}
@@ -0,0 +1,279 @@
+package mailosaur
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// ParseEmail converts a raw RFC 5322 message, such as the one returned by
+// FilesService.GetEmail, into a Message. The first text/plain and text/html
+// parts become the text and HTML content, and every other part is returned
+// as an attachment with its content base64 encoded. Received is left for the
+// caller to set from the delivery time, since the Date header is chosen by
+// the sender; that header is still available in Metadata.Headers.
+func ParseEmail(raw []byte) (*Message, error) {
+	headers, body, err := readHeaders(bufio.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Message{
+		Type:     "Email",
+		Metadata: &Metadata{Headers: headers},
+	}
+
+	m.Subject = decodeHeader(headerValue(headers, "Subject"))
+	m.From = parseAddressList(headerValue(headers, "From"))
+	m.To = parseAddressList(headerValue(headers, "To"))
+	m.Cc = parseAddressList(headerValue(headers, "Cc"))
+	m.Bcc = parseAddressList(headerValue(headers, "Bcc"))
+
+	if err := parsePart(m, headers, body); err != nil {
+		return nil, err
+	}
+
+	for _, c := range []*MessageContent{m.Html, m.Text} {
+		if c != nil && c.Links == nil {
+			c.Links = []*Link{}
+		}
+	}
+
+	return m, nil
+}
+
+// readHeaders reads a header block, preserving the order and case of the
+// fields, and returns the remainder of the input as the body.
+func readHeaders(r *bufio.Reader) ([]*MessageHeader, io.Reader, error) {
+	var headers []*MessageHeader
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, nil, err
+		}
+
+		trimmed := strings.TrimRight(line, "\r\n")
+		if len(trimmed) == 0 {
+			break
+		}
+
+		if (trimmed[0] == ' ' || trimmed[0] == '\t') && len(headers) > 0 {
+			// Folded continuation of the previous header
+			last := headers[len(headers)-1]
+			last.Value += " " + strings.TrimSpace(trimmed)
+		} else if idx := strings.Index(trimmed, ":"); idx > 0 {
+			headers = append(headers, &MessageHeader{
+				Field: strings.TrimSpace(trimmed[:idx]),
+				Value: strings.TrimSpace(trimmed[idx+1:]),
+			})
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	return headers, r, nil
+}
+
+func headerValue(headers []*MessageHeader, field string) string {
+	for _, h := range headers {
+		if strings.EqualFold(h.Field, field) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+func decodeHeader(value string) string {
+	decoded, err := wordDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+func parseAddressList(value string) []*MessageAddress {
+	result := []*MessageAddress{}
+	if len(strings.TrimSpace(value)) == 0 {
+		return result
+	}
+
+	parser := &mail.AddressParser{WordDecoder: wordDecoder}
+	list, err := parser.ParseList(value)
+	if err != nil {
+		// Fall back to the raw value rather than losing the address
+		return append(result, &MessageAddress{Email: strings.TrimSpace(value)})
+	}
+
+	for _, a := range list {
+		result = append(result, &MessageAddress{Name: a.Name, Email: a.Address})
+	}
+	return result
+}
+
+func parsePart(m *Message, headers []*MessageHeader, body io.Reader) error {
+	contentType := headerValue(headers, "Content-Type")
+	if len(contentType) == 0 {
+		contentType = "text/plain; charset=us-ascii"
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+		params = map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			var partHeaders []*MessageHeader
+			for field, values := range p.Header {
+				for _, v := range values {
+					partHeaders = append(partHeaders, &MessageHeader{Field: field, Value: v})
+				}
+			}
+
+			if err := parsePart(m, partHeaders, p); err != nil {
+				return err
+			}
+		}
+	}
+
+	content, err := decodeTransferEncoding(headerValue(headers, "Content-Transfer-Encoding"), body)
+	if err != nil {
+		return err
+	}
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(headerValue(headers, "Content-Disposition"))
+	fileName := dispositionParams["filename"]
+	if len(fileName) == 0 {
+		fileName = params["name"]
+	}
+
+	isAttachment := disposition == "attachment" || len(fileName) > 0
+
+	if !isAttachment && mediaType == "text/plain" && m.Text == nil {
+		m.Text = &MessageContent{Body: decodeCharset(params["charset"], content)}
+		m.Text.Links = textLinks(m.Text.Body)
+		return nil
+	}
+
+	if !isAttachment && mediaType == "text/html" && m.Html == nil {
+		m.Html = &MessageContent{Body: decodeCharset(params["charset"], content)}
+		m.Html.Links, m.Html.Images = htmlLinksAndImages(m.Html.Body)
+		return nil
+	}
+
+	m.Attachments = append(m.Attachments, &Attachment{
+		ContentType: mediaType,
+		FileName:    decodeHeader(fileName),
+		Content:     base64.StdEncoding.EncodeToString(content),
+		ContentId:   strings.Trim(headerValue(headers, "Content-Id"), "<>"),
+		Length:      len(content),
+	})
+
+	return nil
+}
+
+func decodeTransferEncoding(encoding string, body io.Reader) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		raw, err := io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		cleaned := strings.Map(func(r rune) rune {
+			if r == '\r' || r == '\n' || r == ' ' || r == '\t' {
+				return -1
+			}
+			return r
+		}, string(raw))
+		return base64.StdEncoding.DecodeString(cleaned)
+	case "quoted-printable":
+		return io.ReadAll(quotedprintable.NewReader(body))
+	default:
+		return io.ReadAll(body)
+	}
+}
+
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	content, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	return strings.NewReader(decodeCharset(charset, content)), nil
+}
+
+// decodeCharset converts the common single-byte charsets to UTF-8. Content
+// in other charsets is returned unchanged.
+func decodeCharset(charset string, content []byte) string {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1":
+		runes := make([]rune, len(content))
+		for i, b := range content {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	default:
+		return string(content)
+	}
+}
+
+var (
+	textLinkPattern = regexp.MustCompile(`https?://[^\s<>"')\]]+`)
+	anchorPattern   = regexp.MustCompile(`(?is)<a\s[^>]*?href\s*=\s*["']([^"']*)["'][^>]*>(.*?)</a>`)
+	imagePattern    = regexp.MustCompile(`(?is)<img\s[^>]*>`)
+	srcPattern      = regexp.MustCompile(`(?is)\bsrc\s*=\s*["']([^"']*)["']`)
+	altPattern      = regexp.MustCompile(`(?is)\balt\s*=\s*["']([^"']*)["']`)
+	tagPattern      = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+func textLinks(body string) []*Link {
+	links := []*Link{}
+	for _, href := range textLinkPattern.FindAllString(body, -1) {
+		links = append(links, &Link{Href: href, Text: href})
+	}
+	return links
+}
+
+func htmlLinksAndImages(body string) ([]*Link, []*Image) {
+	links := []*Link{}
+	for _, match := range anchorPattern.FindAllStringSubmatch(body, -1) {
+		text := strings.Join(strings.Fields(tagPattern.ReplaceAllString(match[2], " ")), " ")
+		links = append(links, &Link{Href: html.UnescapeString(match[1]), Text: html.UnescapeString(text)})
+	}
+
+	images := []*Image{}
+	for _, tag := range imagePattern.FindAllString(body, -1) {
+		img := &Image{}
+		if src := srcPattern.FindStringSubmatch(tag); src != nil {
+			img.Src = html.UnescapeString(src[1])
+		}
+		if alt := altPattern.FindStringSubmatch(tag); alt != nil {
+			img.Alt = html.UnescapeString(alt[1])
+		}
+		images = append(images, img)
+	}
+
+	return links, images
+}
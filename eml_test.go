@@ -0,0 +1,102 @@
+package mailosaur
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testEml = "Received: from mx.example\r\n" +
+	"\tby mailosaur.net; Tue, 02 Jan 2024 09:00:01 +0000\r\n" +
+	"From: =?UTF-8?Q?Ren=C3=A9e?= <renee@acme.example>\r\n" +
+	"To: Jo <jo@abcd1234.mailosaur.net>, sam@abcd1234.mailosaur.net\r\n" +
+	"Cc: cc@abcd1234.mailosaur.net\r\n" +
+	"Subject: =?UTF-8?B?V2VsY29tZSDwn5GL?=\r\n" +
+	"Date: Tue, 02 Jan 2024 10:00:00 +0100\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"b1\"\r\n" +
+	"\r\n" +
+	"--b1\r\n" +
+	"Content-Type: multipart/alternative; boundary=\"b2\"\r\n" +
+	"\r\n" +
+	"--b2\r\n" +
+	"Content-Type: text/plain; charset=iso-8859-1\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Caf=E9 menu: https://acme.example/menu?a=1\r\n" +
+	"--b2\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"\r\n" +
+	"<p>Hello <a href=\"https://acme.example/?a=1&amp;b=2\"><b>Open</b> menu</a><img src=\"cid:logo\" alt=\"Logo\"></p>\r\n" +
+	"--b2--\r\n" +
+	"--b1\r\n" +
+	"Content-Type: image/png\r\n" +
+	"Content-Id: <logo>\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"iVBORw0K\r\n" +
+	"GgoAAAA=\r\n" +
+	"--b1\r\n" +
+	"Content-Type: text/plain; name=\"notes.txt\"\r\n" +
+	"Content-Disposition: attachment; filename=\"notes.txt\"\r\n" +
+	"\r\n" +
+	"notes\r\n" +
+	"--b1--\r\n"
+
+func TestParseEmail(t *testing.T) {
+	m, err := ParseEmail([]byte(testEml))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Email", m.Type)
+	assert.Equal(t, "Welcome 👋", m.Subject)
+	assert.True(t, m.Received.IsZero())
+	assert.Equal(t, "Renée", m.From[0].Name)
+	assert.Equal(t, "renee@acme.example", m.From[0].Email)
+	assert.Equal(t, 2, len(m.To))
+	assert.Equal(t, "sam@abcd1234.mailosaur.net", m.To[1].Email)
+	assert.Equal(t, "cc@abcd1234.mailosaur.net", m.Cc[0].Email)
+	assert.Equal(t, 0, len(m.Bcc))
+
+	assert.Equal(t, "Received", m.Metadata.Headers[0].Field)
+	assert.Equal(t, "from mx.example by mailosaur.net; Tue, 02 Jan 2024 09:00:01 +0000", m.Metadata.Headers[0].Value)
+	assert.Equal(t, "Date", m.Metadata.Headers[5].Field)
+	assert.Equal(t, "Tue, 02 Jan 2024 10:00:00 +0100", m.Metadata.Headers[5].Value)
+
+	assert.Equal(t, "Café menu: https://acme.example/menu?a=1", strings.TrimSpace(m.Text.Body))
+	assert.Equal(t, "https://acme.example/menu?a=1", m.Text.Links[0].Href)
+
+	assert.Contains(t, m.Html.Body, "<b>Open</b>")
+	assert.Equal(t, "https://acme.example/?a=1&b=2", m.Html.Links[0].Href)
+	assert.Equal(t, "Open menu", m.Html.Links[0].Text)
+	assert.Equal(t, "cid:logo", m.Html.Images[0].Src)
+	assert.Equal(t, "Logo", m.Html.Images[0].Alt)
+
+	assert.Equal(t, 2, len(m.Attachments))
+	logo := m.Attachments[0]
+	assert.Equal(t, "image/png", logo.ContentType)
+	assert.Equal(t, "logo", logo.ContentId)
+	assert.Equal(t, 11, logo.Length)
+	content, _ := base64.StdEncoding.DecodeString(logo.Content)
+	assert.Equal(t, "\x89PNG\r\n\x1a\n\x00\x00\x00", string(content))
+
+	assert.Equal(t, "notes.txt", m.Attachments[1].FileName)
+	assert.Equal(t, "text/plain", m.Attachments[1].ContentType)
+}
+
+func TestParseEmailSinglePart(t *testing.T) {
+	m, err := ParseEmail([]byte("Subject: Plain\n\nJust text\n"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Plain", m.Subject)
+	assert.Equal(t, "Just text\n", m.Text.Body)
+	assert.Nil(t, m.Html)
+	assert.Equal(t, 0, len(m.From))
+	assert.True(t, m.Received.IsZero())
+}
+
+func TestParseEmailInvalidBase64(t *testing.T) {
+	_, err := ParseEmail([]byte("Content-Type: image/png\r\nContent-Transfer-Encoding: base64\r\n\r\n!!!\r\n"))
+	assert.Error(t, err)
+}
@@ -16,6 +16,7 @@ func init() {
 }
 
 func TestUnauthorized(t *testing.T) {
+	skipUnlessLive(t)
 	client := New("invalid_key")
 	client.baseUrl = baseUrl
 	_, err := client.Servers.List()
@@ -25,6 +26,7 @@ func TestUnauthorized(t *testing.T) {
 }
 
 func TestNotFound(t *testing.T) {
+	skipUnlessLive(t)
 	client := New()
 	client.baseUrl = baseUrl
 	_, err := client.Servers.Get("not_found")
@@ -34,6 +36,7 @@ func TestNotFound(t *testing.T) {
 }
 
 func TestBadRequest(t *testing.T) {
+	skipUnlessLive(t)
 	client := New()
 	client.baseUrl = baseUrl
 	serverCreateOptions := ServerCreateOptions{}
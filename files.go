@@ -1,6 +1,8 @@
 package mailosaur
 
 import (
+	"io"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -21,15 +23,44 @@ func (s *FilesService) GetEmail(id string) ([]byte, error) {
 }
 
 func (s *FilesService) GetPreview(id string) ([]byte, error) {
-	timeout := 120
+	return s.getPreview(id, 120)
+}
+
+func (s *FilesService) getPreview(id string, timeout int) ([]byte, error) {
+	result, err := s.observePreview(id, timeout, func() (interface{}, string, error) {
+		return s.client.executeRequestWithDelayHeader(nil, "GET", "api/files/screenshots/"+id, nil, 200)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]byte), nil
+}
+
+func (s *FilesService) observePreview(id string, timeout int, fetch func() (interface{}, string, error)) (interface{}, error) {
+	op := &Operation{Name: "files.preview", Target: id, Started: time.Now()}
+
+	result, polls, err := s.pollPreview(id, timeout, fetch)
+	op.Polls = polls
+	op.Err = err
+	if err == nil {
+		op.Results = 1
+	}
+	s.client.observe(op)
+
+	return result, err
+}
+
+func (s *FilesService) pollPreview(id string, timeout int, fetch func() (interface{}, string, error)) (interface{}, int, error) {
 	pollCount := 0
 	startTime := time.Now()
 
 	for {
-		result, delayHeader, err := s.client.executeRequestWithDelayHeader(nil, "GET", "api/files/screenshots/"+id, nil, 200)
+		result, delayHeader, err := fetch()
 
 		if err == nil {
-			return result.([]byte), nil
+			return result, pollCount + 1, nil
 		}
 
 		// Check if it's a mailosaur error and if the status code is 202 (still processing)
@@ -37,7 +68,7 @@ func (s *FilesService) GetPreview(id string) ([]byte, error) {
 			if mailosaurErr.HttpStatusCode == 202 {
 				// Continue polling
 			} else if mailosaurErr.HttpStatusCode == 410 {
-				return nil, &mailosaurError{
+				return nil, pollCount + 1, &mailosaurError{
 					Message:   "Permanently expired or deleted.",
 					ErrorType: "gone",
 					HttpStatusCode: 410,
@@ -45,10 +76,10 @@ func (s *FilesService) GetPreview(id string) ([]byte, error) {
 				}
 			} else {
 				// Other errors should be returned immediately
-				return nil, err
+				return nil, pollCount + 1, err
 			}
 		} else {
-			return nil, err
+			return nil, pollCount + 1, err
 		}
 
 		delayPattern := "1000"
@@ -80,9 +111,156 @@ func (s *FilesService) GetPreview(id string) ([]byte, error) {
 				Message:   "An email preview was not generated in time. The email client may not be available, or the preview ID [" + id + "] may be incorrect.",
 				ErrorType: "preview_timeout",
 			}
-			return nil, err
+			return nil, pollCount, err
 		}
 
 		time.Sleep(time.Duration(delay) * time.Second)
 	}
 }
+
+type FileDownloadOptions struct {
+	// MaxSize aborts downloads larger than this many bytes. Zero means no limit.
+	MaxSize int64
+}
+
+// FileStream is the body of a downloaded file. Callers must close it.
+type FileStream struct {
+	Body io.ReadCloser
+
+	// ContentLength is -1 when the size is not known in advance.
+	ContentLength int64
+	ContentType   string
+
+	maxSize int64
+	read    int64
+}
+
+func (f *FileStream) Read(p []byte) (int, error) {
+	// Read at most one byte past the limit, so that oversized files are
+	// detected without handing any of the excess to the caller
+	if f.maxSize > 0 {
+		remaining := f.maxSize - f.read + 1
+		if int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+	}
+
+	n, err := f.Body.Read(p)
+	f.read += int64(n)
+
+	if f.maxSize > 0 && f.read > f.maxSize {
+		return n - int(f.read-f.maxSize), fileTooLargeError(f.maxSize)
+	}
+
+	return n, err
+}
+
+func (f *FileStream) Close() error {
+	return f.Body.Close()
+}
+
+type FileDownloadResult struct {
+	ContentLength int64
+	ContentType   string
+	Written       int64
+}
+
+func (s *FilesService) StreamAttachment(id string, options *FileDownloadOptions) (*FileStream, error) {
+	return s.stream("api/files/attachments/"+id, options)
+}
+
+func (s *FilesService) StreamEmail(id string, options *FileDownloadOptions) (*FileStream, error) {
+	return s.stream("api/files/email/"+id, options)
+}
+
+// StreamPreview waits for the preview to be generated, in the same way as
+// GetPreview, then returns its body as a stream.
+func (s *FilesService) StreamPreview(id string, options *FileDownloadOptions) (*FileStream, error) {
+	result, err := s.observePreview(id, 120, func() (interface{}, string, error) {
+		resp, err := s.client.executeStreamRequest("GET", "api/files/screenshots/"+id, nil, 200)
+		if err != nil {
+			return nil, "", err
+		}
+		return resp, resp.Header.Get("x-ms-delay"), nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newFileStream(result.(*http.Response), options)
+}
+
+func (s *FilesService) DownloadAttachment(id string, w io.Writer, options *FileDownloadOptions) (*FileDownloadResult, error) {
+	stream, err := s.StreamAttachment(id, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return copyFileStream(w, stream)
+}
+
+func (s *FilesService) DownloadEmail(id string, w io.Writer, options *FileDownloadOptions) (*FileDownloadResult, error) {
+	stream, err := s.StreamEmail(id, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return copyFileStream(w, stream)
+}
+
+func (s *FilesService) DownloadPreview(id string, w io.Writer, options *FileDownloadOptions) (*FileDownloadResult, error) {
+	stream, err := s.StreamPreview(id, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return copyFileStream(w, stream)
+}
+
+func (s *FilesService) stream(path string, options *FileDownloadOptions) (*FileStream, error) {
+	resp, err := s.client.executeStreamRequest("GET", path, nil, 200)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFileStream(resp, options)
+}
+
+func newFileStream(resp *http.Response, options *FileDownloadOptions) (*FileStream, error) {
+	f := &FileStream{
+		Body:          resp.Body,
+		ContentLength: resp.ContentLength,
+		ContentType:   resp.Header.Get("Content-Type"),
+	}
+
+	if options != nil {
+		f.maxSize = options.MaxSize
+	}
+
+	if f.maxSize > 0 && f.ContentLength > f.maxSize {
+		resp.Body.Close()
+		return nil, fileTooLargeError(f.maxSize)
+	}
+
+	return f, nil
+}
+
+func copyFileStream(w io.Writer, f *FileStream) (*FileDownloadResult, error) {
+	defer f.Close()
+
+	written, err := io.Copy(w, f)
+
+	return &FileDownloadResult{
+		ContentLength: f.ContentLength,
+		ContentType:   f.ContentType,
+		Written:       written,
+	}, err
+}
+
+func fileTooLargeError(maxSize int64) error {
+	return &mailosaurError{
+		Message:   "File exceeds the maximum download size of " + strconv.FormatInt(maxSize, 10) + " bytes.",
+		ErrorType: "file_too_large",
+	}
+}
@@ -1,15 +1,23 @@
 package mailosaur
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func init() {
+	if !live {
+		return
+	}
+
 	baseUrl := os.Getenv("MAILOSAUR_BASE_URL")
 	server = os.Getenv("MAILOSAUR_SERVER")
 
@@ -52,9 +60,140 @@ func init() {
 // }
 
 func TestFilesGetAttachment(t *testing.T) {
+	skipUnlessLive(t)
 	attachment := email.Attachments[0]
 	bytes, _ := client.Files.GetAttachment(attachment.Id)
 
 	assert.True(t, len(bytes) > 1)
 	assert.Equal(t, attachment.Length, len(bytes))
 }
+
+func TestFilesStreamAttachment(t *testing.T) {
+	skipUnlessLive(t)
+	attachment := email.Attachments[0]
+	stream, err := client.Files.StreamAttachment(attachment.Id, nil)
+	assert.NoError(t, err)
+	defer stream.Close()
+
+	bytes, err := io.ReadAll(stream)
+	assert.NoError(t, err)
+	assert.Equal(t, attachment.Length, len(bytes))
+	assert.Equal(t, attachment.ContentType, stream.ContentType)
+}
+
+func TestFilesDownloadAttachment(t *testing.T) {
+	skipUnlessLive(t)
+	attachment := email.Attachments[0]
+
+	var buf bytes.Buffer
+	result, err := client.Files.DownloadAttachment(attachment.Id, &buf, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(attachment.Length), result.Written)
+	assert.Equal(t, attachment.Length, buf.Len())
+}
+
+func TestFilesDownloadAttachmentMaxSize(t *testing.T) {
+	skipUnlessLive(t)
+	attachment := email.Attachments[0]
+
+	var buf bytes.Buffer
+	_, err := client.Files.DownloadAttachment(attachment.Id, &buf, &FileDownloadOptions{MaxSize: 1024})
+	assert.Error(t, err)
+	assert.Equal(t, "file_too_large", err.(*mailosaurError).ErrorType)
+	assert.True(t, buf.Len() <= 1024)
+}
+
+func TestFilesSaveAttachments(t *testing.T) {
+	skipUnlessLive(t)
+	dir := t.TempDir()
+
+	manifest, err := client.Files.SaveAttachments(email, dir, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, len(email.Attachments), len(manifest.Files))
+
+	for i, entry := range manifest.Files {
+		assert.Equal(t, email.Attachments[i].FileName, entry.Path)
+		assert.Equal(t, int64(email.Attachments[i].Length), entry.Size)
+		assert.True(t, entry.ContentTypeMatches)
+		assert.Equal(t, 64, len(entry.Sha256))
+	}
+}
+
+func newFilesTestClient(t *testing.T) *MailosaurClient {
+	content := bytes.Repeat([]byte("x"), 2048)
+
+	return newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/files/attachments/small":
+			w.Header().Set("Content-Type", "image/png")
+			w.Header().Set("Content-Length", "100")
+			w.Write(content[:100])
+		case "/api/files/attachments/large":
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write(content)
+		case "/api/files/email/chunked":
+			// Flushing first sends the body chunked, without a length
+			w.Write(content[:1024])
+			w.(http.Flusher).Flush()
+			w.Write(content[1024:])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestFilesStreamOffline(t *testing.T) {
+	client := newFilesTestClient(t)
+
+	stream, err := client.Files.StreamAttachment("small", &FileDownloadOptions{MaxSize: 100})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), stream.ContentLength)
+	assert.Equal(t, "image/png", stream.ContentType)
+
+	body, err := io.ReadAll(stream)
+	assert.NoError(t, err)
+	assert.Equal(t, 100, len(body))
+	assert.NoError(t, stream.Close())
+
+	var buf bytes.Buffer
+	result, err := client.Files.DownloadEmail("chunked", &buf, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), result.ContentLength)
+	assert.Equal(t, int64(2048), result.Written)
+	assert.Equal(t, 2048, buf.Len())
+
+	_, err = client.Files.StreamAttachment("missing", nil)
+	assert.Equal(t, 404, err.(*mailosaurError).HttpStatusCode)
+}
+
+func TestFilesMaxSizeFromContentLength(t *testing.T) {
+	client := newFilesTestClient(t)
+
+	// Refused before any of the body is read
+	stream, err := client.Files.StreamAttachment("large", &FileDownloadOptions{MaxSize: 1024})
+	assert.Nil(t, stream)
+	assert.Equal(t, "file_too_large", err.(*mailosaurError).ErrorType)
+
+	var buf bytes.Buffer
+	_, err = client.Files.DownloadAttachment("large", &buf, &FileDownloadOptions{MaxSize: 1024})
+	assert.Equal(t, "file_too_large", err.(*mailosaurError).ErrorType)
+	assert.Equal(t, 0, buf.Len())
+}
+
+func TestFilesMaxSizeFromBody(t *testing.T) {
+	client := newFilesTestClient(t)
+
+	// Without a length the limit is enforced while reading
+	stream, err := client.Files.StreamEmail("chunked", &FileDownloadOptions{MaxSize: 1500})
+	assert.NoError(t, err)
+	body, err := io.ReadAll(stream)
+	assert.Equal(t, "file_too_large", err.(*mailosaurError).ErrorType)
+	assert.Equal(t, 1500, len(body))
+	stream.Close()
+
+	var buf bytes.Buffer
+	result, err := client.Files.DownloadEmail("chunked", &buf, &FileDownloadOptions{MaxSize: 1500})
+	assert.Equal(t, "file_too_large", err.(*mailosaurError).ErrorType)
+	assert.Equal(t, int64(1500), result.Written)
+	assert.Equal(t, 1500, buf.Len())
+}
@@ -0,0 +1,439 @@
+// Package qrcode decodes QR codes from images. It is written for the clean
+// codes shown on screen during MFA enrollment: the code may be scaled,
+// rotated or JPEG compressed, but perspective distortion is not corrected.
+package qrcode
+
+import (
+	"errors"
+	"image"
+	"math/bits"
+	"unicode/utf8"
+)
+
+var (
+	ErrNotFound = errors.New("qrcode: no QR code found in the image")
+	errFormat   = errors.New("qrcode: unreadable format information")
+	errVersion  = errors.New("qrcode: unreadable version information")
+	errData     = errors.New("qrcode: malformed data")
+)
+
+// Decode finds a QR code in the image and returns its content.
+func Decode(img image.Image) (string, error) {
+	bm := binarise(img)
+
+	tl, tr, bl, ok := selectCorners(bm.findFinderPatterns())
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	estimate := estimateSize(tl, tr, bl)
+
+	var lastErr error = ErrNotFound
+	for _, size := range []int{estimate, estimate - 4, estimate + 4} {
+		if size < 21 || size > 177 {
+			continue
+		}
+
+		text, err := decodeModules(bm.grid(tl, tr, bl, size))
+		if err == nil {
+			return text, nil
+		}
+		lastErr = err
+	}
+
+	return "", lastErr
+}
+
+func decodeModules(modules [][]bool) (string, error) {
+	size := len(modules)
+	version := (size - 17) / 4
+
+	level, mask, err := readFormat(modules)
+	if err != nil {
+		return "", err
+	}
+
+	if version >= 7 {
+		v, err := readVersion(modules)
+		if err != nil {
+			return "", err
+		}
+		if v != version {
+			return "", errVersion
+		}
+	}
+
+	codewords := readCodewords(modules, version, mask)
+
+	data, err := correctBlocks(codewords, version, level)
+	if err != nil {
+		return "", err
+	}
+
+	return decodeSegments(data, version)
+}
+
+// readFormat reads both copies of the format information and returns the
+// level and mask of the closest valid value.
+func readFormat(modules [][]bool) (int, int, error) {
+	size := len(modules)
+	bit := func(x, y int) uint {
+		if modules[y][x] {
+			return 1
+		}
+		return 0
+	}
+
+	var first, second uint
+	for i := 0; i <= 5; i++ {
+		first |= bit(8, i) << i
+	}
+	first |= bit(8, 7) << 6
+	first |= bit(8, 8) << 7
+	first |= bit(7, 8) << 8
+	for i := 9; i < 15; i++ {
+		first |= bit(14-i, 8) << i
+	}
+
+	for i := 0; i < 8; i++ {
+		second |= bit(size-1-i, 8) << i
+	}
+	for i := 8; i < 15; i++ {
+		second |= bit(8, size-15+i) << i
+	}
+
+	bestDistance := 16
+	bestLevel, bestMask := 0, 0
+	for level := 0; level < 4; level++ {
+		for mask := 0; mask < 8; mask++ {
+			expected := formatBits(level, mask)
+			for _, actual := range []uint{first, second} {
+				if d := bits.OnesCount(expected ^ actual); d < bestDistance {
+					bestDistance, bestLevel, bestMask = d, level, mask
+				}
+			}
+		}
+	}
+
+	if bestDistance > 3 {
+		return 0, 0, errFormat
+	}
+	return bestLevel, bestMask, nil
+}
+
+func readVersion(modules [][]bool) (int, error) {
+	size := len(modules)
+
+	var first, second uint
+	for i := 0; i < 18; i++ {
+		a := size - 11 + i%3
+		b := i / 3
+		if modules[b][a] {
+			first |= 1 << i
+		}
+		if modules[a][b] {
+			second |= 1 << i
+		}
+	}
+
+	bestDistance := 19
+	bestVersion := 0
+	for v := 7; v <= 40; v++ {
+		expected := versionBits(v)
+		for _, actual := range []uint{first, second} {
+			if d := bits.OnesCount(expected ^ actual); d < bestDistance {
+				bestDistance, bestVersion = d, v
+			}
+		}
+	}
+
+	if bestDistance > 3 {
+		return 0, errVersion
+	}
+	return bestVersion, nil
+}
+
+// functionModules marks the modules that hold patterns and format or
+// version information rather than data.
+func functionModules(version int) [][]bool {
+	size := version*4 + 17
+	f := make([][]bool, size)
+	for i := range f {
+		f[i] = make([]bool, size)
+	}
+
+	fill := func(x, y, w, h int) {
+		for dy := 0; dy < h; dy++ {
+			for dx := 0; dx < w; dx++ {
+				f[y+dy][x+dx] = true
+			}
+		}
+	}
+
+	// Finder patterns, separators and format information
+	fill(0, 0, 9, 9)
+	fill(size-8, 0, 8, 9)
+	fill(0, size-8, 9, 8)
+
+	// Timing patterns
+	fill(6, 0, 1, size)
+	fill(0, 6, size, 1)
+
+	positions := alignmentPatternPositions(version)
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			fill(x-2, y-2, 5, 5)
+		}
+	}
+
+	if version >= 7 {
+		fill(size-11, 0, 3, 6)
+		fill(0, size-11, 6, 3)
+	}
+
+	return f
+}
+
+func masked(mask int, x int, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// readCodewords reads the data modules in the zigzag order of the
+// standard, removing the mask.
+func readCodewords(modules [][]bool, version int, mask int) []byte {
+	size := len(modules)
+	function := functionModules(version)
+	result := make([]byte, numRawDataModules(version)/8)
+
+	i := 0
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				upward := (right+1)&2 == 0
+				y := vert
+				if upward {
+					y = size - 1 - vert
+				}
+
+				if function[y][x] || i >= len(result)*8 {
+					continue
+				}
+
+				if modules[y][x] != masked(mask, x, y) {
+					result[i/8] |= 1 << (7 - uint(i%8))
+				}
+				i++
+			}
+		}
+	}
+
+	return result
+}
+
+// correctBlocks de-interleaves the codewords into blocks, corrects each
+// block and returns the data codewords.
+func correctBlocks(codewords []byte, version int, level int) ([]byte, error) {
+	row := tableIndex[level]
+	numBlocks := numErrorCorrectionBlocks[row][version]
+	eccLen := eccCodewordsPerBlock[row][version]
+
+	numShort := numBlocks - len(codewords)%numBlocks
+	shortLen := len(codewords) / numBlocks
+
+	// Every block is laid out with room for the longest, where short blocks
+	// have one less data codeword and skip that slot when interleaved
+	gap := shortLen - eccLen
+	blocks := make([][]byte, numBlocks)
+	for j := range blocks {
+		blocks[j] = make([]byte, shortLen+1)
+	}
+
+	k := 0
+	for i := 0; i <= shortLen; i++ {
+		for j := 0; j < numBlocks; j++ {
+			if i != gap || j >= numShort {
+				blocks[j][i] = codewords[k]
+				k++
+			}
+		}
+	}
+
+	for j := 0; j < numShort; j++ {
+		blocks[j] = append(blocks[j][:gap], blocks[j][gap+1:]...)
+	}
+
+	var data []byte
+	for _, block := range blocks {
+		if err := correct(block, eccLen); err != nil {
+			return nil, err
+		}
+		data = append(data, block[:len(block)-eccLen]...)
+	}
+	return data, nil
+}
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) available() int {
+	return len(r.data)*8 - r.pos
+}
+
+func (r *bitReader) read(n int) (int, bool) {
+	if n > r.available() {
+		return 0, false
+	}
+	v := 0
+	for i := 0; i < n; i++ {
+		v <<= 1
+		if r.data[r.pos/8]&(1<<(7-uint(r.pos%8))) != 0 {
+			v |= 1
+		}
+		r.pos++
+	}
+	return v, true
+}
+
+const alphanumeric = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// decodeSegments decodes numeric, alphanumeric and byte segments. Byte
+// segments are treated as UTF-8, falling back to ISO-8859-1, the default
+// of the standard, when they are not valid UTF-8.
+func decodeSegments(data []byte, version int) (string, error) {
+	r := &bitReader{data: data}
+	var out []byte
+
+	countBits := func(small, medium, large int) int {
+		switch {
+		case version <= 9:
+			return small
+		case version <= 26:
+			return medium
+		default:
+			return large
+		}
+	}
+
+	for r.available() >= 4 {
+		mode, _ := r.read(4)
+
+		switch mode {
+		case 0:
+			return toString(out), nil
+		case 1:
+			count, ok := r.read(countBits(10, 12, 14))
+			if !ok {
+				return "", errData
+			}
+			for ; count >= 3; count -= 3 {
+				v, ok := r.read(10)
+				if !ok || v > 999 {
+					return "", errData
+				}
+				out = append(out, byte('0'+v/100), byte('0'+v/10%10), byte('0'+v%10))
+			}
+			if count == 2 {
+				v, ok := r.read(7)
+				if !ok || v > 99 {
+					return "", errData
+				}
+				out = append(out, byte('0'+v/10), byte('0'+v%10))
+			} else if count == 1 {
+				v, ok := r.read(4)
+				if !ok || v > 9 {
+					return "", errData
+				}
+				out = append(out, byte('0'+v))
+			}
+		case 2:
+			count, ok := r.read(countBits(9, 11, 13))
+			if !ok {
+				return "", errData
+			}
+			for ; count >= 2; count -= 2 {
+				v, ok := r.read(11)
+				if !ok || v >= 45*45 {
+					return "", errData
+				}
+				out = append(out, alphanumeric[v/45], alphanumeric[v%45])
+			}
+			if count == 1 {
+				v, ok := r.read(6)
+				if !ok || v >= 45 {
+					return "", errData
+				}
+				out = append(out, alphanumeric[v])
+			}
+		case 4:
+			count, ok := r.read(countBits(8, 16, 16))
+			if !ok {
+				return "", errData
+			}
+			for i := 0; i < count; i++ {
+				v, ok := r.read(8)
+				if !ok {
+					return "", errData
+				}
+				out = append(out, byte(v))
+			}
+		case 7:
+			// ECI designator, assumed to select UTF-8 or a compatible charset
+			first, ok := r.read(8)
+			if !ok {
+				return "", errData
+			}
+			switch {
+			case first&0x80 == 0:
+			case first&0xC0 == 0x80:
+				_, ok = r.read(8)
+			default:
+				_, ok = r.read(16)
+			}
+			if !ok {
+				return "", errData
+			}
+		default:
+			return "", errData
+		}
+	}
+
+	return toString(out), nil
+}
+
+func toString(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
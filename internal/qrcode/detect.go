@@ -0,0 +1,403 @@
+package qrcode
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// bitmap is a binarised image, where true is a dark pixel.
+type bitmap struct {
+	width, height int
+	bits          []bool
+}
+
+func (b *bitmap) get(x, y int) bool {
+	if x < 0 || y < 0 || x >= b.width || y >= b.height {
+		return false
+	}
+	return b.bits[y*b.width+x]
+}
+
+// binarise converts the image to black and white using Otsu's threshold,
+// which suits rendered and photographed codes with even lighting.
+func binarise(img image.Image) *bitmap {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	lum := make([]uint8, w*h)
+	var histogram [256]int
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, b, a := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			// Composite transparent pixels onto white
+			l := (299*r + 587*g + 114*b) / 1000
+			l = l + (0xffff - a)
+			if l > 0xffff {
+				l = 0xffff
+			}
+			v := uint8(l >> 8)
+			lum[y*w+x] = v
+			histogram[v]++
+		}
+	}
+
+	total := w * h
+	var sum float64
+	for i, c := range histogram {
+		sum += float64(i * c)
+	}
+
+	var sumB, best float64
+	var weightB int
+	threshold := 127
+	for i, c := range histogram {
+		weightB += c
+		if weightB == 0 {
+			continue
+		}
+		weightF := total - weightB
+		if weightF == 0 {
+			break
+		}
+		sumB += float64(i * c)
+		meanB := sumB / float64(weightB)
+		meanF := (sum - sumB) / float64(weightF)
+		between := float64(weightB) * float64(weightF) * (meanB - meanF) * (meanB - meanF)
+		if between > best {
+			best = between
+			threshold = i
+		}
+	}
+
+	bm := &bitmap{width: w, height: h, bits: make([]bool, w*h)}
+	for i, v := range lum {
+		bm.bits[i] = int(v) <= threshold
+	}
+	return bm
+}
+
+type point struct {
+	x, y float64
+}
+
+func distance(a, b point) float64 {
+	return math.Hypot(a.x-b.x, a.y-b.y)
+}
+
+// finderPattern is a candidate position detection pattern, the 7x7 square
+// in three corners of a code.
+type finderPattern struct {
+	point
+	moduleSize float64
+	count      int
+}
+
+// isFinderRatio checks five run lengths against the 1:1:3:1:1 ratio of a
+// finder pattern.
+func isFinderRatio(runs [5]int) bool {
+	total := 0
+	for _, r := range runs {
+		if r == 0 {
+			return false
+		}
+		total += r
+	}
+	if total < 7 {
+		return false
+	}
+
+	module := float64(total) / 7
+	variance := module / 2
+	return math.Abs(module-float64(runs[0])) < variance &&
+		math.Abs(module-float64(runs[1])) < variance &&
+		math.Abs(3*module-float64(runs[2])) < 3*variance &&
+		math.Abs(module-float64(runs[3])) < variance &&
+		math.Abs(module-float64(runs[4])) < variance
+}
+
+// crossCheck counts runs along a line through (x, y) in direction (dx, dy)
+// and returns the centre of the pattern along that line, or NaN if the
+// runs do not look like a finder pattern.
+func (b *bitmap) crossCheck(x, y int, dx, dy int, maxRun int) (float64, float64) {
+	var runs [5]int
+
+	// Walk backwards from the centre through the dark core, the light ring
+	// and the outer dark ring
+	i := 0
+	for b.get(x-i*dx, y-i*dy) {
+		runs[2]++
+		i++
+	}
+	for j := 1; j >= 0; j-- {
+		dark := j == 0
+		for b.inside(x-i*dx, y-i*dy) && b.get(x-i*dx, y-i*dy) == dark && runs[j] <= maxRun {
+			runs[j]++
+			i++
+		}
+		if runs[j] == 0 || runs[j] > maxRun {
+			return math.NaN(), 0
+		}
+	}
+	start := i
+
+	i = 1
+	for b.get(x+i*dx, y+i*dy) {
+		runs[2]++
+		i++
+	}
+	for j := 3; j <= 4; j++ {
+		dark := j == 4
+		for b.inside(x+i*dx, y+i*dy) && b.get(x+i*dx, y+i*dy) == dark && runs[j] <= maxRun {
+			runs[j]++
+			i++
+		}
+		if runs[j] == 0 || runs[j] > maxRun {
+			return math.NaN(), 0
+		}
+	}
+	end := i
+
+	if !isFinderRatio(runs) {
+		return math.NaN(), 0
+	}
+
+	total := 0
+	for _, r := range runs {
+		total += r
+	}
+
+	// The pattern covers offsets -(start-1) to end-1 from (x, y), and the
+	// centre is returned in continuous coordinates
+	centre := float64(end-start)/2 + 0.5
+	return centre, float64(total) / 7
+}
+
+func (b *bitmap) inside(x, y int) bool {
+	return x >= 0 && y >= 0 && x < b.width && y < b.height
+}
+
+// findFinderPatterns scans every row for the 1:1:3:1:1 ratio, confirms
+// candidates vertically and horizontally, and merges nearby hits.
+func (b *bitmap) findFinderPatterns() []*finderPattern {
+	var patterns []*finderPattern
+
+	for y := 0; y < b.height; y++ {
+		var runs [5]int
+		state := 0
+
+		for x := 0; x <= b.width; x++ {
+			dark := x < b.width && b.get(x, y)
+
+			if dark {
+				if state%2 == 1 {
+					state++
+				}
+				runs[state]++
+				continue
+			}
+
+			if state%2 == 1 {
+				runs[state]++
+				continue
+			}
+
+			// A dark run has just ended
+			if state == 0 && runs[0] == 0 {
+				continue
+			}
+			if state < 4 {
+				state++
+				runs[state]++
+				continue
+			}
+
+			if isFinderRatio(runs) {
+				centre := float64(x-runs[4]-runs[3]) - float64(runs[2])/2
+				b.addCandidate(&patterns, int(centre), y, runs)
+			}
+
+			// Keep the last dark and light runs as the start of the next
+			// candidate
+			runs = [5]int{runs[2], runs[3], runs[4], 1, 0}
+			state = 3
+		}
+	}
+
+	// Keep patterns confirmed on several rows
+	var result []*finderPattern
+	for _, p := range patterns {
+		if p.count >= 2 {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func (b *bitmap) addCandidate(patterns *[]*finderPattern, x int, y int, runs [5]int) {
+	total := 0
+	for _, r := range runs {
+		total += r
+	}
+	maxRun := total
+
+	offsetY, moduleY := b.crossCheck(x, y, 0, 1, maxRun)
+	if math.IsNaN(offsetY) {
+		return
+	}
+	cy := float64(y) + offsetY
+
+	offsetX, moduleX := b.crossCheck(x, int(cy), 1, 0, maxRun)
+	if math.IsNaN(offsetX) {
+		return
+	}
+	cx := float64(x) + offsetX
+
+	module := (moduleX + moduleY) / 2
+
+	for _, p := range *patterns {
+		if math.Abs(p.x-cx) <= module && math.Abs(p.y-cy) <= module && math.Abs(p.moduleSize-module) <= module/2+1 {
+			n := float64(p.count)
+			p.x = (p.x*n + cx) / (n + 1)
+			p.y = (p.y*n + cy) / (n + 1)
+			p.moduleSize = (p.moduleSize*n + module) / (n + 1)
+			p.count++
+			return
+		}
+	}
+
+	*patterns = append(*patterns, &finderPattern{point: point{cx, cy}, moduleSize: module, count: 1})
+}
+
+// selectCorners picks the three patterns most likely to belong to one code
+// and orders them as top-left, top-right and bottom-left, allowing for the
+// code to be rotated.
+func selectCorners(patterns []*finderPattern) (tl, tr, bl *finderPattern, ok bool) {
+	if len(patterns) < 3 {
+		return nil, nil, nil, false
+	}
+
+	sort.Slice(patterns, func(i, j int) bool {
+		return patterns[i].count > patterns[j].count
+	})
+
+	// Of the most frequently seen patterns, choose the three whose module
+	// sizes agree best and which form the closest to an isosceles right
+	// triangle
+	candidates := patterns
+	if len(candidates) > 10 {
+		candidates = candidates[:10]
+	}
+
+	bestScore := math.Inf(1)
+	for i := 0; i < len(candidates); i++ {
+		for j := i + 1; j < len(candidates); j++ {
+			for k := j + 1; k < len(candidates); k++ {
+				a, b, c := orderCorners(candidates[i], candidates[j], candidates[k])
+				score := triangleScore(a, b, c)
+				if score < bestScore {
+					bestScore = score
+					tl, tr, bl = a, b, c
+				}
+			}
+		}
+	}
+
+	return tl, tr, bl, bestScore < 0.5
+}
+
+// orderCorners returns the pattern at the right angle first, followed by
+// the other two in clockwise order in image coordinates.
+func orderCorners(a, b, c *finderPattern) (*finderPattern, *finderPattern, *finderPattern) {
+	ab := distance(a.point, b.point)
+	bc := distance(b.point, c.point)
+	ac := distance(a.point, c.point)
+
+	// The corner opposite the longest side is the top-left
+	var tl, p, q *finderPattern
+	switch {
+	case bc >= ab && bc >= ac:
+		tl, p, q = a, b, c
+	case ac >= ab && ac >= bc:
+		tl, p, q = b, a, c
+	default:
+		tl, p, q = c, a, b
+	}
+
+	// With y pointing down, top-right is clockwise from bottom-left
+	cross := (p.x-tl.x)*(q.y-tl.y) - (p.y-tl.y)*(q.x-tl.x)
+	if cross < 0 {
+		p, q = q, p
+	}
+	return tl, p, q
+}
+
+func triangleScore(tl, tr, bl *finderPattern) float64 {
+	sizes := []float64{tl.moduleSize, tr.moduleSize, bl.moduleSize}
+	mean := (sizes[0] + sizes[1] + sizes[2]) / 3
+	var sizeError float64
+	for _, s := range sizes {
+		sizeError += math.Abs(s-mean) / mean
+	}
+
+	d1 := distance(tl.point, tr.point)
+	d2 := distance(tl.point, bl.point)
+	if d1 == 0 || d2 == 0 {
+		return math.Inf(1)
+	}
+	sideError := math.Abs(d1-d2) / math.Max(d1, d2)
+
+	hyp := distance(tr.point, bl.point)
+	angleError := math.Abs(hyp-math.Hypot(d1, d2)) / hyp
+
+	// Patterns must be at least a version 1 code apart
+	if d1/mean < 13 || d2/mean < 13 {
+		return math.Inf(1)
+	}
+
+	return sizeError + sideError + angleError
+}
+
+// grid samples the modules of a code of the given size, using an affine
+// transform from the centres of the three finder patterns.
+func (b *bitmap) grid(tl, tr, bl *finderPattern, size int) [][]bool {
+	span := float64(size - 7)
+	ux, uy := (tr.x-tl.x)/span, (tr.y-tl.y)/span
+	vx, vy := (bl.x-tl.x)/span, (bl.y-tl.y)/span
+
+	modules := make([][]bool, size)
+	for row := 0; row < size; row++ {
+		modules[row] = make([]bool, size)
+		for col := 0; col < size; col++ {
+			// Finder pattern centres are at module 3.5
+			i := float64(col) - 3
+			j := float64(row) - 3
+			x := tl.x + i*ux + j*vx
+			y := tl.y + i*uy + j*vy
+			modules[row][col] = b.get(int(math.Floor(x)), int(math.Floor(y)))
+		}
+	}
+	return modules
+}
+
+// estimateSize returns the number of modules along each side, from the
+// distance between finder patterns.
+func estimateSize(tl, tr, bl *finderPattern) int {
+	// Runs are measured along rows and columns, so they are longer than
+	// the module size when the code is rotated
+	angle := math.Atan2(tr.y-tl.y, tr.x-tl.x)
+	stretch := math.Max(math.Abs(math.Cos(angle)), math.Abs(math.Sin(angle)))
+	module := (tl.moduleSize + tr.moduleSize + bl.moduleSize) / 3 * stretch
+	across := (distance(tl.point, tr.point) + distance(tl.point, bl.point)) / 2
+	size := int(math.Round(across/module)) + 7
+
+	switch size % 4 {
+	case 0:
+		size++
+	case 2:
+		size--
+	case 3:
+		size -= 2
+	}
+	return size
+}
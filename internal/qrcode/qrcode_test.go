@@ -0,0 +1,88 @@
+package qrcode
+
+import (
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readImage(t *testing.T, path string) image.Image {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestDecode(t *testing.T) {
+	text, err := Decode(readImage(t, "testdata/url.png"))
+	assert.NoError(t, err)
+	assert.Equal(t, "https://mailosaur.com/", text)
+}
+
+func TestDecodeRotatedJpeg(t *testing.T) {
+	text, err := Decode(readImage(t, "testdata/version10h-rotated.jpg"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Mailosaur QR code test, version 10 with level H error correction 0123456789", text)
+}
+
+func TestDecodeNotFound(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 100))
+	_, err := Decode(img)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+// encode appends Reed-Solomon error correction codewords to data, in the
+// same way as a QR code encoder.
+func encode(data []byte, eccLen int) []byte {
+	generator := []byte{1}
+	for i := 0; i < eccLen; i++ {
+		next := make([]byte, len(generator)+1)
+		for j, c := range generator {
+			next[j] ^= c
+			next[j+1] ^= gfMul(c, gfExp[i])
+		}
+		generator = next
+	}
+
+	remainder := make([]byte, len(data)+eccLen)
+	copy(remainder, data)
+	for i := range data {
+		factor := remainder[i]
+		for j, c := range generator {
+			remainder[i+j] ^= gfMul(c, factor)
+		}
+	}
+
+	return append(append([]byte{}, data...), remainder[len(data):]...)
+}
+
+func TestCorrect(t *testing.T) {
+	block := encode([]byte("mailosaur reed-solomon"), 10)
+	original := append([]byte{}, block...)
+
+	assert.NoError(t, correct(block, 10))
+	assert.Equal(t, original, block)
+
+	// Up to eccLen/2 errors can be corrected, including in the ECC codewords
+	for _, i := range []int{0, 7, 13, 21, len(block) - 1} {
+		block[i] ^= 0x5A
+	}
+	assert.NoError(t, correct(block, 10))
+	assert.Equal(t, original, block)
+
+	for i := 0; i < 8; i++ {
+		block[i*3] ^= 0xFF
+	}
+	assert.Equal(t, errUncorrectable, correct(block, 10))
+}
@@ -0,0 +1,161 @@
+package qrcode
+
+import "errors"
+
+var errUncorrectable = errors.New("qrcode: too many errors to correct")
+
+// GF(256) with the QR code polynomial x^8 + x^4 + x^3 + x^2 + 1.
+var (
+	gfExp [512]byte
+	gfLog [256]int
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+	for i := 255; i < len(gfExp); i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+gfLog[b]]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[(gfLog[a]+255-gfLog[b])%255]
+}
+
+// polyEval evaluates a polynomial with coefficients in ascending order of
+// degree.
+func polyEval(p []byte, x byte) byte {
+	var result byte
+	for i := len(p) - 1; i >= 0; i-- {
+		result = gfMul(result, x) ^ p[i]
+	}
+	return result
+}
+
+// correct fixes errors in place in a block of data followed by eccLen error
+// correction codewords, using the Berlekamp-Massey algorithm to find the
+// error locator and Forney's algorithm for the error values.
+func correct(block []byte, eccLen int) error {
+	n := len(block)
+
+	// The first codeword is the highest degree coefficient
+	syndromes := make([]byte, eccLen)
+	hasErrors := false
+	for i := 0; i < eccLen; i++ {
+		var s byte
+		for _, c := range block {
+			s = gfMul(s, gfExp[i]) ^ c
+		}
+		syndromes[i] = s
+		if s != 0 {
+			hasErrors = true
+		}
+	}
+	if !hasErrors {
+		return nil
+	}
+
+	// Berlekamp-Massey
+	locator := []byte{1}
+	prev := []byte{1}
+	l := 0
+	m := 1
+	b := byte(1)
+	for i := 0; i < eccLen; i++ {
+		d := syndromes[i]
+		for j := 1; j <= l && j < len(locator); j++ {
+			d ^= gfMul(locator[j], syndromes[i-j])
+		}
+
+		if d == 0 {
+			m++
+			continue
+		}
+
+		coef := gfDiv(d, b)
+		next := make([]byte, maxInt(len(locator), len(prev)+m))
+		copy(next, locator)
+		for j, p := range prev {
+			next[j+m] ^= gfMul(coef, p)
+		}
+
+		if 2*l <= i {
+			prev = locator
+			l = i + 1 - l
+			b = d
+			m = 1
+		} else {
+			m++
+		}
+		locator = next
+	}
+
+	for len(locator) > 1 && locator[len(locator)-1] == 0 {
+		locator = locator[:len(locator)-1]
+	}
+	numErrors := len(locator) - 1
+	if numErrors != l || 2*numErrors > eccLen {
+		return errUncorrectable
+	}
+
+	// Omega(x) = S(x) * Lambda(x) mod x^eccLen
+	omega := make([]byte, eccLen)
+	for i := 0; i < eccLen; i++ {
+		for j := 0; j <= i && j < len(locator); j++ {
+			omega[i] ^= gfMul(locator[j], syndromes[i-j])
+		}
+	}
+
+	// Formal derivative of the locator, where even powers vanish
+	derivative := make([]byte, len(locator))
+	for i := 1; i < len(locator); i += 2 {
+		derivative[i-1] = locator[i]
+	}
+
+	found := 0
+	for k := 0; k < n; k++ {
+		// Position k from the end of the block has locator X = alpha^k
+		xInv := gfExp[(255-k)%255]
+		if polyEval(locator, xInv) != 0 {
+			continue
+		}
+
+		denominator := polyEval(derivative, xInv)
+		if denominator == 0 {
+			return errUncorrectable
+		}
+		x := gfExp[k%255]
+		magnitude := gfMul(x, gfDiv(polyEval(omega, xInv), denominator))
+		block[n-1-k] ^= magnitude
+		found++
+	}
+
+	if found != numErrors {
+		return errUncorrectable
+	}
+	return nil
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,371 @@
+// Package mailosaurassert provides test assertions for messages retrieved
+// with the mailosaur client.
+package mailosaurassert
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mailosaur/mailosaur-go"
+)
+
+func Subject(t testing.TB, message *mailosaur.Message, expected string, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	if !notNil(t, message, msgAndArgs...) {
+		return false
+	}
+
+	if message.Subject != expected {
+		return fail(t, message, "Subject not equal", diff(expected, message.Subject), msgAndArgs...)
+	}
+
+	return true
+}
+
+func SubjectContains(t testing.TB, message *mailosaur.Message, substr string, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	if !notNil(t, message, msgAndArgs...) {
+		return false
+	}
+
+	if !strings.Contains(message.Subject, substr) {
+		return fail(t, message, fmt.Sprintf("Subject %q does not contain %q", message.Subject, substr), "", msgAndArgs...)
+	}
+
+	return true
+}
+
+func SentFrom(t testing.TB, message *mailosaur.Message, email string, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	if !notNil(t, message, msgAndArgs...) {
+		return false
+	}
+
+	if !containsAddress(message.From, email) {
+		return fail(t, message, fmt.Sprintf("Not sent from %q, senders were %s", email, formatAddresses(message.From)), "", msgAndArgs...)
+	}
+
+	return true
+}
+
+// SentTo checks To, Cc and Bcc recipients for the given email address or
+// phone number.
+func SentTo(t testing.TB, message *mailosaur.Message, recipient string, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	if !notNil(t, message, msgAndArgs...) {
+		return false
+	}
+
+	if containsAddress(message.To, recipient) || containsAddress(message.Cc, recipient) || containsAddress(message.Bcc, recipient) {
+		return true
+	}
+
+	var all []*mailosaur.MessageAddress
+	all = append(all, message.To...)
+	all = append(all, message.Cc...)
+	all = append(all, message.Bcc...)
+
+	return fail(t, message, fmt.Sprintf("Not sent to %q, recipients were %s", recipient, formatAddresses(all)), "", msgAndArgs...)
+}
+
+func HasHeader(t testing.TB, message *mailosaur.Message, field string, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	if !notNil(t, message, msgAndArgs...) {
+		return false
+	}
+
+	if len(headerValues(message, field)) == 0 {
+		return fail(t, message, fmt.Sprintf("Header %q not present", field), "", msgAndArgs...)
+	}
+
+	return true
+}
+
+// HeaderEquals passes if any header with the given field name (compared
+// case-insensitively) has exactly the expected value.
+func HeaderEquals(t testing.TB, message *mailosaur.Message, field string, expected string, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	if !notNil(t, message, msgAndArgs...) {
+		return false
+	}
+
+	values := headerValues(message, field)
+	if len(values) == 0 {
+		return fail(t, message, fmt.Sprintf("Header %q not present", field), "", msgAndArgs...)
+	}
+
+	for _, v := range values {
+		if v == expected {
+			return true
+		}
+	}
+
+	return fail(t, message, fmt.Sprintf("Header %q not equal", field), diff(expected, strings.Join(values, "\n")), msgAndArgs...)
+}
+
+func HasAttachment(t testing.TB, message *mailosaur.Message, fileName string, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	if !notNil(t, message, msgAndArgs...) {
+		return false
+	}
+
+	if findAttachment(message, fileName) == nil {
+		return fail(t, message, fmt.Sprintf("No attachment named %q", fileName), "", msgAndArgs...)
+	}
+
+	return true
+}
+
+// Attachment checks the content type and length of the named attachment. An
+// empty contentType or a negative length skips that part of the check.
+func Attachment(t testing.TB, message *mailosaur.Message, fileName string, contentType string, length int, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	if !notNil(t, message, msgAndArgs...) {
+		return false
+	}
+
+	a := findAttachment(message, fileName)
+	if a == nil {
+		return fail(t, message, fmt.Sprintf("No attachment named %q", fileName), "", msgAndArgs...)
+	}
+
+	if len(contentType) > 0 && !strings.EqualFold(a.ContentType, contentType) {
+		return fail(t, message, fmt.Sprintf("Attachment %q content type not equal", fileName), diff(contentType, a.ContentType), msgAndArgs...)
+	}
+
+	if length >= 0 && a.Length != length {
+		return fail(t, message, fmt.Sprintf("Attachment %q length not equal", fileName), diff(fmt.Sprint(length), fmt.Sprint(a.Length)), msgAndArgs...)
+	}
+
+	return true
+}
+
+// HasLink checks both the HTML and text content for a link with the given
+// href.
+func HasLink(t testing.TB, message *mailosaur.Message, href string, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	if !notNil(t, message, msgAndArgs...) {
+		return false
+	}
+
+	for _, l := range links(message) {
+		if l.Href == href {
+			return true
+		}
+	}
+
+	return fail(t, message, fmt.Sprintf("No link with href %q", href), "", msgAndArgs...)
+}
+
+func HasLinkToHost(t testing.TB, message *mailosaur.Message, host string, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	if !notNil(t, message, msgAndArgs...) {
+		return false
+	}
+
+	for _, l := range links(message) {
+		u, err := url.Parse(l.Href)
+		if err == nil && strings.EqualFold(u.Hostname(), host) {
+			return true
+		}
+	}
+
+	return fail(t, message, fmt.Sprintf("No link to host %q", host), "", msgAndArgs...)
+}
+
+func HasCode(t testing.TB, message *mailosaur.Message, code string, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	if !notNil(t, message, msgAndArgs...) {
+		return false
+	}
+
+	for _, c := range codes(message) {
+		if c.Value == code {
+			return true
+		}
+	}
+
+	return fail(t, message, fmt.Sprintf("Code %q was not extracted", code), "", msgAndArgs...)
+}
+
+// Code returns the first code extracted from the message, failing the test
+// if there is none.
+func Code(t testing.TB, message *mailosaur.Message, msgAndArgs ...interface{}) string {
+	t.Helper()
+	if !notNil(t, message, msgAndArgs...) {
+		return ""
+	}
+
+	c := codes(message)
+	if len(c) == 0 {
+		fail(t, message, "No codes were extracted", "", msgAndArgs...)
+		return ""
+	}
+
+	return c[0].Value
+}
+
+// BodyContains checks both the text and HTML bodies for the substring.
+func BodyContains(t testing.TB, message *mailosaur.Message, substr string, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	if !notNil(t, message, msgAndArgs...) {
+		return false
+	}
+
+	for _, b := range bodies(message) {
+		if strings.Contains(b, substr) {
+			return true
+		}
+	}
+
+	return fail(t, message, fmt.Sprintf("Body does not contain %q", substr), "", msgAndArgs...)
+}
+
+// BodyMatches checks both the text and HTML bodies against the regular
+// expression.
+func BodyMatches(t testing.TB, message *mailosaur.Message, pattern string, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	if !notNil(t, message, msgAndArgs...) {
+		return false
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fail(t, message, fmt.Sprintf("Invalid pattern %q: %s", pattern, err), "", msgAndArgs...)
+	}
+
+	for _, b := range bodies(message) {
+		if re.MatchString(b) {
+			return true
+		}
+	}
+
+	return fail(t, message, fmt.Sprintf("Body does not match %q", pattern), "", msgAndArgs...)
+}
+
+func notNil(t testing.TB, message *mailosaur.Message, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	if message == nil {
+		return fail(t, nil, "Expected a message but got nil", "", msgAndArgs...)
+	}
+	return true
+}
+
+func fail(t testing.TB, message *mailosaur.Message, failure string, details string, msgAndArgs ...interface{}) bool {
+	t.Helper()
+
+	var sb strings.Builder
+	sb.WriteString(failure)
+	if len(details) > 0 {
+		sb.WriteString("\n")
+		sb.WriteString(details)
+	}
+	if m := messageFromMsgAndArgs(msgAndArgs...); len(m) > 0 {
+		sb.WriteString("\nMessages: ")
+		sb.WriteString(m)
+	}
+	if message != nil {
+		sb.WriteString("\n")
+		sb.WriteString(Dump(message))
+	}
+
+	t.Errorf("%s", sb.String())
+	return false
+}
+
+func diff(expected string, actual string) string {
+	return fmt.Sprintf("expected: %q\nactual  : %q", expected, actual)
+}
+
+func messageFromMsgAndArgs(msgAndArgs ...interface{}) string {
+	if len(msgAndArgs) == 0 {
+		return ""
+	}
+	if len(msgAndArgs) == 1 {
+		if s, ok := msgAndArgs[0].(string); ok {
+			return s
+		}
+		return fmt.Sprintf("%+v", msgAndArgs[0])
+	}
+	if format, ok := msgAndArgs[0].(string); ok {
+		return fmt.Sprintf(format, msgAndArgs[1:]...)
+	}
+	return ""
+}
+
+func containsAddress(addresses []*mailosaur.MessageAddress, value string) bool {
+	for _, a := range addresses {
+		if a == nil {
+			continue
+		}
+		if strings.EqualFold(a.Email, value) || (len(a.Phone) > 0 && a.Phone == value) {
+			return true
+		}
+	}
+	return false
+}
+
+func headerValues(message *mailosaur.Message, field string) []string {
+	var values []string
+	if message.Metadata == nil {
+		return values
+	}
+	for _, h := range message.Metadata.Headers {
+		if h != nil && strings.EqualFold(h.Field, field) {
+			values = append(values, h.Value)
+		}
+	}
+	return values
+}
+
+func findAttachment(message *mailosaur.Message, fileName string) *mailosaur.Attachment {
+	for _, a := range message.Attachments {
+		if a != nil && a.FileName == fileName {
+			return a
+		}
+	}
+	return nil
+}
+
+func links(message *mailosaur.Message) []*mailosaur.Link {
+	var result []*mailosaur.Link
+	for _, c := range []*mailosaur.MessageContent{message.Html, message.Text} {
+		if c == nil {
+			continue
+		}
+		for _, l := range c.Links {
+			if l != nil {
+				result = append(result, l)
+			}
+		}
+	}
+	return result
+}
+
+func codes(message *mailosaur.Message) []*mailosaur.Code {
+	var result []*mailosaur.Code
+	for _, c := range []*mailosaur.MessageContent{message.Html, message.Text} {
+		if c == nil {
+			continue
+		}
+		for _, code := range c.Codes {
+			if code != nil {
+				result = append(result, code)
+			}
+		}
+	}
+	return result
+}
+
+func bodies(message *mailosaur.Message) []string {
+	var result []string
+	for _, c := range []*mailosaur.MessageContent{message.Text, message.Html} {
+		if c != nil {
+			result = append(result, c.Body)
+		}
+	}
+	return result
+}
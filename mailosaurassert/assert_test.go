@@ -0,0 +1,140 @@
+package mailosaurassert
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mailosaur/mailosaur-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockT struct {
+	testing.TB
+	errors []string
+}
+
+func (m *mockT) Helper() {}
+
+func (m *mockT) Errorf(format string, args ...interface{}) {
+	m.errors = append(m.errors, fmt.Sprintf(format, args...))
+}
+
+func testMessage() *mailosaur.Message {
+	return &mailosaur.Message{
+		Id:       "a1b2c3",
+		Type:     "Email",
+		Server:   "abcd1234",
+		Received: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+		Subject:  "Reset your password",
+		From:     []*mailosaur.MessageAddress{{Name: "Acme", Email: "noreply@acme.example"}},
+		To:       []*mailosaur.MessageAddress{{Name: "Jo", Email: "jo@abcd1234.mailosaur.net"}},
+		Cc:       []*mailosaur.MessageAddress{{Email: "cc@abcd1234.mailosaur.net"}},
+		Html: &mailosaur.MessageContent{
+			Body:  "<p>Your code is 123456</p><a href=\"https://acme.example/reset?t=abc\">Reset</a>",
+			Links: []*mailosaur.Link{{Href: "https://acme.example/reset?t=abc", Text: "Reset"}},
+			Codes: []*mailosaur.Code{{Value: "123456"}},
+		},
+		Text: &mailosaur.MessageContent{
+			Body:  "Your code is 123456",
+			Codes: []*mailosaur.Code{{Value: "123456"}},
+		},
+		Attachments: []*mailosaur.Attachment{
+			{FileName: "cat.png", ContentType: "image/png", Length: 82138},
+		},
+		Metadata: &mailosaur.Metadata{
+			Headers: []*mailosaur.MessageHeader{
+				{Field: "List-Unsubscribe", Value: "<mailto:unsub@acme.example>"},
+			},
+		},
+	}
+}
+
+func TestPassingAssertions(t *testing.T) {
+	m := &mockT{}
+	message := testMessage()
+
+	assert.True(t, Subject(m, message, "Reset your password"))
+	assert.True(t, SubjectContains(m, message, "password"))
+	assert.True(t, SentFrom(m, message, "NOREPLY@acme.example"))
+	assert.True(t, SentTo(m, message, "jo@abcd1234.mailosaur.net"))
+	assert.True(t, SentTo(m, message, "cc@abcd1234.mailosaur.net"))
+	assert.True(t, HasHeader(m, message, "list-unsubscribe"))
+	assert.True(t, HeaderEquals(m, message, "List-Unsubscribe", "<mailto:unsub@acme.example>"))
+	assert.True(t, HasAttachment(m, message, "cat.png"))
+	assert.True(t, Attachment(m, message, "cat.png", "image/png", 82138))
+	assert.True(t, HasLink(m, message, "https://acme.example/reset?t=abc"))
+	assert.True(t, HasLinkToHost(m, message, "acme.example"))
+	assert.True(t, HasCode(m, message, "123456"))
+	assert.Equal(t, "123456", Code(m, message))
+	assert.True(t, BodyContains(m, message, "Your code"))
+	assert.True(t, BodyMatches(m, message, `code is \d{6}`))
+
+	assert.Empty(t, m.errors)
+}
+
+func TestFailingAssertions(t *testing.T) {
+	message := testMessage()
+
+	cases := map[string]func(testing.TB) bool{
+		"Subject":         func(tb testing.TB) bool { return Subject(tb, message, "Welcome") },
+		"SubjectContains": func(tb testing.TB) bool { return SubjectContains(tb, message, "Welcome") },
+		"SentFrom":        func(tb testing.TB) bool { return SentFrom(tb, message, "other@acme.example") },
+		"SentTo":          func(tb testing.TB) bool { return SentTo(tb, message, "other@acme.example") },
+		"HasHeader":       func(tb testing.TB) bool { return HasHeader(tb, message, "X-Missing") },
+		"HeaderEquals":    func(tb testing.TB) bool { return HeaderEquals(tb, message, "List-Unsubscribe", "nope") },
+		"HasAttachment":   func(tb testing.TB) bool { return HasAttachment(tb, message, "dog.png") },
+		"Attachment":      func(tb testing.TB) bool { return Attachment(tb, message, "cat.png", "image/jpeg", -1) },
+		"HasLink":         func(tb testing.TB) bool { return HasLink(tb, message, "https://acme.example/") },
+		"HasLinkToHost":   func(tb testing.TB) bool { return HasLinkToHost(tb, message, "evil.example") },
+		"HasCode":         func(tb testing.TB) bool { return HasCode(tb, message, "654321") },
+		"BodyContains":    func(tb testing.TB) bool { return BodyContains(tb, message, "Welcome") },
+		"BodyMatches":     func(tb testing.TB) bool { return BodyMatches(tb, message, `^\d+$`) },
+	}
+
+	for name, fn := range cases {
+		m := &mockT{}
+		assert.False(t, fn(m), name)
+		assert.Equal(t, 1, len(m.errors), name)
+		assert.Contains(t, m.errors[0], "Id       : a1b2c3", name)
+	}
+}
+
+func TestFailureShowsExpectedAndActual(t *testing.T) {
+	m := &mockT{}
+	Subject(m, testMessage(), "Welcome", "checking %s", "subject")
+
+	assert.Equal(t, 1, len(m.errors))
+	assert.Contains(t, m.errors[0], `expected: "Welcome"`)
+	assert.Contains(t, m.errors[0], `actual  : "Reset your password"`)
+	assert.Contains(t, m.errors[0], "Messages: checking subject")
+}
+
+func TestNilMessage(t *testing.T) {
+	m := &mockT{}
+	assert.False(t, Subject(m, nil, "Welcome"))
+	assert.Equal(t, "", Code(m, nil))
+	assert.Equal(t, 2, len(m.errors))
+}
+
+func TestCodeWithoutCodes(t *testing.T) {
+	m := &mockT{}
+	message := testMessage()
+	message.Html.Codes = nil
+	message.Text.Codes = nil
+
+	assert.Equal(t, "", Code(m, message))
+	assert.Equal(t, 1, len(m.errors))
+}
+
+func TestDump(t *testing.T) {
+	dump := Dump(testMessage())
+
+	assert.True(t, strings.HasPrefix(dump, "Message:\n"))
+	assert.Contains(t, dump, `Subject  : "Reset your password"`)
+	assert.Contains(t, dump, "From     : [Acme <noreply@acme.example>]")
+	assert.Contains(t, dump, "Attachment: cat.png (image/png, 82138 bytes)")
+	assert.Contains(t, dump, "Link     : https://acme.example/reset?t=abc")
+	assert.Contains(t, dump, "Headers  : List-Unsubscribe")
+}
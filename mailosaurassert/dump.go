@@ -0,0 +1,94 @@
+package mailosaurassert
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mailosaur/mailosaur-go"
+)
+
+const dumpBodyLength = 200
+
+// Dump returns a compact, human readable summary of a message, used in
+// assertion failures.
+func Dump(message *mailosaur.Message) string {
+	if message == nil {
+		return "Message: <nil>"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Message:\n")
+	fmt.Fprintf(&sb, "  Id       : %s\n", message.Id)
+	fmt.Fprintf(&sb, "  Type     : %s\n", message.Type)
+	fmt.Fprintf(&sb, "  Server   : %s\n", message.Server)
+	fmt.Fprintf(&sb, "  Received : %s\n", message.Received.Format(time.RFC3339))
+	fmt.Fprintf(&sb, "  Subject  : %q\n", message.Subject)
+	fmt.Fprintf(&sb, "  From     : %s\n", formatAddresses(message.From))
+	fmt.Fprintf(&sb, "  To       : %s\n", formatAddresses(message.To))
+	if len(message.Cc) > 0 {
+		fmt.Fprintf(&sb, "  Cc       : %s\n", formatAddresses(message.Cc))
+	}
+	if len(message.Bcc) > 0 {
+		fmt.Fprintf(&sb, "  Bcc      : %s\n", formatAddresses(message.Bcc))
+	}
+
+	if message.Metadata != nil && len(message.Metadata.Headers) > 0 {
+		fields := make([]string, 0, len(message.Metadata.Headers))
+		for _, h := range message.Metadata.Headers {
+			if h != nil {
+				fields = append(fields, h.Field)
+			}
+		}
+		fmt.Fprintf(&sb, "  Headers  : %s\n", strings.Join(fields, ", "))
+	}
+
+	for _, a := range message.Attachments {
+		if a != nil {
+			fmt.Fprintf(&sb, "  Attachment: %s (%s, %d bytes)\n", a.FileName, a.ContentType, a.Length)
+		}
+	}
+
+	for _, l := range links(message) {
+		fmt.Fprintf(&sb, "  Link     : %s\n", l.Href)
+	}
+
+	for _, c := range codes(message) {
+		fmt.Fprintf(&sb, "  Code     : %s\n", c.Value)
+	}
+
+	if message.Text != nil {
+		fmt.Fprintf(&sb, "  Text     : %q\n", truncate(message.Text.Body, dumpBodyLength))
+	}
+	if message.Html != nil {
+		fmt.Fprintf(&sb, "  Html     : %q\n", truncate(message.Html.Body, dumpBodyLength))
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func formatAddresses(addresses []*mailosaur.MessageAddress) string {
+	parts := make([]string, 0, len(addresses))
+	for _, a := range addresses {
+		if a == nil {
+			continue
+		}
+		value := a.Email
+		if len(value) == 0 {
+			value = a.Phone
+		}
+		if len(a.Name) > 0 {
+			value = fmt.Sprintf("%s <%s>", a.Name, value)
+		}
+		parts = append(parts, value)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "…"
+}
@@ -0,0 +1,306 @@
+package mailosaurcatcher
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mailosaur/mailosaur-go"
+)
+
+const defaultItemsPerPage = 50
+
+// ServeHTTP serves the parts of the Mailosaur API that read messages:
+// listing, searching, getting and deleting messages, and downloading the
+// raw email and attachments. Any API key is accepted.
+func (c *Catcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+
+	switch {
+	case path == "api/messages" && r.Method == "GET":
+		c.serveList(w, r)
+	case path == "api/messages" && r.Method == "DELETE":
+		c.serveDeleteAll(w, r)
+	case path == "api/messages/search" && r.Method == "POST":
+		c.serveSearch(w, r)
+	case strings.HasPrefix(path, "api/messages/") && r.Method == "GET":
+		c.serveMessage(w, strings.TrimPrefix(path, "api/messages/"))
+	case strings.HasPrefix(path, "api/messages/") && r.Method == "DELETE":
+		c.serveDelete(w, strings.TrimPrefix(path, "api/messages/"))
+	case strings.HasPrefix(path, "api/files/email/") && r.Method == "GET":
+		c.serveEmail(w, strings.TrimPrefix(path, "api/files/email/"))
+	case strings.HasPrefix(path, "api/files/attachments/") && r.Method == "GET":
+		c.serveAttachment(w, strings.TrimPrefix(path, "api/files/attachments/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (c *Catcher) serveList(w http.ResponseWriter, r *http.Request) {
+	messages, ok := c.listForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	writeSummaries(w, r, messages)
+}
+
+func (c *Catcher) serveSearch(w http.ResponseWriter, r *http.Request) {
+	messages, ok := c.listForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	criteria := &mailosaur.SearchCriteria{}
+	if err := json.NewDecoder(r.Body).Decode(criteria); err != nil {
+		writeError(w, "criteria", err.Error())
+		return
+	}
+
+	var matched []*mailosaur.Message
+	for _, m := range messages {
+		if criteria.Matches(m) {
+			matched = append(matched, m)
+		}
+	}
+
+	// Poll again after a second when there are no matches yet
+	w.Header().Set("x-ms-delay", "1000")
+	writeSummaries(w, r, matched)
+}
+
+// listForRequest returns the parsed messages of the server in the query,
+// filtered by receivedAfter.
+func (c *Catcher) listForRequest(w http.ResponseWriter, r *http.Request) ([]*mailosaur.Message, bool) {
+	q := r.URL.Query()
+
+	server := strings.ToLower(q.Get("server"))
+	if len(server) == 0 {
+		writeError(w, "server", "A server must be specified.")
+		return nil, false
+	}
+
+	var receivedAfter time.Time
+	if value := q.Get("receivedAfter"); len(value) > 0 {
+		var err error
+		receivedAfter, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeError(w, "receivedAfter", "Must be an RFC 3339 date.")
+			return nil, false
+		}
+	}
+
+	stored, err := c.store.List(server)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	var messages []*mailosaur.Message
+	for _, s := range stored {
+		// The query is only accurate to the second
+		if s.Received.Before(receivedAfter.Truncate(time.Second)) {
+			continue
+		}
+
+		m, err := toMessage(s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return nil, false
+		}
+		messages = append(messages, m)
+	}
+
+	return messages, true
+}
+
+func (c *Catcher) serveDeleteAll(w http.ResponseWriter, r *http.Request) {
+	server := strings.ToLower(r.URL.Query().Get("server"))
+	if len(server) == 0 {
+		writeError(w, "server", "A server must be specified.")
+		return
+	}
+
+	if err := c.store.DeleteAll(server); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *Catcher) serveMessage(w http.ResponseWriter, id string) {
+	stored, ok := c.get(w, id)
+	if !ok {
+		return
+	}
+
+	m, err := toMessage(stored)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// As with the API, attachment content is downloaded separately
+	for _, a := range m.Attachments {
+		a.Content = ""
+	}
+
+	writeJSON(w, m)
+}
+
+func (c *Catcher) serveDelete(w http.ResponseWriter, id string) {
+	if err := c.store.Delete(id); err == ErrNotFound {
+		http.NotFound(w, nil)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *Catcher) serveEmail(w http.ResponseWriter, id string) {
+	stored, ok := c.get(w, id)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "message/rfc822")
+	w.Write(stored.Raw)
+}
+
+func (c *Catcher) serveAttachment(w http.ResponseWriter, id string) {
+	dash := strings.LastIndex(id, "-")
+	index, err := strconv.Atoi(id[dash+1:])
+	if dash <= 0 || err != nil {
+		http.NotFound(w, nil)
+		return
+	}
+
+	stored, ok := c.get(w, id[:dash])
+	if !ok {
+		return
+	}
+
+	m, err := toMessage(stored)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if index < 0 || index >= len(m.Attachments) {
+		http.NotFound(w, nil)
+		return
+	}
+
+	content, err := base64.StdEncoding.DecodeString(m.Attachments[index].Content)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", m.Attachments[index].ContentType)
+	w.Write(content)
+}
+
+func (c *Catcher) get(w http.ResponseWriter, id string) (*StoredMessage, bool) {
+	stored, err := c.store.Get(id)
+	if err == ErrNotFound {
+		http.NotFound(w, nil)
+		return nil, false
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return stored, true
+}
+
+// toMessage parses a stored message, using the envelope for the fields
+// that the API takes from the SMTP session.
+func toMessage(s *StoredMessage) (*mailosaur.Message, error) {
+	m, err := mailosaur.ParseEmail(s.Raw)
+	if err != nil {
+		return nil, err
+	}
+
+	m.Id = s.Id
+	m.Server = s.Server
+	m.Received = s.Received
+	m.Metadata.MailFrom = s.MailFrom
+	m.Metadata.Ehlo = s.Ehlo
+	for _, rcpt := range s.RcptTo {
+		m.Metadata.RcptTo = append(m.Metadata.RcptTo, &mailosaur.MessageAddress{Email: rcpt})
+	}
+
+	for i, a := range m.Attachments {
+		a.Id = s.Id + "-" + strconv.Itoa(i)
+	}
+
+	return m, nil
+}
+
+func writeSummaries(w http.ResponseWriter, r *http.Request, messages []*mailosaur.Message) {
+	q := r.URL.Query()
+
+	// Newest first, unless asked otherwise
+	sort.SliceStable(messages, func(i, j int) bool {
+		if strings.EqualFold(q.Get("dir"), "asc") {
+			return messages[i].Received.Before(messages[j].Received)
+		}
+		return messages[i].Received.After(messages[j].Received)
+	})
+
+	page, _ := strconv.Atoi(q.Get("page"))
+	itemsPerPage, _ := strconv.Atoi(q.Get("itemsPerPage"))
+	if itemsPerPage <= 0 {
+		itemsPerPage = defaultItemsPerPage
+	}
+
+	result := &mailosaur.MessageListResult{Items: []*mailosaur.MessageSummary{}}
+	for i := page * itemsPerPage; i >= 0 && i < len(messages) && i < (page+1)*itemsPerPage; i++ {
+		result.Items = append(result.Items, toSummary(messages[i]))
+	}
+
+	writeJSON(w, result)
+}
+
+func toSummary(m *mailosaur.Message) *mailosaur.MessageSummary {
+	summary := ""
+	if m.Text != nil {
+		summary = strings.Join(strings.Fields(m.Text.Body), " ")
+		if runes := []rune(summary); len(runes) > 140 {
+			summary = string(runes[:140])
+		}
+	}
+
+	return &mailosaur.MessageSummary{
+		Id:          m.Id,
+		Type:        m.Type,
+		Server:      m.Server,
+		From:        m.From,
+		To:          m.To,
+		Cc:          m.Cc,
+		Bcc:         m.Bcc,
+		Received:    m.Received,
+		Subject:     m.Subject,
+		Summary:     summary,
+		Attachments: len(m.Attachments),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, field string, description string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(&mailosaur.ErrorResponse{
+		Errors: []mailosaur.Error{{Field: field, Detail: []mailosaur.ErrorDetail{{Description: description}}}},
+	})
+}
@@ -0,0 +1,200 @@
+// Package mailosaurcatcher is an embeddable SMTP server that stands in for
+// Mailosaur during offline development. It accepts mail for any address in
+// the form anything@{serverId}.{host}, and serves it over HTTP using the
+// same JSON as the Mailosaur API, so that a client pointed at it with
+// SetBaseUrl works unchanged:
+//
+//	catcher := mailosaurcatcher.New(nil)
+//	go catcher.ListenAndServe("127.0.0.1:2525")
+//	go http.ListenAndServe("127.0.0.1:8025", catcher)
+//
+//	client := mailosaur.New("any key")
+//	client.SetBaseUrl("http://127.0.0.1:8025/")
+package mailosaurcatcher
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Options struct {
+	// Host is the domain that server addresses belong to. It defaults to
+	// mailosaur.net, matching ServersService.GenerateEmailAddress.
+	Host string
+	// Hostname is announced in the SMTP greeting. It defaults to the host.
+	Hostname string
+	// Store defaults to NewMemoryStore.
+	Store Store
+	// MaxSize is the largest message accepted, in bytes. It defaults to
+	// 10MB.
+	MaxSize int
+}
+
+type Catcher struct {
+	host     string
+	hostname string
+	store    Store
+	maxSize  int
+
+	mu        sync.Mutex
+	listeners []net.Listener
+	closed    bool
+}
+
+func New(options *Options) *Catcher {
+	if options == nil {
+		options = &Options{}
+	}
+
+	c := &Catcher{
+		host:     strings.ToLower(options.Host),
+		hostname: options.Hostname,
+		store:    options.Store,
+		maxSize:  options.MaxSize,
+	}
+
+	if len(c.host) == 0 {
+		c.host = "mailosaur.net"
+	}
+	if len(c.hostname) == 0 {
+		c.hostname = c.host
+	}
+	if c.store == nil {
+		c.store = NewMemoryStore()
+	}
+	if c.maxSize <= 0 {
+		c.maxSize = 10 * 1024 * 1024
+	}
+
+	return c
+}
+
+// Store returns the store that received messages are kept in.
+func (c *Catcher) Store() Store {
+	return c.store
+}
+
+// ServerFor returns the server ID an address delivers to, or false if the
+// address is not in the form anything@{serverId}.{host}.
+func (c *Catcher) ServerFor(address string) (string, bool) {
+	at := strings.LastIndex(address, "@")
+	if at <= 0 {
+		return "", false
+	}
+
+	domain := strings.ToLower(address[at+1:])
+	server := strings.TrimSuffix(domain, "."+c.host)
+	if server == domain || !validName(server) {
+		return "", false
+	}
+	return server, true
+}
+
+// Deliver stores a message as if it had been received over SMTP. A copy
+// is stored for each server among the recipients.
+func (c *Catcher) Deliver(mailFrom string, rcptTo []string, raw []byte) ([]*StoredMessage, error) {
+	return c.deliver("", mailFrom, rcptTo, raw)
+}
+
+func (c *Catcher) deliver(ehlo string, mailFrom string, rcptTo []string, raw []byte) ([]*StoredMessage, error) {
+	// Recipients are grouped by server, keeping their order
+	var servers []string
+	recipients := map[string][]string{}
+	for _, rcpt := range rcptTo {
+		server, ok := c.ServerFor(rcpt)
+		if !ok {
+			return nil, errors.New("mailosaurcatcher: " + rcpt + " is not an address for any server")
+		}
+		if _, seen := recipients[server]; !seen {
+			servers = append(servers, server)
+		}
+		recipients[server] = append(recipients[server], rcpt)
+	}
+
+	received := time.Now().UTC()
+
+	var stored []*StoredMessage
+	for _, server := range servers {
+		m := &StoredMessage{
+			Id:       newId(),
+			Server:   server,
+			Received: received,
+			Ehlo:     ehlo,
+			MailFrom: mailFrom,
+			RcptTo:   recipients[server],
+			Raw:      raw,
+		}
+		if err := c.store.Add(m); err != nil {
+			return stored, err
+		}
+		stored = append(stored, m)
+	}
+
+	return stored, nil
+}
+
+// ListenAndServe accepts SMTP connections on addr until Close is called.
+func (c *Catcher) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return c.Serve(l)
+}
+
+// Serve accepts SMTP connections on the listener until Close is called.
+func (c *Catcher) Serve(l net.Listener) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		l.Close()
+		return ErrClosed
+	}
+	c.listeners = append(c.listeners, l)
+	c.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			c.mu.Lock()
+			closed := c.closed
+			c.mu.Unlock()
+			if closed {
+				return ErrClosed
+			}
+			return err
+		}
+
+		go c.serveSMTP(conn)
+	}
+}
+
+// Close stops accepting SMTP connections. Stored messages are kept.
+func (c *Catcher) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	var err error
+	for _, l := range c.listeners {
+		if closeErr := l.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	c.listeners = nil
+	return err
+}
+
+// ErrClosed is returned by Serve and ListenAndServe after Close.
+var ErrClosed = errors.New("mailosaurcatcher: closed")
+
+func newId() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
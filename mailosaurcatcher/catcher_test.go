@@ -0,0 +1,197 @@
+package mailosaurcatcher
+
+import (
+	"net"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mailosaur/mailosaur-go"
+	"github.com/stretchr/testify/assert"
+)
+
+const welcomeEmail = "From: ACME <noreply@example.com>\r\n" +
+	"To: jo@abc123.mailosaur.net\r\n" +
+	"Subject: Welcome to ACME\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"b1\"\r\n" +
+	"\r\n" +
+	"--b1\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"Your code is 123456.\r\n" +
+	".Lines starting with a dot survive.\r\n" +
+	"--b1\r\n" +
+	"Content-Type: text/plain; name=\"notes.txt\"\r\n" +
+	"Content-Disposition: attachment; filename=\"notes.txt\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"aGVsbG8gd29ybGQ=\r\n" +
+	"--b1--\r\n"
+
+func startCatcher(t *testing.T, options *Options) (*Catcher, string, *mailosaur.MailosaurClient) {
+	catcher := New(options)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go catcher.Serve(l)
+	t.Cleanup(func() { catcher.Close() })
+
+	api := httptest.NewServer(catcher)
+	t.Cleanup(api.Close)
+
+	client := mailosaur.New("any key")
+	client.SetBaseUrl(api.URL)
+
+	return catcher, l.Addr().String(), client
+}
+
+func TestCatcher(t *testing.T) {
+	_, addr, client := startCatcher(t, nil)
+
+	auth := smtp.PlainAuth("", "abc123@mailosaur.net", "password", "127.0.0.1")
+	err := smtp.SendMail(addr, auth, "noreply@example.com", []string{"jo@abc123.mailosaur.net"}, []byte(welcomeEmail))
+	assert.NoError(t, err)
+
+	message, err := client.Messages.Get(&mailosaur.MessageSearchParams{Server: "abc123"}, &mailosaur.SearchCriteria{SentTo: "jo@abc123.mailosaur.net"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Welcome to ACME", message.Subject)
+	assert.Equal(t, "abc123", message.Server)
+	assert.Equal(t, "noreply@example.com", message.Metadata.MailFrom)
+	assert.Equal(t, "jo@abc123.mailosaur.net", message.Metadata.RcptTo[0].Email)
+	assert.Equal(t, "localhost", message.Metadata.Ehlo)
+	assert.Contains(t, message.Text.Body, "\n.Lines starting with a dot survive.")
+	assert.WithinDuration(t, time.Now(), message.Received, time.Minute)
+
+	assert.Equal(t, 1, len(message.Attachments))
+	assert.Equal(t, "notes.txt", message.Attachments[0].FileName)
+	assert.Empty(t, message.Attachments[0].Content)
+
+	attachment, err := client.Files.GetAttachment(message.Attachments[0].Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", string(attachment))
+
+	raw, err := client.Files.GetEmail(message.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, welcomeEmail, string(raw))
+
+	list, err := client.Messages.List(&mailosaur.MessageListParams{Server: "abc123"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(list.Items))
+	assert.Equal(t, message.Id, list.Items[0].Id)
+	assert.Equal(t, 1, list.Items[0].Attachments)
+	assert.Equal(t, "Your code is 123456. .Lines starting with a dot survive.", list.Items[0].Summary)
+
+	byId, err := client.Messages.GetById(message.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, message.Subject, byId.Subject)
+
+	assert.NoError(t, client.Messages.Delete(message.Id))
+	_, err = client.Messages.GetById(message.Id)
+	assert.Error(t, err)
+}
+
+func TestCatcherSearch(t *testing.T) {
+	catcher, _, client := startCatcher(t, nil)
+
+	send := func(to string, subject string, body string) {
+		raw := "From: noreply@example.com\r\nTo: " + to + "\r\nSubject: " + subject + "\r\n\r\n" + body + "\r\n"
+		_, err := catcher.Deliver("noreply@example.com", []string{to}, []byte(raw))
+		assert.NoError(t, err)
+	}
+
+	send("a@abc123.mailosaur.net", "Reset your password", "Click to reset")
+	send("b@abc123.mailosaur.net", "Welcome", "Thanks for signing up")
+	send("c@other.mailosaur.net", "Welcome", "Another server")
+
+	params := &mailosaur.MessageSearchParams{Server: "abc123", Timeout: 0}
+
+	result, err := client.Messages.Search(params, &mailosaur.SearchCriteria{Subject: "welcome"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(result.Items))
+	assert.Equal(t, "b@abc123.mailosaur.net", result.Items[0].To[0].Email)
+
+	result, _ = client.Messages.Search(params, &mailosaur.SearchCriteria{Subject: "welcome", Body: "reset", Match: "ANY"})
+	assert.Equal(t, 2, len(result.Items))
+
+	result, _ = client.Messages.Search(params, &mailosaur.SearchCriteria{Subject: "welcome", Body: "reset"})
+	assert.Equal(t, 0, len(result.Items))
+
+	result, _ = client.Messages.Search(params, &mailosaur.SearchCriteria{SentFrom: "NOREPLY@example.com"})
+	assert.Equal(t, 2, len(result.Items))
+
+	result, _ = client.Messages.Search(&mailosaur.MessageSearchParams{Server: "abc123", ItemsPerPage: 1, Page: 1}, &mailosaur.SearchCriteria{})
+	assert.Equal(t, 1, len(result.Items))
+	assert.Equal(t, "Reset your password", result.Items[0].Subject)
+
+	// Searching with a timeout polls until it runs out
+	_, err = client.Messages.Get(&mailosaur.MessageSearchParams{Server: "abc123", Timeout: 1}, &mailosaur.SearchCriteria{Subject: "missing"})
+	assert.Error(t, err)
+
+	_, err = client.Messages.List(&mailosaur.MessageListParams{})
+	assert.Error(t, err)
+
+	assert.NoError(t, client.Messages.DeleteAll("abc123"))
+	list, _ := client.Messages.List(&mailosaur.MessageListParams{Server: "abc123"})
+	assert.Equal(t, 0, len(list.Items))
+	list, _ = client.Messages.List(&mailosaur.MessageListParams{Server: "other"})
+	assert.Equal(t, 1, len(list.Items))
+}
+
+func TestCatcherRejectsOtherAddresses(t *testing.T) {
+	_, addr, _ := startCatcher(t, &Options{Host: "example.test", MaxSize: 1024})
+
+	c, err := smtp.Dial(addr)
+	assert.NoError(t, err)
+	defer c.Close()
+
+	assert.NoError(t, c.Mail("sender@example.com"))
+	err = c.Rcpt("jo@abc123.mailosaur.net")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "550")
+	assert.NoError(t, c.Rcpt("jo@abc123.example.test"))
+
+	w, err := c.Data()
+	assert.NoError(t, err)
+	w.Write([]byte("Subject: Too large\r\n\r\n" + strings.Repeat("x", 2048) + "\r\n"))
+	err = w.Close()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "552")
+}
+
+func TestCatcherServerFor(t *testing.T) {
+	catcher := New(nil)
+
+	server, ok := catcher.ServerFor("anything@ABC123.mailosaur.net")
+	assert.True(t, ok)
+	assert.Equal(t, "abc123", server)
+
+	for _, address := range []string{"jo@mailosaur.net", "jo@a.b.mailosaur.net", "jo@abc123.example.com", "abc123.mailosaur.net"} {
+		_, ok := catcher.ServerFor(address)
+		assert.False(t, ok, address)
+	}
+
+	stored, err := catcher.Deliver("", []string{"a@s1.mailosaur.net", "b@s2.mailosaur.net", "c@s1.mailosaur.net"}, []byte("Subject: Hi\r\n\r\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(stored))
+	assert.Equal(t, []string{"a@s1.mailosaur.net", "c@s1.mailosaur.net"}, stored[0].RcptTo)
+
+	_, err = catcher.Deliver("", []string{"a@example.com"}, []byte("Subject: Hi\r\n\r\n"))
+	assert.Error(t, err)
+}
+
+func TestCatcherClose(t *testing.T) {
+	catcher := New(nil)
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+
+	done := make(chan error)
+	go func() { done <- catcher.Serve(l) }()
+
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, catcher.Close())
+	assert.Equal(t, ErrClosed, <-done)
+}
@@ -0,0 +1,219 @@
+package mailosaurcatcher
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+type smtpSession struct {
+	c        *Catcher
+	text     *textproto.Conn
+	ehlo     string
+	mailFrom string
+	rcptTo   []string
+	inMail   bool
+}
+
+func (c *Catcher) serveSMTP(conn net.Conn) {
+	defer conn.Close()
+
+	s := &smtpSession{c: c, text: textproto.NewConn(conn)}
+	s.reply(220, c.hostname+" ESMTP Mailosaur catcher")
+
+	for {
+		conn.SetDeadline(time.Now().Add(5 * time.Minute))
+
+		line, err := s.text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg := line, ""
+		if idx := strings.Index(line, " "); idx >= 0 {
+			verb, arg = line[:idx], strings.TrimSpace(line[idx+1:])
+		}
+
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			s.ehlo = arg
+			s.reset()
+			s.reply(250, c.hostname)
+		case "EHLO":
+			s.ehlo = arg
+			s.reset()
+			s.reply(250, c.hostname, fmt.Sprintf("SIZE %d", c.maxSize), "8BITMIME", "AUTH PLAIN LOGIN")
+		case "AUTH":
+			s.auth(arg)
+		case "MAIL":
+			s.mail(arg)
+		case "RCPT":
+			s.rcpt(arg)
+		case "DATA":
+			if !s.data() {
+				return
+			}
+		case "RSET":
+			s.reset()
+			s.reply(250, "2.0.0 Ok")
+		case "NOOP":
+			s.reply(250, "2.0.0 Ok")
+		case "VRFY":
+			s.reply(252, "2.5.0 Cannot verify, but will accept")
+		case "QUIT":
+			s.reply(221, "2.0.0 Bye")
+			return
+		default:
+			s.reply(502, "5.5.2 Command not recognised")
+		}
+	}
+}
+
+// reply writes a single or multi-line response.
+func (s *smtpSession) reply(code int, lines ...string) {
+	for i, line := range lines {
+		separator := "-"
+		if i == len(lines)-1 {
+			separator = " "
+		}
+		s.text.PrintfLine("%d%s%s", code, separator, line)
+	}
+}
+
+func (s *smtpSession) reset() {
+	s.mailFrom = ""
+	s.rcptTo = nil
+	s.inMail = false
+}
+
+// auth accepts any credentials, so that clients configured for Mailosaur
+// can send without changes.
+func (s *smtpSession) auth(arg string) {
+	fields := strings.Fields(arg)
+	if len(fields) == 0 {
+		s.reply(501, "5.5.4 Syntax: AUTH mechanism")
+		return
+	}
+
+	switch strings.ToUpper(fields[0]) {
+	case "PLAIN":
+		if len(fields) == 1 {
+			s.reply(334, "")
+			if _, err := s.text.ReadLine(); err != nil {
+				return
+			}
+		}
+	case "LOGIN":
+		// "Username:" and "Password:", base64 encoded
+		for _, prompt := range []string{"VXNlcm5hbWU6", "UGFzc3dvcmQ6"} {
+			s.reply(334, prompt)
+			if _, err := s.text.ReadLine(); err != nil {
+				return
+			}
+		}
+	default:
+		s.reply(504, "5.5.4 Unrecognised authentication mechanism")
+		return
+	}
+
+	s.reply(235, "2.7.0 Authentication successful")
+}
+
+func (s *smtpSession) mail(arg string) {
+	address, ok := pathArg(arg, "FROM:")
+	if !ok {
+		s.reply(501, "5.5.4 Syntax: MAIL FROM:<address>")
+		return
+	}
+
+	s.reset()
+	s.mailFrom = address
+	s.inMail = true
+	s.reply(250, "2.1.0 Ok")
+}
+
+func (s *smtpSession) rcpt(arg string) {
+	if !s.inMail {
+		s.reply(503, "5.5.1 Need MAIL before RCPT")
+		return
+	}
+
+	address, ok := pathArg(arg, "TO:")
+	if !ok {
+		s.reply(501, "5.5.4 Syntax: RCPT TO:<address>")
+		return
+	}
+
+	if _, ok := s.c.ServerFor(address); !ok {
+		s.reply(550, "5.1.1 <"+address+">: Recipient address rejected, expected anything@{server}."+s.c.host)
+		return
+	}
+
+	s.rcptTo = append(s.rcptTo, address)
+	s.reply(250, "2.1.5 Ok")
+}
+
+// data reads a message, returning false if the connection failed.
+func (s *smtpSession) data() bool {
+	if len(s.rcptTo) == 0 {
+		s.reply(503, "5.5.1 Need RCPT before DATA")
+		return true
+	}
+
+	s.reply(354, "End data with <CR><LF>.<CR><LF>")
+
+	var buf bytes.Buffer
+	tooLarge := false
+	for {
+		line, err := s.text.ReadLine()
+		if err != nil {
+			return false
+		}
+		if line == "." {
+			break
+		}
+		if tooLarge {
+			continue
+		}
+
+		buf.WriteString(strings.TrimPrefix(line, "."))
+		buf.WriteString("\r\n")
+		tooLarge = buf.Len() > s.c.maxSize
+	}
+
+	if tooLarge {
+		s.reset()
+		s.reply(552, "5.3.4 Message exceeds the maximum size")
+		return true
+	}
+
+	stored, err := s.c.deliver(s.ehlo, s.mailFrom, s.rcptTo, buf.Bytes())
+	s.reset()
+	if err != nil {
+		s.reply(451, "4.3.0 "+err.Error())
+		return true
+	}
+
+	s.reply(250, "2.0.0 Ok: queued as "+stored[0].Id)
+	return true
+}
+
+// pathArg extracts the address from "FROM:<address> SIZE=123" and similar.
+func pathArg(arg string, prefix string) (string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+
+	path := strings.TrimSpace(arg[len(prefix):])
+	if idx := strings.Index(path, " "); idx >= 0 {
+		path = path[:idx]
+	}
+
+	if !strings.HasPrefix(path, "<") || !strings.HasSuffix(path, ">") {
+		return "", false
+	}
+	return path[1 : len(path)-1], true
+}
@@ -0,0 +1,251 @@
+package mailosaurcatcher
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNotFound = errors.New("mailosaurcatcher: message not found")
+
+// StoredMessage is a message as received over SMTP, with its envelope.
+type StoredMessage struct {
+	Id       string    `json:"id"`
+	Server   string    `json:"server"`
+	Received time.Time `json:"received"`
+	Ehlo     string    `json:"ehlo"`
+	MailFrom string    `json:"mailFrom"`
+	RcptTo   []string  `json:"rcptTo"`
+	Raw      []byte    `json:"-"`
+}
+
+// Store holds received messages. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	Add(m *StoredMessage) error
+	// List returns the messages for a server, oldest first.
+	List(server string) ([]*StoredMessage, error)
+	Get(id string) (*StoredMessage, error)
+	Delete(id string) error
+	DeleteAll(server string) error
+}
+
+type memoryStore struct {
+	mu       sync.RWMutex
+	messages []*StoredMessage
+}
+
+// NewMemoryStore returns a store that keeps messages until the process
+// exits.
+func NewMemoryStore() Store {
+	return &memoryStore{}
+}
+
+func (s *memoryStore) Add(m *StoredMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, m)
+	return nil
+}
+
+func (s *memoryStore) List(server string) ([]*StoredMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*StoredMessage
+	for _, m := range s.messages {
+		if m.Server == server {
+			result = append(result, m)
+		}
+	}
+	return result, nil
+}
+
+func (s *memoryStore) Get(id string) (*StoredMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, m := range s.messages {
+		if m.Id == id {
+			return m, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryStore) Delete(id string) error {
+	return s.remove(func(m *StoredMessage) bool { return m.Id == id })
+}
+
+func (s *memoryStore) DeleteAll(server string) error {
+	s.remove(func(m *StoredMessage) bool { return m.Server == server })
+	return nil
+}
+
+func (s *memoryStore) remove(match func(*StoredMessage) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.messages[:0]
+	removed := false
+	for _, m := range s.messages {
+		if match(m) {
+			removed = true
+			continue
+		}
+		kept = append(kept, m)
+	}
+	s.messages = kept
+
+	if !removed {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type dirStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewDirStore returns a store that keeps messages on disk, so that they
+// survive restarts. Each server has a directory holding an .eml file and a
+// .json envelope for every message.
+func NewDirStore(dir string) (Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &dirStore{dir: dir}, nil
+}
+
+func (s *dirStore) Add(m *StoredMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := filepath.Join(s.dir, m.Server)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	envelope, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, m.Id+".eml"), m.Raw, 0644); err != nil {
+		return err
+	}
+
+	// The envelope is written last, so a message is never listed without
+	// its content
+	return os.WriteFile(filepath.Join(dir, m.Id+".json"), envelope, 0644)
+}
+
+func (s *dirStore) List(server string) ([]*StoredMessage, error) {
+	if !validName(server) {
+		return nil, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(s.dir, server, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*StoredMessage
+	for _, path := range paths {
+		m, err := s.read(path)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, m)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Received.Before(result[j].Received)
+	})
+	return result, nil
+}
+
+func (s *dirStore) Get(id string) (*StoredMessage, error) {
+	path, err := s.find(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.read(path)
+}
+
+func (s *dirStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, err := s.find(id)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	return os.Remove(strings.TrimSuffix(path, ".json") + ".eml")
+}
+
+func (s *dirStore) DeleteAll(server string) error {
+	if !validName(server) {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return os.RemoveAll(filepath.Join(s.dir, server))
+}
+
+func (s *dirStore) find(id string) (string, error) {
+	if !validName(id) {
+		return "", ErrNotFound
+	}
+
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*", id+".json"))
+	if err != nil {
+		return "", err
+	}
+	if len(paths) == 0 {
+		return "", ErrNotFound
+	}
+	return paths[0], nil
+}
+
+func (s *dirStore) read(path string) (*StoredMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &StoredMessage{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+
+	m.Raw, err = os.ReadFile(strings.TrimSuffix(path, ".json") + ".eml")
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// validName stops IDs from the HTTP API reaching outside the directory.
+func validName(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') && r != '-' && r != '_' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package mailosaurcatcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testStore(t *testing.T, store Store) {
+	now := time.Now().UTC()
+	first := &StoredMessage{Id: "m1", Server: "s1", Received: now, RcptTo: []string{"a@s1.mailosaur.net"}, Raw: []byte("Subject: One\r\n\r\n")}
+	second := &StoredMessage{Id: "m2", Server: "s1", Received: now.Add(time.Second), Raw: []byte("Subject: Two\r\n\r\n")}
+	other := &StoredMessage{Id: "m3", Server: "s2", Received: now, Raw: []byte("Subject: Three\r\n\r\n")}
+
+	assert.NoError(t, store.Add(second))
+	assert.NoError(t, store.Add(first))
+	assert.NoError(t, store.Add(other))
+
+	list, err := store.List("s1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(list))
+
+	m, err := store.Get("m1")
+	assert.NoError(t, err)
+	assert.Equal(t, "s1", m.Server)
+	assert.Equal(t, []string{"a@s1.mailosaur.net"}, m.RcptTo)
+	assert.Equal(t, "Subject: One\r\n\r\n", string(m.Raw))
+	assert.True(t, now.Equal(m.Received))
+
+	_, err = store.Get("missing")
+	assert.Equal(t, ErrNotFound, err)
+	_, err = store.Get("../s1/m1")
+	assert.Equal(t, ErrNotFound, err)
+
+	assert.NoError(t, store.Delete("m2"))
+	assert.Equal(t, ErrNotFound, store.Delete("m2"))
+
+	assert.NoError(t, store.DeleteAll("s1"))
+	list, _ = store.List("s1")
+	assert.Equal(t, 0, len(list))
+	list, _ = store.List("s2")
+	assert.Equal(t, 1, len(list))
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestDirStore(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewDirStore(dir)
+	assert.NoError(t, err)
+	testStore(t, store)
+
+	// Messages survive a restart
+	reopened, _ := NewDirStore(dir)
+	m, err := reopened.Get("m3")
+	assert.NoError(t, err)
+	assert.Equal(t, "Subject: Three\r\n\r\n", string(m.Raw))
+}
+
+func TestDirStoreOrder(t *testing.T) {
+	store, _ := NewDirStore(t.TempDir())
+	now := time.Now()
+
+	store.Add(&StoredMessage{Id: "b", Server: "s1", Received: now.Add(time.Second)})
+	store.Add(&StoredMessage{Id: "a", Server: "s1", Received: now.Add(2 * time.Second)})
+	store.Add(&StoredMessage{Id: "c", Server: "s1", Received: now})
+
+	list, _ := store.List("s1")
+	assert.Equal(t, "c", list[0].Id)
+	assert.Equal(t, "b", list[1].Id)
+	assert.Equal(t, "a", list[2].Id)
+}
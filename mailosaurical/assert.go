@@ -0,0 +1,277 @@
+package mailosaurical
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Invite checks that the calendar is a new meeting request with a single
+// event and returns that event, or nil if the check failed.
+func Invite(t testing.TB, calendar *Calendar, msgAndArgs ...interface{}) *Event {
+	t.Helper()
+	if !notNil(t, calendar, msgAndArgs...) {
+		return nil
+	}
+
+	if calendar.Method != MethodRequest {
+		fail(t, calendar, "Calendar is not an invite", diff(MethodRequest, calendar.Method), msgAndArgs...)
+		return nil
+	}
+
+	event := master(t, calendar, msgAndArgs...)
+	if event == nil {
+		return nil
+	}
+
+	if event.Status == "CANCELLED" {
+		fail(t, calendar, fmt.Sprintf("Event %q is cancelled", event.Uid), "", msgAndArgs...)
+		return nil
+	}
+
+	return event
+}
+
+// Update checks that the calendar is a meeting request for the same event
+// as previous, with a higher SEQUENCE, and returns the updated event.
+func Update(t testing.TB, previous *Calendar, calendar *Calendar, msgAndArgs ...interface{}) *Event {
+	t.Helper()
+	if !notNil(t, previous, msgAndArgs...) || !notNil(t, calendar, msgAndArgs...) {
+		return nil
+	}
+
+	before := master(t, previous, msgAndArgs...)
+	if before == nil {
+		return nil
+	}
+
+	after := Invite(t, calendar, msgAndArgs...)
+	if after == nil {
+		return nil
+	}
+
+	if after.Uid != before.Uid {
+		fail(t, calendar, "Update is for a different event", diff(before.Uid, after.Uid), msgAndArgs...)
+		return nil
+	}
+
+	if after.Sequence <= before.Sequence {
+		fail(t, calendar, fmt.Sprintf("SEQUENCE was not incremented from %d", before.Sequence), diff(fmt.Sprintf("> %d", before.Sequence), fmt.Sprint(after.Sequence)), msgAndArgs...)
+		return nil
+	}
+
+	return after
+}
+
+// Cancellation checks that the calendar cancels the event with the given
+// UID and returns the cancelled event.
+func Cancellation(t testing.TB, calendar *Calendar, uid string, msgAndArgs ...interface{}) *Event {
+	t.Helper()
+	if !notNil(t, calendar, msgAndArgs...) {
+		return nil
+	}
+
+	if calendar.Method != MethodCancel {
+		fail(t, calendar, "Calendar is not a cancellation", diff(MethodCancel, calendar.Method), msgAndArgs...)
+		return nil
+	}
+
+	event := calendar.Event(uid)
+	if event == nil {
+		fail(t, calendar, fmt.Sprintf("No event with UID %q", uid), "", msgAndArgs...)
+		return nil
+	}
+
+	return event
+}
+
+// HasAttendee returns the attendee with the given email address, failing
+// the test if there is none.
+func HasAttendee(t testing.TB, event *Event, email string, msgAndArgs ...interface{}) *Attendee {
+	t.Helper()
+	if event == nil {
+		fail(t, nil, "Expected an event but got nil", "", msgAndArgs...)
+		return nil
+	}
+
+	a := event.Attendee(email)
+	if a == nil {
+		failEvent(t, event, fmt.Sprintf("%q is not an attendee, attendees were %s", email, formatAttendees(event.Attendees)), msgAndArgs...)
+	}
+	return a
+}
+
+// RsvpRequested checks that the attendee was asked to respond to the
+// invite.
+func RsvpRequested(t testing.TB, event *Event, email string, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	a := HasAttendee(t, event, email, msgAndArgs...)
+	if a == nil {
+		return false
+	}
+
+	if !a.Rsvp {
+		return failEvent(t, event, fmt.Sprintf("RSVP was not requested from %q", email), msgAndArgs...)
+	}
+	return true
+}
+
+func OrganizedBy(t testing.TB, event *Event, email string, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	if event == nil {
+		return fail(t, nil, "Expected an event but got nil", "", msgAndArgs...)
+	}
+
+	if event.Organizer == nil || !strings.EqualFold(event.Organizer.Email, email) {
+		organizer := ""
+		if event.Organizer != nil {
+			organizer = event.Organizer.Email
+		}
+		return failEvent(t, event, "Organizer not equal\n"+diff(email, organizer), msgAndArgs...)
+	}
+	return true
+}
+
+// Scheduled checks the start and end of the event as instants, so the
+// expected times may be given in any location.
+func Scheduled(t testing.TB, event *Event, start time.Time, end time.Time, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	if event == nil {
+		return fail(t, nil, "Expected an event but got nil", "", msgAndArgs...)
+	}
+
+	if !event.Start.Equal(start) {
+		return failEvent(t, event, "Start not equal\n"+diff(start.Format(time.RFC3339), event.Start.Format(time.RFC3339)), msgAndArgs...)
+	}
+	if !event.End.Equal(end) {
+		return failEvent(t, event, "End not equal\n"+diff(end.Format(time.RFC3339), event.End.Format(time.RFC3339)), msgAndArgs...)
+	}
+	return true
+}
+
+// master returns the single event of the calendar, ignoring overrides of
+// individual occurrences of a recurring event.
+func master(t testing.TB, calendar *Calendar, msgAndArgs ...interface{}) *Event {
+	t.Helper()
+
+	var masters []*Event
+	for _, e := range calendar.Events {
+		if e.RecurrenceId.IsZero() {
+			masters = append(masters, e)
+		}
+	}
+
+	if len(masters) != 1 {
+		fail(t, calendar, fmt.Sprintf("Expected a single event but found %d", len(masters)), "", msgAndArgs...)
+		return nil
+	}
+	return masters[0]
+}
+
+func notNil(t testing.TB, calendar *Calendar, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	if calendar == nil {
+		return fail(t, nil, "Expected a calendar but got nil", "", msgAndArgs...)
+	}
+	return true
+}
+
+func failEvent(t testing.TB, event *Event, failure string, msgAndArgs ...interface{}) bool {
+	t.Helper()
+	return fail(t, &Calendar{Events: []*Event{event}}, failure, "", msgAndArgs...)
+}
+
+func fail(t testing.TB, calendar *Calendar, failure string, details string, msgAndArgs ...interface{}) bool {
+	t.Helper()
+
+	var sb strings.Builder
+	sb.WriteString(failure)
+	if len(details) > 0 {
+		sb.WriteString("\n")
+		sb.WriteString(details)
+	}
+	if m := messageFromMsgAndArgs(msgAndArgs...); len(m) > 0 {
+		sb.WriteString("\nMessages: ")
+		sb.WriteString(m)
+	}
+	if calendar != nil {
+		sb.WriteString("\n")
+		sb.WriteString(Dump(calendar))
+	}
+
+	t.Errorf("%s", sb.String())
+	return false
+}
+
+func diff(expected string, actual string) string {
+	return fmt.Sprintf("expected: %q\nactual  : %q", expected, actual)
+}
+
+func messageFromMsgAndArgs(msgAndArgs ...interface{}) string {
+	if len(msgAndArgs) == 0 {
+		return ""
+	}
+	if len(msgAndArgs) == 1 {
+		if s, ok := msgAndArgs[0].(string); ok {
+			return s
+		}
+		return fmt.Sprintf("%+v", msgAndArgs[0])
+	}
+	if format, ok := msgAndArgs[0].(string); ok {
+		return fmt.Sprintf(format, msgAndArgs[1:]...)
+	}
+	return ""
+}
+
+// Dump returns a compact, human readable summary of a calendar, used in
+// assertion failures.
+func Dump(calendar *Calendar) string {
+	if calendar == nil {
+		return "Calendar: <nil>"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Calendar:\n")
+	if len(calendar.Method) > 0 {
+		fmt.Fprintf(&sb, "  Method   : %s\n", calendar.Method)
+	}
+	for _, e := range calendar.Events {
+		fmt.Fprintf(&sb, "  Event    : %s (sequence %d)\n", e.Uid, e.Sequence)
+		fmt.Fprintf(&sb, "    Summary  : %q\n", e.Summary)
+		if len(e.Status) > 0 {
+			fmt.Fprintf(&sb, "    Status   : %s\n", e.Status)
+		}
+		fmt.Fprintf(&sb, "    Start    : %s\n", e.Start.Format(time.RFC3339))
+		fmt.Fprintf(&sb, "    End      : %s\n", e.End.Format(time.RFC3339))
+		if len(e.Location) > 0 {
+			fmt.Fprintf(&sb, "    Location : %q\n", e.Location)
+		}
+		if e.Organizer != nil {
+			fmt.Fprintf(&sb, "    Organizer: %s\n", formatAttendees([]*Attendee{e.Organizer}))
+		}
+		if len(e.Attendees) > 0 {
+			fmt.Fprintf(&sb, "    Attendees: %s\n", formatAttendees(e.Attendees))
+		}
+		if e.RRule != nil {
+			fmt.Fprintf(&sb, "    RRule    : %s\n", e.RRule.Raw)
+		}
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func formatAttendees(attendees []*Attendee) string {
+	parts := make([]string, 0, len(attendees))
+	for _, a := range attendees {
+		value := a.Email
+		if len(a.Name) > 0 {
+			value = fmt.Sprintf("%s <%s>", a.Name, value)
+		}
+		if a.Rsvp {
+			value += " (RSVP)"
+		}
+		parts = append(parts, value)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
@@ -0,0 +1,78 @@
+package mailosaurical
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockT struct {
+	testing.TB
+	errors []string
+}
+
+func (m *mockT) Helper() {}
+
+func (m *mockT) Errorf(format string, args ...interface{}) {
+	m.errors = append(m.errors, fmt.Sprintf(format, args...))
+}
+
+func TestInviteUpdateCancelFlow(t *testing.T) {
+	m := &mockT{}
+	invite := loadCalendar(t, "invite.ics")
+	update := loadCalendar(t, "update.ics")
+	cancel := loadCalendar(t, "cancel.ics")
+
+	event := Invite(m, invite)
+	assert.NotNil(t, event)
+	assert.NotNil(t, HasAttendee(m, event, "jo@abcd1234.mailosaur.net"))
+	assert.True(t, RsvpRequested(m, event, "jo@abcd1234.mailosaur.net"))
+	assert.True(t, OrganizedBy(m, event, "scheduler@acme.example"))
+	assert.True(t, Scheduled(m, event, time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC), time.Date(2024, 1, 15, 11, 0, 0, 0, time.UTC)))
+
+	updated := Update(m, invite, update)
+	assert.NotNil(t, updated)
+	assert.Equal(t, 1, updated.Sequence)
+	assert.True(t, Scheduled(m, updated, time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC), time.Date(2024, 1, 15, 15, 0, 0, 0, time.UTC)))
+
+	assert.NotNil(t, Cancellation(m, cancel, event.Uid))
+
+	assert.Empty(t, m.errors)
+}
+
+func TestFailingCalendarAssertions(t *testing.T) {
+	invite := loadCalendar(t, "invite.ics")
+	cancel := loadCalendar(t, "cancel.ics")
+	event := invite.Events[0]
+
+	cases := map[string]func(testing.TB) bool{
+		"Invite":        func(tb testing.TB) bool { return Invite(tb, cancel) != nil },
+		"Update":        func(tb testing.TB) bool { return Update(tb, invite, invite) != nil },
+		"Cancellation":  func(tb testing.TB) bool { return Cancellation(tb, invite, event.Uid) != nil },
+		"Unknown UID":   func(tb testing.TB) bool { return Cancellation(tb, cancel, "other") != nil },
+		"HasAttendee":   func(tb testing.TB) bool { return HasAttendee(tb, event, "other@acme.example") != nil },
+		"RsvpRequested": func(tb testing.TB) bool { return RsvpRequested(tb, event, "sam@abcd1234.mailosaur.net") },
+		"OrganizedBy":   func(tb testing.TB) bool { return OrganizedBy(tb, event, "other@acme.example") },
+		"Scheduled":     func(tb testing.TB) bool { return Scheduled(tb, event, time.Time{}, time.Time{}) },
+		"Nil":           func(tb testing.TB) bool { return Invite(tb, nil) != nil },
+	}
+
+	for name, fn := range cases {
+		m := &mockT{}
+		assert.False(t, fn(m), name)
+		assert.Equal(t, 1, len(m.errors), name)
+	}
+}
+
+func TestFailureShowsCalendar(t *testing.T) {
+	m := &mockT{}
+	Update(m, loadCalendar(t, "invite.ics"), loadCalendar(t, "invite.ics"), "after %s", "reschedule")
+
+	assert.Equal(t, 1, len(m.errors))
+	assert.Contains(t, m.errors[0], "SEQUENCE was not incremented from 0")
+	assert.Contains(t, m.errors[0], "Messages: after reschedule")
+	assert.Contains(t, m.errors[0], "Event    : 7f3c2a10-planning@acme.example (sequence 0)")
+	assert.Contains(t, m.errors[0], "Attendees: [Jo Bloggs <jo@abcd1234.mailosaur.net> (RSVP), Sam <sam@abcd1234.mailosaur.net>]")
+}
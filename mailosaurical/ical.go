@@ -0,0 +1,606 @@
+// Package mailosaurical parses iCalendar (RFC 5545) meeting invites sent as
+// text/calendar parts of messages, and provides assertions for the common
+// invite, update and cancel flows.
+package mailosaurical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MethodPublish = "PUBLISH"
+	MethodRequest = "REQUEST"
+	MethodReply   = "REPLY"
+	MethodCancel  = "CANCEL"
+)
+
+type Calendar struct {
+	Method     string
+	ProdId     string
+	Version    string
+	Events     []*Event
+	Properties []*Property
+}
+
+type Event struct {
+	Uid          string
+	Sequence     int
+	Status       string
+	Summary      string
+	Description  string
+	Location     string
+	Start        time.Time
+	End          time.Time
+	AllDay       bool
+	Created      time.Time
+	Stamp        time.Time
+	Organizer    *Attendee
+	Attendees    []*Attendee
+	RRule        *RecurrenceRule
+	RecurrenceId time.Time
+	Properties   []*Property
+}
+
+type Attendee struct {
+	Email    string
+	Name     string
+	Role     string
+	PartStat string
+	CuType   string
+	Rsvp     bool
+}
+
+type RecurrenceRule struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    time.Time
+	ByDay    []string
+	Raw      string
+}
+
+// Property is a single content line, with its parameters unescaped.
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+func (p *Property) Param(name string) string {
+	return p.Params[strings.ToUpper(name)]
+}
+
+// Get returns the first top-level calendar property with the given name.
+func (c *Calendar) Get(name string) *Property {
+	return findProperty(c.Properties, name)
+}
+
+// Event returns the event with the given UID, or nil.
+func (c *Calendar) Event(uid string) *Event {
+	for _, e := range c.Events {
+		if e.Uid == uid {
+			return e
+		}
+	}
+	return nil
+}
+
+// Get returns the first event property with the given name.
+func (e *Event) Get(name string) *Property {
+	return findProperty(e.Properties, name)
+}
+
+// Attendee returns the attendee with the given email address (compared
+// case-insensitively), or nil.
+func (e *Event) Attendee(email string) *Attendee {
+	for _, a := range e.Attendees {
+		if strings.EqualFold(a.Email, email) {
+			return a
+		}
+	}
+	return nil
+}
+
+func findProperty(properties []*Property, name string) *Property {
+	for _, p := range properties {
+		if strings.EqualFold(p.Name, name) {
+			return p
+		}
+	}
+	return nil
+}
+
+// Parse reads an iCalendar stream, which may contain more than one
+// VCALENDAR object.
+func Parse(r io.Reader) ([]*Calendar, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var calendars []*Calendar
+	var calendar *Calendar
+	var event *Event
+	var stack []string
+	timezones := map[string]*time.Location{}
+	var tz *vtimezone
+
+	for i, line := range lines {
+		p, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", i+1, err)
+		}
+
+		switch p.Name {
+		case "BEGIN":
+			name := strings.ToUpper(p.Value)
+			stack = append(stack, name)
+			switch name {
+			case "VCALENDAR":
+				calendar = &Calendar{}
+			case "VEVENT":
+				event = &Event{}
+			case "VTIMEZONE":
+				tz = &vtimezone{}
+			}
+			continue
+		case "END":
+			name := strings.ToUpper(p.Value)
+			if len(stack) == 0 || stack[len(stack)-1] != name {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", i+1, p.Value)
+			}
+			stack = stack[:len(stack)-1]
+			switch name {
+			case "VCALENDAR":
+				calendars = append(calendars, calendar)
+				calendar = nil
+			case "VEVENT":
+				if calendar != nil {
+					calendar.Events = append(calendar.Events, event)
+				}
+				event = nil
+			case "VTIMEZONE":
+				if len(tz.id) > 0 {
+					timezones[tz.id] = tz.location()
+				}
+				tz = nil
+			}
+			continue
+		}
+
+		if len(stack) == 0 {
+			return nil, fmt.Errorf("line %d: property %s outside of a component", i+1, p.Name)
+		}
+
+		switch stack[len(stack)-1] {
+		case "VCALENDAR":
+			calendar.Properties = append(calendar.Properties, p)
+		case "VEVENT":
+			event.Properties = append(event.Properties, p)
+		case "VTIMEZONE":
+			if p.Name == "TZID" {
+				tz.id = p.Value
+			}
+		case "STANDARD", "DAYLIGHT":
+			if tz != nil && p.Name == "TZOFFSETTO" && (stack[len(stack)-1] == "STANDARD" || tz.offset == nil) {
+				if offset, err := parseOffset(p.Value); err == nil {
+					tz.offset = &offset
+				}
+			}
+		}
+	}
+
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("unterminated %s component", stack[len(stack)-1])
+	}
+
+	if len(calendars) == 0 {
+		return nil, errors.New("no VCALENDAR found")
+	}
+
+	for _, c := range calendars {
+		if err := c.populate(timezones); err != nil {
+			return nil, err
+		}
+	}
+
+	return calendars, nil
+}
+
+// ParseString parses iCalendar text that is expected to hold a single
+// VCALENDAR object.
+func ParseString(s string) (*Calendar, error) {
+	calendars, err := Parse(strings.NewReader(s))
+	if err != nil {
+		return nil, err
+	}
+	return calendars[0], nil
+}
+
+func (c *Calendar) populate(timezones map[string]*time.Location) error {
+	for _, p := range c.Properties {
+		switch p.Name {
+		case "METHOD":
+			c.Method = strings.ToUpper(p.Value)
+		case "PRODID":
+			c.ProdId = p.Value
+		case "VERSION":
+			c.Version = p.Value
+		}
+	}
+
+	for _, e := range c.Events {
+		if err := e.populate(timezones); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *Event) populate(timezones map[string]*time.Location) error {
+	var duration *Property
+
+	for _, p := range e.Properties {
+		var err error
+
+		switch p.Name {
+		case "UID":
+			e.Uid = p.Value
+		case "SEQUENCE":
+			e.Sequence, err = strconv.Atoi(p.Value)
+		case "STATUS":
+			e.Status = strings.ToUpper(p.Value)
+		case "SUMMARY":
+			e.Summary = p.Value
+		case "DESCRIPTION":
+			e.Description = p.Value
+		case "LOCATION":
+			e.Location = p.Value
+		case "DTSTART":
+			e.Start, err = parseTime(p, timezones)
+			e.AllDay = strings.EqualFold(p.Param("VALUE"), "DATE") || len(p.Value) == 8
+		case "DTEND":
+			e.End, err = parseTime(p, timezones)
+		case "DURATION":
+			duration = p
+		case "CREATED":
+			e.Created, err = parseTime(p, timezones)
+		case "DTSTAMP":
+			e.Stamp, err = parseTime(p, timezones)
+		case "RECURRENCE-ID":
+			e.RecurrenceId, err = parseTime(p, timezones)
+		case "ORGANIZER":
+			e.Organizer = parseAttendee(p)
+		case "ATTENDEE":
+			e.Attendees = append(e.Attendees, parseAttendee(p))
+		case "RRULE":
+			e.RRule, err = parseRRule(p.Value, timezones)
+		}
+
+		if err != nil {
+			return fmt.Errorf("event %s: invalid %s: %s", e.Uid, p.Name, err)
+		}
+	}
+
+	if e.End.IsZero() && duration != nil && !e.Start.IsZero() {
+		d, err := parseDuration(duration.Value)
+		if err != nil {
+			return fmt.Errorf("event %s: invalid DURATION: %s", e.Uid, err)
+		}
+		e.End = e.Start.Add(d)
+	}
+
+	return nil
+}
+
+func parseAttendee(p *Property) *Attendee {
+	email := p.Value
+	if len(email) >= 7 && strings.EqualFold(email[:7], "mailto:") {
+		email = email[7:]
+	}
+
+	return &Attendee{
+		Email:    email,
+		Name:     p.Param("CN"),
+		Role:     strings.ToUpper(p.Param("ROLE")),
+		PartStat: strings.ToUpper(p.Param("PARTSTAT")),
+		CuType:   strings.ToUpper(p.Param("CUTYPE")),
+		Rsvp:     strings.EqualFold(p.Param("RSVP"), "TRUE"),
+	}
+}
+
+func parseRRule(value string, timezones map[string]*time.Location) (*RecurrenceRule, error) {
+	rule := &RecurrenceRule{Interval: 1, Raw: value}
+
+	for _, part := range strings.Split(value, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("malformed rule part %q", part)
+		}
+
+		var err error
+		switch strings.ToUpper(kv[0]) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(kv[1])
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(kv[1])
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(kv[1])
+		case "UNTIL":
+			rule.Until, err = parseTime(&Property{Value: kv[1]}, timezones)
+		case "BYDAY":
+			rule.ByDay = strings.Split(strings.ToUpper(kv[1]), ",")
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(rule.Freq) == 0 {
+		return nil, errors.New("missing FREQ")
+	}
+
+	return rule, nil
+}
+
+// parseTime handles the three forms of DATE-TIME (UTC, floating and with a
+// TZID parameter) as well as DATE values. TZIDs are resolved from the IANA
+// database first, then from the VTIMEZONE components in the calendar.
+// Floating times are returned in UTC.
+func parseTime(p *Property, timezones map[string]*time.Location) (time.Time, error) {
+	value := p.Value
+
+	if len(value) == 8 {
+		return time.ParseInLocation("20060102", value, locationFor(p.Param("TZID"), timezones))
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse("20060102T150405Z", value)
+	}
+
+	return time.ParseInLocation("20060102T150405", value, locationFor(p.Param("TZID"), timezones))
+}
+
+func locationFor(tzid string, timezones map[string]*time.Location) *time.Location {
+	if len(tzid) == 0 {
+		return time.UTC
+	}
+
+	if loc, err := time.LoadLocation(tzid); err == nil {
+		return loc
+	}
+
+	if loc, ok := timezones[tzid]; ok {
+		return loc
+	}
+
+	return time.UTC
+}
+
+type vtimezone struct {
+	id     string
+	offset *int
+}
+
+// location falls back to the standard offset of the VTIMEZONE when the
+// TZID is not a name known to the IANA database, such as the Windows zone
+// names sent by Outlook.
+func (v *vtimezone) location() *time.Location {
+	if loc, err := time.LoadLocation(v.id); err == nil {
+		return loc
+	}
+	if v.offset != nil {
+		return time.FixedZone(v.id, *v.offset)
+	}
+	return time.UTC
+}
+
+func parseOffset(value string) (int, error) {
+	if len(value) != 5 && len(value) != 7 {
+		return 0, fmt.Errorf("invalid offset %q", value)
+	}
+
+	sign := 1
+	switch value[0] {
+	case '-':
+		sign = -1
+	case '+':
+	default:
+		return 0, fmt.Errorf("invalid offset %q", value)
+	}
+
+	hours, err := strconv.Atoi(value[1:3])
+	if err != nil {
+		return 0, err
+	}
+	minutes, err := strconv.Atoi(value[3:5])
+	if err != nil {
+		return 0, err
+	}
+	seconds := 0
+	if len(value) == 7 {
+		if seconds, err = strconv.Atoi(value[5:7]); err != nil {
+			return 0, err
+		}
+	}
+
+	return sign * (hours*3600 + minutes*60 + seconds), nil
+}
+
+func parseDuration(value string) (time.Duration, error) {
+	s := value
+	sign := time.Duration(1)
+	if strings.HasPrefix(s, "-") {
+		sign = -1
+		s = s[1:]
+	}
+	s = strings.TrimPrefix(s, "+")
+
+	if !strings.HasPrefix(s, "P") {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	s = s[1:]
+
+	var total time.Duration
+	inTime := false
+	num := ""
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			num += string(r)
+		case r == 'T':
+			inTime = true
+		default:
+			n, err := strconv.Atoi(num)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", value)
+			}
+			num = ""
+
+			switch {
+			case r == 'W':
+				total += time.Duration(n) * 7 * 24 * time.Hour
+			case r == 'D':
+				total += time.Duration(n) * 24 * time.Hour
+			case r == 'H' && inTime:
+				total += time.Duration(n) * time.Hour
+			case r == 'M' && inTime:
+				total += time.Duration(n) * time.Minute
+			case r == 'S' && inTime:
+				total += time.Duration(n) * time.Second
+			default:
+				return 0, fmt.Errorf("invalid duration %q", value)
+			}
+		}
+	}
+
+	if len(num) > 0 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	return sign * total, nil
+}
+
+// unfold joins content lines that were folded across multiple physical
+// lines, as required by RFC 5545 section 3.1.
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) == 0 {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, scanner.Err()
+}
+
+// parseLine splits a content line into its name, parameters and value,
+// respecting quoted parameter values that may contain ':' or ';'.
+func parseLine(line string) (*Property, error) {
+	p := &Property{Params: map[string]string{}}
+
+	inQuotes := false
+	nameEnd := -1
+	valueStart := -1
+	for i := 0; i < len(line) && valueStart < 0; i++ {
+		switch line[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case ';':
+			if !inQuotes && nameEnd < 0 {
+				nameEnd = i
+			}
+		case ':':
+			if !inQuotes {
+				if nameEnd < 0 {
+					nameEnd = i
+				}
+				valueStart = i + 1
+			}
+		}
+	}
+
+	if valueStart < 0 {
+		return nil, fmt.Errorf("malformed content line %q", line)
+	}
+
+	p.Name = strings.ToUpper(line[:nameEnd])
+	if len(p.Name) == 0 {
+		return nil, fmt.Errorf("malformed content line %q", line)
+	}
+
+	for _, param := range splitParams(line[nameEnd : valueStart-1]) {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		p.Params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+	}
+
+	p.Value = unescapeText(line[valueStart:])
+	return p, nil
+}
+
+func splitParams(s string) []string {
+	var params []string
+	inQuotes := false
+	start := -1
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case ';':
+			if !inQuotes {
+				if start >= 0 {
+					params = append(params, s[start:i])
+				}
+				start = i + 1
+			}
+		}
+	}
+	if start >= 0 && start < len(s) {
+		params = append(params, s[start:])
+	}
+
+	return params
+}
+
+func unescapeText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				sb.WriteByte('\n')
+			default:
+				sb.WriteByte(s[i])
+			}
+			continue
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,161 @@
+package mailosaurical
+
+import (
+	"encoding/base64"
+	"os"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/mailosaur/mailosaur-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func loadCalendar(t *testing.T, name string) *Calendar {
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	calendars, err := Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(calendars))
+	return calendars[0]
+}
+
+func TestParseInvite(t *testing.T) {
+	c := loadCalendar(t, "invite.ics")
+
+	assert.Equal(t, MethodRequest, c.Method)
+	assert.Equal(t, "-//Acme//Scheduler 1.0//EN", c.ProdId)
+	assert.Equal(t, "2.0", c.Version)
+	assert.Equal(t, 2, len(c.Events))
+
+	e := c.Events[0]
+	assert.Equal(t, "7f3c2a10-planning@acme.example", e.Uid)
+	assert.Equal(t, 0, e.Sequence)
+	assert.Equal(t, "Quarterly planning", e.Summary)
+	assert.Equal(t, "Agenda:\n1. Review, plan; commit", e.Description)
+	assert.Equal(t, "Room 4, Building 2", e.Location)
+	assert.False(t, e.AllDay)
+
+	london, _ := time.LoadLocation("Europe/London")
+	assert.True(t, e.Start.Equal(time.Date(2024, 1, 15, 10, 0, 0, 0, london)))
+	assert.True(t, e.End.Equal(time.Date(2024, 1, 15, 11, 0, 0, 0, london)))
+	assert.Equal(t, "Europe/London", e.Start.Location().String())
+	assert.Equal(t, time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC), e.Stamp)
+
+	assert.Equal(t, "scheduler@acme.example", e.Organizer.Email)
+	assert.Equal(t, "Acme, Scheduling", e.Organizer.Name)
+
+	assert.Equal(t, 2, len(e.Attendees))
+	jo := e.Attendee("JO@abcd1234.mailosaur.net")
+	assert.Equal(t, "Jo Bloggs", jo.Name)
+	assert.Equal(t, "REQ-PARTICIPANT", jo.Role)
+	assert.Equal(t, "NEEDS-ACTION", jo.PartStat)
+	assert.True(t, jo.Rsvp)
+	sam := e.Attendee("sam@abcd1234.mailosaur.net")
+	assert.False(t, sam.Rsvp)
+	assert.Equal(t, "ACCEPTED", sam.PartStat)
+
+	assert.Equal(t, "WEEKLY", e.RRule.Freq)
+	assert.Equal(t, 2, e.RRule.Interval)
+	assert.Equal(t, 6, e.RRule.Count)
+	assert.Equal(t, []string{"MO", "WE"}, e.RRule.ByDay)
+
+	// VALARM properties stay out of the event
+	assert.Equal(t, "Agenda:\n1. Review, plan; commit", e.Get("description").Value)
+}
+
+func TestParseWindowsTimezone(t *testing.T) {
+	c := loadCalendar(t, "invite.ics")
+	e := c.Events[1]
+
+	cet := time.FixedZone("", 3600)
+	assert.True(t, e.RecurrenceId.Equal(time.Date(2024, 1, 17, 11, 0, 0, 0, cet)))
+	assert.True(t, e.Start.Equal(time.Date(2024, 1, 17, 12, 0, 0, 0, cet)))
+	assert.True(t, e.End.Equal(time.Date(2024, 1, 17, 13, 30, 0, 0, cet)))
+}
+
+func TestParseCancel(t *testing.T) {
+	c := loadCalendar(t, "cancel.ics")
+	e := c.Events[0]
+
+	assert.Equal(t, MethodCancel, c.Method)
+	assert.Equal(t, "CANCELLED", e.Status)
+	assert.True(t, e.AllDay)
+	assert.Equal(t, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), e.Start)
+	assert.Equal(t, "", e.Attendees[0].Name)
+	assert.False(t, e.Attendees[0].Rsvp)
+}
+
+func TestParseFloatingAndUntil(t *testing.T) {
+	c, err := ParseString("BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:1\nDTSTART:20240101T090000\nDURATION:P1DT2H\nRRULE:FREQ=DAILY;UNTIL=20240110T000000Z\nEND:VEVENT\nEND:VCALENDAR\n")
+	assert.NoError(t, err)
+
+	e := c.Events[0]
+	assert.Equal(t, time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC), e.Start)
+	assert.Equal(t, time.Date(2024, 1, 2, 11, 0, 0, 0, time.UTC), e.End)
+	assert.Equal(t, 1, e.RRule.Interval)
+	assert.Equal(t, time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC), e.RRule.Until)
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := map[string]string{
+		"empty":        "",
+		"unterminated": "BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:1\n",
+		"mismatched":   "BEGIN:VCALENDAR\nBEGIN:VEVENT\nEND:VCALENDAR\n",
+		"no colon":     "BEGIN:VCALENDAR\nSUMMARY\nEND:VCALENDAR\n",
+		"outside":      "SUMMARY:hello\n",
+		"bad date":     "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:tomorrow\nEND:VEVENT\nEND:VCALENDAR\n",
+		"bad rrule":    "BEGIN:VCALENDAR\nBEGIN:VEVENT\nRRULE:COUNT=2\nEND:VEVENT\nEND:VCALENDAR\n",
+	}
+
+	for name, input := range cases {
+		_, err := ParseString(input)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestFromMessage(t *testing.T) {
+	raw, err := os.ReadFile("testdata/cancel.ics")
+	assert.NoError(t, err)
+
+	message := &mailosaur.Message{
+		Attachments: []*mailosaur.Attachment{
+			{FileName: "cat.png", ContentType: "image/png"},
+			{FileName: "meeting.ics", ContentType: "application/octet-stream", Content: base64.StdEncoding.EncodeToString(raw)},
+		},
+	}
+
+	calendars, err := FromMessage(nil, message)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(calendars))
+	assert.Equal(t, MethodCancel, calendars[0].Method)
+}
+
+func TestFromEmail(t *testing.T) {
+	raw, err := os.ReadFile("testdata/invite.eml")
+	assert.NoError(t, err)
+
+	calendars, err := FromEmail(raw)
+	assert.NoError(t, err)
+
+	// The inline text/calendar alternative and the invite.ics attachment
+	assert.Equal(t, 2, len(calendars))
+	for _, c := range calendars {
+		assert.Equal(t, MethodRequest, c.Method)
+		assert.Equal(t, "Quarterly planning", c.Events[0].Summary)
+	}
+}
+
+func TestIsCalendar(t *testing.T) {
+	assert.True(t, IsCalendar(&mailosaur.Attachment{ContentType: "text/calendar; method=REQUEST"}))
+	assert.True(t, IsCalendar(&mailosaur.Attachment{ContentType: "application/ics"}))
+	assert.True(t, IsCalendar(&mailosaur.Attachment{FileName: "Invite.ICS"}))
+	assert.False(t, IsCalendar(&mailosaur.Attachment{FileName: "cat.png", ContentType: "image/png"}))
+	assert.False(t, IsCalendar(nil))
+}
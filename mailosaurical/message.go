@@ -0,0 +1,78 @@
+package mailosaurical
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+
+	"github.com/mailosaur/mailosaur-go"
+)
+
+// IsCalendar reports whether the attachment is an iCalendar part, either by
+// content type or by an .ics file name.
+func IsCalendar(a *mailosaur.Attachment) bool {
+	if a == nil {
+		return false
+	}
+	contentType := strings.ToLower(a.ContentType)
+	if idx := strings.Index(contentType, ";"); idx >= 0 {
+		contentType = contentType[:idx]
+	}
+	return strings.TrimSpace(contentType) == "text/calendar" ||
+		strings.EqualFold(a.ContentType, "application/ics") ||
+		strings.HasSuffix(strings.ToLower(a.FileName), ".ics")
+}
+
+// FromMessage parses every calendar attachment of the message. Attachments
+// with inline content are decoded directly, others are downloaded with the
+// client, which may be nil when all content is inline.
+func FromMessage(client *mailosaur.MailosaurClient, message *mailosaur.Message) ([]*Calendar, error) {
+	var calendars []*Calendar
+
+	for _, a := range message.Attachments {
+		if !IsCalendar(a) {
+			continue
+		}
+
+		content, err := attachmentContent(client, a)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed, err := Parse(bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		calendars = append(calendars, parsed...)
+	}
+
+	return calendars, nil
+}
+
+// FromEmail parses the calendar parts of a raw MIME message, such as one
+// returned by FilesService.GetEmail.
+func FromEmail(raw []byte) ([]*Calendar, error) {
+	message, err := mailosaur.ParseEmail(raw)
+	if err != nil {
+		return nil, err
+	}
+	return FromMessage(nil, message)
+}
+
+// Download fetches the raw source of a message and parses its calendar
+// parts. This also finds inline text/calendar alternatives, which are not
+// listed in Message.Attachments.
+func Download(client *mailosaur.MailosaurClient, messageId string) ([]*Calendar, error) {
+	raw, err := client.Files.GetEmail(messageId)
+	if err != nil {
+		return nil, err
+	}
+	return FromEmail(raw)
+}
+
+func attachmentContent(client *mailosaur.MailosaurClient, a *mailosaur.Attachment) ([]byte, error) {
+	if len(a.Content) > 0 || client == nil || len(a.Id) == 0 {
+		return base64.StdEncoding.DecodeString(a.Content)
+	}
+	return client.Files.GetAttachment(a.Id)
+}
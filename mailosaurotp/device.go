@@ -0,0 +1,43 @@
+package mailosaurotp
+
+import (
+	"fmt"
+
+	"github.com/mailosaur/mailosaur-go"
+)
+
+// CreateDevice adds the key to Mailosaur as a virtual security device, so
+// that codes can be fetched with DevicesService.OtpByDevice. The device is
+// named from the issuer and account when name is empty.
+//
+// Devices only take a shared secret, and generate 6 digit TOTP codes with
+// SHA1 every 30 seconds, so any other key is refused rather than creating
+// a device whose codes would never match. Use Generate or Hotp for those.
+func CreateDevice(client *mailosaur.MailosaurClient, key *Key, name string) (*mailosaur.Device, error) {
+	if err := checkDeviceKey(key); err != nil {
+		return nil, err
+	}
+
+	if len(name) == 0 {
+		name = key.Name()
+	}
+
+	return client.Devices.Create(mailosaur.DeviceCreateOptions{
+		Name:         name,
+		SharedSecret: key.Secret,
+	})
+}
+
+func checkDeviceKey(key *Key) error {
+	switch {
+	case key.Type != TypeTotp:
+		return fmt.Errorf("mailosaurotp: devices only support TOTP keys, not %s", key.Type)
+	case key.Algorithm != "SHA1":
+		return fmt.Errorf("mailosaurotp: devices only support SHA1 keys, not %s", key.Algorithm)
+	case key.Digits != 6:
+		return fmt.Errorf("mailosaurotp: devices only support 6 digit codes, not %d", key.Digits)
+	case key.Period != 30:
+		return fmt.Errorf("mailosaurotp: devices only support a 30 second period, not %d", key.Period)
+	}
+	return nil
+}
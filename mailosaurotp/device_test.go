@@ -0,0 +1,77 @@
+package mailosaurotp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/mailosaur/mailosaur-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// rewriteTransport sends every request to the test server.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestCreateDevice(t *testing.T) {
+	var created mailosaur.DeviceCreateOptions
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/api/devices", r.URL.Path)
+		json.NewDecoder(r.Body).Decode(&created)
+		json.NewEncoder(w).Encode(mailosaur.Device{Id: "d1", Name: created.Name})
+	}))
+	defer srv.Close()
+
+	target, _ := url.Parse(srv.URL)
+	client := mailosaur.NewWithClient("key", &http.Client{Transport: &rewriteTransport{target: target}})
+
+	key, _ := ParseURI(enrollmentURI)
+
+	device, err := CreateDevice(client, key, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "d1", device.Id)
+	assert.Equal(t, "ACME Corp (jo@example.com)", created.Name)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", created.SharedSecret)
+
+	CreateDevice(client, key, "Staging login")
+	assert.Equal(t, "Staging login", created.Name)
+}
+
+func TestCreateDeviceRefusesUnsupportedKeys(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer srv.Close()
+
+	target, _ := url.Parse(srv.URL)
+	client := mailosaur.NewWithClient("key", &http.Client{Transport: &rewriteTransport{target: target}})
+
+	cases := map[string]string{
+		"otpauth://totp/a?secret=JBSWY3DPEHPK3PXP&algorithm=SHA256": "mailosaurotp: devices only support SHA1 keys, not SHA256",
+		"otpauth://totp/a?secret=JBSWY3DPEHPK3PXP&digits=8":         "mailosaurotp: devices only support 6 digit codes, not 8",
+		"otpauth://totp/a?secret=JBSWY3DPEHPK3PXP&period=60":        "mailosaurotp: devices only support a 30 second period, not 60",
+		"otpauth://hotp/a?secret=JBSWY3DPEHPK3PXP&counter=1":        "mailosaurotp: devices only support TOTP keys, not hotp",
+	}
+
+	for uri, expected := range cases {
+		key, err := ParseURI(uri)
+		assert.NoError(t, err, uri)
+
+		device, err := CreateDevice(client, key, "")
+		assert.Nil(t, device, uri)
+		assert.EqualError(t, err, expected, uri)
+	}
+
+	assert.Equal(t, 0, requests)
+}
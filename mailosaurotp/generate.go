@@ -0,0 +1,70 @@
+package mailosaurotp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"time"
+
+	"github.com/mailosaur/mailosaur-go"
+)
+
+var hashes = map[string]func() hash.Hash{
+	"SHA1":   sha1.New,
+	"SHA256": sha256.New,
+	"SHA512": sha512.New,
+}
+
+// Generate returns the TOTP code for the given time, in the same form as
+// DevicesService.Otp, so tests can switch between local and remote codes.
+func (k *Key) Generate(t time.Time) (*mailosaur.OtpResult, error) {
+	if k.Type != TypeTotp {
+		return nil, errors.New("mailosaurotp: Generate requires a TOTP key, use Hotp for HOTP keys")
+	}
+
+	step := t.Unix() / int64(k.Period)
+	code, err := k.Hotp(uint64(step))
+	if err != nil {
+		return nil, err
+	}
+
+	return &mailosaur.OtpResult{
+		Code:    code,
+		Expires: time.Unix((step+1)*int64(k.Period), 0).UTC(),
+	}, nil
+}
+
+// Hotp returns the HOTP code (RFC 4226) for the counter value.
+func (k *Key) Hotp(counter uint64) (string, error) {
+	newHash, ok := hashes[k.Algorithm]
+	if !ok {
+		return "", fmt.Errorf("mailosaurotp: unsupported algorithm %q", k.Algorithm)
+	}
+
+	secret, err := k.secretBytes()
+	if err != nil {
+		return "", err
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(newHash, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint64(1)
+	for i := 0; i < k.Digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", k.Digits, uint64(value)%mod), nil
+}
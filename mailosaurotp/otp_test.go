@@ -0,0 +1,126 @@
+package mailosaurotp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const enrollmentURI = "otpauth://totp/ACME%20Corp:jo%40example.com?secret=JBSWY3DPEHPK3PXP&issuer=ACME%20Corp&algorithm=SHA1&digits=6&period=30"
+
+func TestParseURI(t *testing.T) {
+	key, err := ParseURI(enrollmentURI)
+	assert.NoError(t, err)
+	assert.Equal(t, TypeTotp, key.Type)
+	assert.Equal(t, "ACME Corp", key.Issuer)
+	assert.Equal(t, "jo@example.com", key.Account)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", key.Secret)
+	assert.Equal(t, "SHA1", key.Algorithm)
+	assert.Equal(t, 6, key.Digits)
+	assert.Equal(t, 30, key.Period)
+	assert.Equal(t, "ACME Corp (jo@example.com)", key.Name())
+}
+
+func TestParseURIDefaults(t *testing.T) {
+	key, err := ParseURI("otpauth://totp/Example:alice?secret=jbsw%20y3dp%20ehpk%203pxp")
+	assert.NoError(t, err)
+	assert.Equal(t, "Example", key.Issuer)
+	assert.Equal(t, "alice", key.Account)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", key.Secret)
+	assert.Equal(t, "SHA1", key.Algorithm)
+	assert.Equal(t, 6, key.Digits)
+	assert.Equal(t, 30, key.Period)
+
+	key, err = ParseURI("otpauth://hotp/bob?secret=JBSWY3DPEHPK3PXP&counter=5&algorithm=sha256&digits=8")
+	assert.NoError(t, err)
+	assert.Equal(t, TypeHotp, key.Type)
+	assert.Equal(t, "", key.Issuer)
+	assert.Equal(t, uint64(5), key.Counter)
+	assert.Equal(t, "SHA256", key.Algorithm)
+	assert.Equal(t, 8, key.Digits)
+	assert.Equal(t, "bob", key.Name())
+}
+
+func TestParseURIErrors(t *testing.T) {
+	for _, uri := range []string{
+		"https://example.com/?secret=JBSWY3DPEHPK3PXP",
+		"otpauth://motp/alice?secret=JBSWY3DPEHPK3PXP",
+		"otpauth://totp/alice",
+		"otpauth://totp/alice?secret=not-base32!",
+		"otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&algorithm=MD5",
+		"otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&digits=4",
+		"otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&period=0",
+		"otpauth://hotp/alice?secret=JBSWY3DPEHPK3PXP",
+	} {
+		_, err := ParseURI(uri)
+		assert.Error(t, err, uri)
+	}
+}
+
+func TestReadImage(t *testing.T) {
+	for _, path := range []string{"testdata/enrollment.png", "testdata/enrollment.jpg"} {
+		key, err := ReadImageFile(path)
+		assert.NoError(t, err, path)
+		if assert.NotNil(t, key, path) {
+			assert.Equal(t, "ACME Corp", key.Issuer)
+			assert.Equal(t, "jo@example.com", key.Account)
+			assert.Equal(t, "JBSWY3DPEHPK3PXP", key.Secret)
+		}
+	}
+}
+
+func rfcKey(algorithm string, secret string) *Key {
+	return &Key{
+		Type:      TypeTotp,
+		Secret:    base32.StdEncoding.EncodeToString([]byte(secret)),
+		Algorithm: algorithm,
+		Digits:    8,
+		Period:    30,
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	// Test vectors from RFC 6238
+	sha1Key := rfcKey("SHA1", "12345678901234567890")
+	sha256Key := rfcKey("SHA256", "12345678901234567890123456789012")
+	sha512Key := rfcKey("SHA512", "1234567890123456789012345678901234567890123456789012345678901234")
+
+	tests := []struct {
+		key  *Key
+		time int64
+		code string
+	}{
+		{sha1Key, 59, "94287082"},
+		{sha256Key, 59, "46119246"},
+		{sha512Key, 59, "90693936"},
+		{sha1Key, 1111111109, "07081804"},
+		{sha256Key, 1111111109, "68084774"},
+		{sha512Key, 1111111109, "25091201"},
+		{sha1Key, 20000000000, "65353130"},
+	}
+
+	for _, test := range tests {
+		result, err := test.key.Generate(time.Unix(test.time, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, test.code, result.Code, test.key.Algorithm)
+	}
+
+	result, _ := sha1Key.Generate(time.Unix(59, 0))
+	assert.Equal(t, time.Unix(60, 0).UTC(), result.Expires)
+}
+
+func TestHotp(t *testing.T) {
+	// Test vectors from RFC 4226
+	key := &Key{Type: TypeHotp, Secret: base32.StdEncoding.EncodeToString([]byte("12345678901234567890")), Algorithm: "SHA1", Digits: 6}
+
+	for counter, expected := range []string{"755224", "287082", "359152", "969429", "338314"} {
+		code, err := key.Hotp(uint64(counter))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code)
+	}
+
+	_, err := key.Generate(time.Now())
+	assert.Error(t, err)
+}
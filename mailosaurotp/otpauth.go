@@ -0,0 +1,135 @@
+// Package mailosaurotp reads MFA enrollment data from otpauth:// URIs or
+// images of their QR codes, creates Mailosaur devices from it and
+// generates one-time passwords locally.
+package mailosaurotp
+
+import (
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	TypeTotp = "totp"
+	TypeHotp = "hotp"
+)
+
+// Key is the enrollment data of an authenticator, as defined by the Key
+// Uri Format used by Google Authenticator and most MFA providers.
+type Key struct {
+	Type    string
+	Issuer  string
+	Account string
+	// Secret is base32 encoded, in upper case without padding or spaces
+	Secret    string
+	Algorithm string
+	Digits    int
+	Period    int
+	Counter   uint64
+}
+
+// ParseURI parses an otpauth:// URI, applying the defaults of SHA1, 6
+// digits and a 30 second period for parameters that are not given.
+func ParseURI(uri string) (*Key, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.EqualFold(u.Scheme, "otpauth") {
+		return nil, fmt.Errorf("mailosaurotp: expected an otpauth:// URI but got scheme %q", u.Scheme)
+	}
+
+	key := &Key{
+		Type:      strings.ToLower(u.Host),
+		Algorithm: "SHA1",
+		Digits:    6,
+		Period:    30,
+	}
+
+	if key.Type != TypeTotp && key.Type != TypeHotp {
+		return nil, fmt.Errorf("mailosaurotp: unsupported OTP type %q", u.Host)
+	}
+
+	// The label is "issuer:account" or just "account"
+	label := strings.TrimPrefix(u.Path, "/")
+	if idx := strings.Index(label, ":"); idx >= 0 {
+		key.Issuer = strings.TrimSpace(label[:idx])
+		key.Account = strings.TrimSpace(label[idx+1:])
+	} else {
+		key.Account = strings.TrimSpace(label)
+	}
+
+	q := u.Query()
+
+	// The issuer parameter takes precedence over the label prefix
+	if issuer := q.Get("issuer"); len(issuer) > 0 {
+		key.Issuer = issuer
+	}
+
+	key.Secret = normaliseSecret(q.Get("secret"))
+	if len(key.Secret) == 0 {
+		return nil, errors.New("mailosaurotp: the URI has no secret")
+	}
+	if _, err := key.secretBytes(); err != nil {
+		return nil, fmt.Errorf("mailosaurotp: the secret is not valid base32: %s", err)
+	}
+
+	if algorithm := q.Get("algorithm"); len(algorithm) > 0 {
+		key.Algorithm = strings.ToUpper(algorithm)
+		if _, ok := hashes[key.Algorithm]; !ok {
+			return nil, fmt.Errorf("mailosaurotp: unsupported algorithm %q", algorithm)
+		}
+	}
+
+	if digits := q.Get("digits"); len(digits) > 0 {
+		key.Digits, err = strconv.Atoi(digits)
+		if err != nil || key.Digits < 6 || key.Digits > 10 {
+			return nil, fmt.Errorf("mailosaurotp: invalid digits %q", digits)
+		}
+	}
+
+	if period := q.Get("period"); len(period) > 0 {
+		key.Period, err = strconv.Atoi(period)
+		if err != nil || key.Period <= 0 {
+			return nil, fmt.Errorf("mailosaurotp: invalid period %q", period)
+		}
+	}
+
+	if key.Type == TypeHotp {
+		counter := q.Get("counter")
+		if len(counter) == 0 {
+			return nil, errors.New("mailosaurotp: an HOTP URI must have a counter")
+		}
+		key.Counter, err = strconv.ParseUint(counter, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("mailosaurotp: invalid counter %q", counter)
+		}
+	}
+
+	return key, nil
+}
+
+// Name returns a device name for the key, such as "ACME (jo@example.com)".
+func (k *Key) Name() string {
+	switch {
+	case len(k.Issuer) > 0 && len(k.Account) > 0:
+		return k.Issuer + " (" + k.Account + ")"
+	case len(k.Issuer) > 0:
+		return k.Issuer
+	default:
+		return k.Account
+	}
+}
+
+func (k *Key) secretBytes() ([]byte, error) {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(normaliseSecret(k.Secret))
+}
+
+func normaliseSecret(secret string) string {
+	secret = strings.ToUpper(strings.Replace(secret, " ", "", -1))
+	return strings.TrimRight(secret, "=")
+}
@@ -0,0 +1,41 @@
+package mailosaurotp
+
+import (
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+
+	"github.com/mailosaur/mailosaur-go/internal/qrcode"
+)
+
+// ReadImage decodes the QR code in a PNG or JPEG image and parses the
+// otpauth:// URI it contains.
+func ReadImage(r io.Reader) (*Key, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	return FromImage(img)
+}
+
+func ReadImageFile(path string) (*Key, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadImage(f)
+}
+
+// FromImage decodes an image already in memory, such as a screenshot
+// taken by a browser automation tool.
+func FromImage(img image.Image) (*Key, error) {
+	uri, err := qrcode.Decode(img)
+	if err != nil {
+		return nil, err
+	}
+	return ParseURI(uri)
+}
@@ -0,0 +1,293 @@
+package mailosaurpolicy
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mailosaur/mailosaur-go"
+	"github.com/mailosaur/mailosaur-go/mailosaurreport"
+)
+
+type Violation struct {
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+func (v *Violation) String() string {
+	return v.Rule + ": " + v.Message
+}
+
+// Check is the outcome of a single policy rule. Violation is nil when the
+// check passed.
+type Check struct {
+	Rule      string     `json:"rule"`
+	Violation *Violation `json:"violation,omitempty"`
+}
+
+type Result struct {
+	Policy string   `json:"policy,omitempty"`
+	Checks []*Check `json:"checks"`
+}
+
+func (r *Result) Passed() bool {
+	return len(r.Violations()) == 0
+}
+
+func (r *Result) Violations() []*Violation {
+	var violations []*Violation
+	for _, c := range r.Checks {
+		if c.Violation != nil {
+			violations = append(violations, c.Violation)
+		}
+	}
+	return violations
+}
+
+// Err returns nil if the policy passed, or an error listing every violation.
+func (r *Result) Err() error {
+	violations := r.Violations()
+	if len(violations) == 0 {
+		return nil
+	}
+
+	lines := make([]string, 0, len(violations))
+	for _, v := range violations {
+		lines = append(lines, v.String())
+	}
+
+	name := r.Policy
+	if len(name) == 0 {
+		name = "deliverability"
+	}
+
+	return fmt.Errorf("%s policy failed with %d violation(s):\n  %s", name, len(violations), strings.Join(lines, "\n  "))
+}
+
+// JUnit converts the result to a test suite with one test case per check.
+func (r *Result) JUnit() *mailosaurreport.JUnitTestSuite {
+	name := r.Policy
+	if len(name) == 0 {
+		name = "deliverability"
+	}
+
+	suite := &mailosaurreport.JUnitTestSuite{Name: name}
+	for _, c := range r.Checks {
+		tc := &mailosaurreport.JUnitTestCase{Name: c.Rule, Classname: name}
+		if v := c.Violation; v != nil {
+			text := v.Message
+			if len(v.Expected) > 0 || len(v.Actual) > 0 {
+				text += "\nexpected: " + v.Expected + "\nactual: " + v.Actual
+			}
+			tc.Failure = &mailosaurreport.JUnitFailure{Message: v.Message, Type: "PolicyViolation", Text: text}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Count()
+
+	return suite
+}
+
+func (r *Result) WriteJUnit(w io.Writer) error {
+	return mailosaurreport.WriteJUnit(w, "mailosaur", r.JUnit())
+}
+
+// Evaluate checks a deliverability report against the policy. The spam
+// analysis is optional; when given, its score is used in place of the
+// report's rounded SpamAssassin score.
+func (p *Policy) Evaluate(report *mailosaur.DeliverabilityReport, spam *mailosaur.SpamAnalysisResult) *Result {
+	if report == nil {
+		report = &mailosaur.DeliverabilityReport{}
+	}
+
+	r := &Result{Policy: p.Name}
+	add := func(rule string, v *Violation) {
+		if v != nil {
+			v.Rule = rule
+		}
+		r.Checks = append(r.Checks, &Check{Rule: rule, Violation: v})
+	}
+
+	if p.Spf != nil {
+		add("spf", checkAuth("SPF", p.Spf, report.Spf))
+	}
+
+	if p.RequireDkim {
+		var v *Violation
+		if len(report.Dkim) == 0 {
+			v = &Violation{Message: "message has no DKIM signature"}
+		}
+		add("dkim", v)
+	}
+	if p.Dkim != nil {
+		for i, dkim := range report.Dkim {
+			add("dkim["+strconv.Itoa(i)+"]", checkAuth("DKIM signature "+strconv.Itoa(i+1), p.Dkim, dkim))
+		}
+	}
+
+	if p.Dmarc != nil {
+		add("dmarc", checkAuth("DMARC", p.Dmarc, report.Dmarc))
+	}
+
+	if p.MaxBlockListHits != nil {
+		var listed []string
+		for _, b := range report.BlockLists {
+			if mailosaurreport.BlockListed(b) {
+				listed = append(listed, b.Name)
+			}
+		}
+
+		var v *Violation
+		if len(listed) > *p.MaxBlockListHits {
+			v = &Violation{
+				Message:  fmt.Sprintf("listed on %d block list(s): %s", len(listed), strings.Join(listed, ", ")),
+				Expected: "at most " + strconv.Itoa(*p.MaxBlockListHits),
+				Actual:   strconv.Itoa(len(listed)),
+			}
+		}
+		add("blockLists", v)
+	}
+
+	for _, flag := range p.ForbidContent {
+		rule := "content." + canonicalFlag(flag)
+		if report.Content == nil {
+			add(rule, &Violation{Message: "content analysis is missing from the report"})
+			continue
+		}
+
+		var v *Violation
+		if contentFlag(report.Content, flag) {
+			v = &Violation{Message: "content flag " + canonicalFlag(flag) + " is set", Expected: "false", Actual: "true"}
+		}
+		add(rule, v)
+	}
+
+	if p.MaxTotalSize > 0 {
+		add("content.totalSize", checkSize("total size", p.MaxTotalSize, report.Content, func(c *mailosaur.Content) int { return c.TotalSize }))
+	}
+	if p.MaxTextSize > 0 {
+		add("content.textSize", checkSize("text size", p.MaxTextSize, report.Content, func(c *mailosaur.Content) int { return c.TextSize }))
+	}
+
+	if p.MaxSpamScore != nil || len(p.ForbidSpamRules) > 0 {
+		score, rules, ok := spamAssassin(report, spam)
+
+		if p.MaxSpamScore != nil {
+			var v *Violation
+			if !ok {
+				v = &Violation{Message: "SpamAssassin results are missing"}
+			} else if score >= *p.MaxSpamScore {
+				v = &Violation{
+					Message:  "SpamAssassin score " + mailosaurreport.FormatScore(score) + " is not under " + mailosaurreport.FormatScore(*p.MaxSpamScore),
+					Expected: "< " + mailosaurreport.FormatScore(*p.MaxSpamScore),
+					Actual:   mailosaurreport.FormatScore(score),
+				}
+			}
+			add("spamScore", v)
+		}
+
+		for _, name := range p.ForbidSpamRules {
+			var v *Violation
+			for _, rule := range rules {
+				if rule != nil && strings.EqualFold(rule.Rule, name) {
+					v = &Violation{Message: "SpamAssassin rule " + rule.Rule + " was triggered (" + mailosaurreport.FormatScore(rule.Score) + ")"}
+					break
+				}
+			}
+			add("spamRule."+name, v)
+		}
+	}
+
+	return r
+}
+
+func checkAuth(name string, rule *AuthRule, result *mailosaur.EmailAuthenticationResult) *Violation {
+	if result == nil {
+		return &Violation{Message: name + " result is missing", Expected: expectation(rule), Actual: "missing"}
+	}
+
+	if containsFold(rule.Deny, result.Result) || (len(rule.Allow) > 0 && !containsFold(rule.Allow, result.Result)) {
+		message := name + " result was " + result.Result
+		if len(result.Description) > 0 {
+			message += " (" + result.Description + ")"
+		}
+		return &Violation{Message: message, Expected: expectation(rule), Actual: result.Result}
+	}
+
+	return nil
+}
+
+func expectation(rule *AuthRule) string {
+	var parts []string
+	if len(rule.Allow) > 0 {
+		parts = append(parts, "one of "+strings.Join(rule.Allow, ", "))
+	}
+	if len(rule.Deny) > 0 {
+		parts = append(parts, "not "+strings.Join(rule.Deny, ", "))
+	}
+	return strings.Join(parts, " and ")
+}
+
+func checkSize(name string, limit int, content *mailosaur.Content, size func(*mailosaur.Content) int) *Violation {
+	if content == nil {
+		return &Violation{Message: "content analysis is missing from the report"}
+	}
+
+	actual := size(content)
+	if actual >= limit {
+		return &Violation{
+			Message:  name + " of " + strconv.Itoa(actual) + " bytes is not under " + strconv.Itoa(limit),
+			Expected: "< " + strconv.Itoa(limit),
+			Actual:   strconv.Itoa(actual),
+		}
+	}
+	return nil
+}
+
+func spamAssassin(report *mailosaur.DeliverabilityReport, spam *mailosaur.SpamAnalysisResult) (float64, []*mailosaur.SpamAssassinRule, bool) {
+	if spam != nil {
+		var rules []*mailosaur.SpamAssassinRule
+		if spam.SpamFilterResults != nil {
+			rules = spam.SpamFilterResults.SpamAssassin
+		}
+		return spam.Score, rules, true
+	}
+
+	if report.SpamAssassin != nil {
+		return float64(report.SpamAssassin.Score), report.SpamAssassin.Rules, true
+	}
+
+	return 0, nil, false
+}
+
+func canonicalFlag(flag string) string {
+	for _, f := range contentFlags {
+		if strings.EqualFold(f, flag) {
+			return f
+		}
+	}
+	return flag
+}
+
+func contentFlag(c *mailosaur.Content, flag string) bool {
+	switch canonicalFlag(flag) {
+	case "embed":
+		return c.Embed
+	case "iframe":
+		return c.Iframe
+	case "object":
+		return c.Object
+	case "script":
+		return c.Script
+	case "shortUrls":
+		return c.ShortUrls
+	case "missingAlt":
+		return c.MissingAlt
+	case "missingListUnsubscribe":
+		return c.MissingListUnsubscribe
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+// Package mailosaurpolicy evaluates deliverability reports against a declared
+// policy, so that email templates can be gated in CI.
+package mailosaurpolicy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AuthRule restricts the result of an SPF, DKIM or DMARC check. Results are
+// compared case-insensitively. When Allow is set the result must be one of
+// its values, and it must never be one of the Deny values.
+type AuthRule struct {
+	Allow []string `json:"allow,omitempty" yaml:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty" yaml:"deny,omitempty"`
+}
+
+type Policy struct {
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+
+	Spf   *AuthRule `json:"spf,omitempty" yaml:"spf,omitempty"`
+	Dmarc *AuthRule `json:"dmarc,omitempty" yaml:"dmarc,omitempty"`
+
+	// Dkim is applied to every DKIM signature. RequireDkim additionally
+	// fails messages that carry no signature at all.
+	Dkim        *AuthRule `json:"dkim,omitempty" yaml:"dkim,omitempty"`
+	RequireDkim bool      `json:"requireDkim,omitempty" yaml:"requireDkim,omitempty"`
+
+	// MaxBlockListHits is the number of block lists the sender may appear
+	// on. Nil disables the check.
+	MaxBlockListHits *int `json:"maxBlockListHits,omitempty" yaml:"maxBlockListHits,omitempty"`
+
+	// ForbidContent lists content flags that must not be set, using their
+	// JSON names: embed, iframe, object, script, shortUrls, missingAlt and
+	// missingListUnsubscribe.
+	ForbidContent []string `json:"forbidContent,omitempty" yaml:"forbidContent,omitempty"`
+
+	// MaxTotalSize and MaxTextSize are in bytes. Zero disables the check.
+	MaxTotalSize int `json:"maxTotalSize,omitempty" yaml:"maxTotalSize,omitempty"`
+	MaxTextSize  int `json:"maxTextSize,omitempty" yaml:"maxTextSize,omitempty"`
+
+	// MaxSpamScore is the highest SpamAssassin score allowed. Nil disables
+	// the check.
+	MaxSpamScore *float64 `json:"maxSpamScore,omitempty" yaml:"maxSpamScore,omitempty"`
+
+	// ForbidSpamRules lists SpamAssassin rules that must not be triggered.
+	ForbidSpamRules []string `json:"forbidSpamRules,omitempty" yaml:"forbidSpamRules,omitempty"`
+}
+
+var contentFlags = []string{
+	"embed",
+	"iframe",
+	"object",
+	"script",
+	"shortUrls",
+	"missingAlt",
+	"missingListUnsubscribe",
+}
+
+// Load reads a policy from a .json, .yaml or .yml file.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ParseJSON(data)
+	case ".yaml", ".yml":
+		return ParseYAML(data)
+	default:
+		return nil, fmt.Errorf("unsupported policy file type %q", filepath.Ext(path))
+	}
+}
+
+func ParseJSON(data []byte) (*Policy, error) {
+	p := &Policy{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(p); err != nil {
+		return nil, err
+	}
+	return p, p.Validate()
+}
+
+func ParseYAML(data []byte) (*Policy, error) {
+	p := &Policy{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(p); err != nil {
+		return nil, err
+	}
+	return p, p.Validate()
+}
+
+func (p *Policy) Validate() error {
+	for _, f := range p.ForbidContent {
+		if !containsFold(contentFlags, f) {
+			return fmt.Errorf("unknown content flag %q, expected one of %s", f, strings.Join(contentFlags, ", "))
+		}
+	}
+	if p.MaxBlockListHits != nil && *p.MaxBlockListHits < 0 {
+		return fmt.Errorf("maxBlockListHits must not be negative")
+	}
+	return nil
+}
+
+func containsFold(list []string, value string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
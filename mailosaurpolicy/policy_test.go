@@ -0,0 +1,144 @@
+package mailosaurpolicy
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mailosaur/mailosaur-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func passingReport() *mailosaur.DeliverabilityReport {
+	return &mailosaur.DeliverabilityReport{
+		Spf:   &mailosaur.EmailAuthenticationResult{Result: "Pass"},
+		Dkim:  []*mailosaur.EmailAuthenticationResult{{Result: "Pass"}},
+		Dmarc: &mailosaur.EmailAuthenticationResult{Result: "Pass"},
+		BlockLists: []*mailosaur.BlockListResult{
+			{Id: "spamhaus", Name: "Spamhaus", Result: "NotListed"},
+		},
+		Content:      &mailosaur.Content{TotalSize: 2048, TextSize: 512},
+		SpamAssassin: &mailosaur.SpamAssassinResult{Score: 1},
+	}
+}
+
+func failingReport() *mailosaur.DeliverabilityReport {
+	return &mailosaur.DeliverabilityReport{
+		Spf: &mailosaur.EmailAuthenticationResult{Result: "SoftFail", Description: "Sender not authorized"},
+		Dkim: []*mailosaur.EmailAuthenticationResult{
+			{Result: "Pass"},
+			{Result: "Fail"},
+		},
+		Dmarc: &mailosaur.EmailAuthenticationResult{Result: "Fail"},
+		BlockLists: []*mailosaur.BlockListResult{
+			{Id: "spamhaus", Name: "Spamhaus", Result: "Listed"},
+		},
+		Content: &mailosaur.Content{TotalSize: 204800, MissingListUnsubscribe: true},
+		SpamAssassin: &mailosaur.SpamAssassinResult{
+			Score: 7,
+			Rules: []*mailosaur.SpamAssassinRule{{Rule: "MIME_HTML_ONLY", Score: 2.1}},
+		},
+	}
+}
+
+func TestLoad(t *testing.T) {
+	for _, path := range []string{"testdata/marketing.yaml", "testdata/marketing.json"} {
+		p, err := Load(path)
+		assert.NoError(t, err, path)
+
+		assert.Equal(t, "marketing", p.Name)
+		assert.Equal(t, []string{"Pass"}, p.Spf.Allow)
+		assert.Equal(t, []string{"Fail"}, p.Dmarc.Deny)
+		assert.True(t, p.RequireDkim)
+		assert.Equal(t, 0, *p.MaxBlockListHits)
+		assert.Equal(t, []string{"missingListUnsubscribe", "script"}, p.ForbidContent)
+		assert.Equal(t, 102400, p.MaxTotalSize)
+		assert.Equal(t, 5.0, *p.MaxSpamScore)
+	}
+}
+
+func TestParseRejectsUnknownFields(t *testing.T) {
+	_, err := ParseYAML([]byte("maxSpamScroe: 5\n"))
+	assert.Error(t, err)
+
+	_, err = ParseJSON([]byte(`{"maxSpamScroe": 5}`))
+	assert.Error(t, err)
+
+	_, err = ParseJSON([]byte(`{"forbidContent": ["blink"]}`))
+	assert.EqualError(t, err, `unknown content flag "blink", expected one of embed, iframe, object, script, shortUrls, missingAlt, missingListUnsubscribe`)
+}
+
+func TestEvaluatePassing(t *testing.T) {
+	p, _ := Load("testdata/marketing.yaml")
+	result := p.Evaluate(passingReport(), nil)
+
+	assert.True(t, result.Passed())
+	assert.NoError(t, result.Err())
+	assert.Empty(t, result.Violations())
+	assert.Equal(t, 10, len(result.Checks))
+}
+
+func TestEvaluateFailing(t *testing.T) {
+	p, _ := Load("testdata/marketing.yaml")
+	result := p.Evaluate(failingReport(), nil)
+
+	assert.False(t, result.Passed())
+
+	rules := []string{}
+	for _, v := range result.Violations() {
+		rules = append(rules, v.Rule)
+	}
+	assert.Equal(t, []string{
+		"spf",
+		"dkim[1]",
+		"dmarc",
+		"blockLists",
+		"content.missingListUnsubscribe",
+		"content.totalSize",
+		"spamScore",
+		"spamRule.MIME_HTML_ONLY",
+	}, rules)
+
+	v := result.Violations()[0]
+	assert.Equal(t, "SPF result was SoftFail (Sender not authorized)", v.Message)
+	assert.Equal(t, "one of Pass", v.Expected)
+	assert.Equal(t, "SoftFail", v.Actual)
+
+	assert.Contains(t, result.Err().Error(), "marketing policy failed with 8 violation(s):")
+}
+
+func TestEvaluateSpamScorePrefersSpamAnalysis(t *testing.T) {
+	max := 3.0
+	p := &Policy{MaxSpamScore: &max}
+
+	result := p.Evaluate(passingReport(), &mailosaur.SpamAnalysisResult{Score: 3.2})
+	assert.False(t, result.Passed())
+	assert.Equal(t, "SpamAssassin score 3.2 is not under 3", result.Violations()[0].Message)
+}
+
+func TestEvaluateMissingData(t *testing.T) {
+	p := &Policy{
+		Spf:         &AuthRule{Allow: []string{"Pass"}},
+		RequireDkim: true,
+	}
+
+	result := p.Evaluate(&mailosaur.DeliverabilityReport{}, nil)
+	assert.Equal(t, 2, len(result.Violations()))
+	assert.Equal(t, "SPF result is missing", result.Violations()[0].Message)
+	assert.Equal(t, "message has no DKIM signature", result.Violations()[1].Message)
+}
+
+func TestWriteJUnit(t *testing.T) {
+	p, _ := Load("testdata/marketing.yaml")
+	result := p.Evaluate(failingReport(), nil)
+
+	var buf bytes.Buffer
+	assert.NoError(t, result.WriteJUnit(&buf))
+
+	xml := buf.String()
+	assert.True(t, strings.HasPrefix(xml, `<?xml version="1.0" encoding="UTF-8"?>`))
+	assert.Contains(t, xml, `<testsuites name="mailosaur" tests="11" failures="8" errors="0" time="0">`)
+	assert.Contains(t, xml, `<testsuite name="marketing" tests="11" failures="8"`)
+	assert.Contains(t, xml, `<testcase name="dkim[0]" classname="marketing" time="0"></testcase>`)
+	assert.Contains(t, xml, `<failure message="DMARC result was Fail" type="PolicyViolation">DMARC result was Fail&#xA;expected: not Fail&#xA;actual: Fail</failure>`)
+}
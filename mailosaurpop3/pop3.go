@@ -0,0 +1,310 @@
+// Package mailosaurpop3 is a small POP3 client (RFC 1939) for reading a
+// server's mail over the mail protocol, with STARTTLS (RFC 2595) and
+// implicit TLS. Retrieved messages are parsed with mailosaur.ParseEmail, so
+// they can be checked in the same way as messages from the API.
+package mailosaurpop3
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/mailosaur/mailosaur-go"
+)
+
+// Error is a -ERR response from the server.
+type Error struct {
+	Command string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return "pop3: " + e.Command + " failed: " + e.Message
+}
+
+// MessageInfo is an entry from LIST.
+type MessageInfo struct {
+	Number int
+	Size   int
+}
+
+type Client struct {
+	conn net.Conn
+	text *textproto.Conn
+	host string
+	tls  bool
+}
+
+// Dial connects without TLS. Use StartTLS before authenticating unless the
+// server is local.
+func Dial(addr string) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn, hostOf(addr))
+}
+
+// DialTLS connects using implicit TLS, usually on port 995.
+func DialTLS(addr string, config *tls.Config) (*Client, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, withServerName(config, hostOf(addr)))
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn, hostOf(addr))
+}
+
+// Connect dials the endpoint from ServersService.ConnectionDetails using
+// its TLS mode, and logs in with its credentials.
+func Connect(endpoint *mailosaur.Endpoint, config *tls.Config) (*Client, error) {
+	var c *Client
+	var err error
+
+	if endpoint.Tls == mailosaur.TlsImplicit {
+		c, err = DialTLS(endpoint.Addr(), config)
+	} else {
+		c, err = Dial(endpoint.Addr())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if endpoint.Tls == mailosaur.TlsStartTls {
+		if err = c.StartTLS(config); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	if err = c.Auth(endpoint.Username, endpoint.Password); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// NewClient uses an existing connection, reading the server greeting.
+func NewClient(conn net.Conn, host string) (*Client, error) {
+	_, isTls := conn.(*tls.Conn)
+	c := &Client{conn: conn, text: textproto.NewConn(conn), host: host, tls: isTls}
+
+	if _, err := c.response("greeting"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// StartTLS upgrades the connection with the STLS command.
+func (c *Client) StartTLS(config *tls.Config) error {
+	if _, err := c.cmd("STLS"); err != nil {
+		return err
+	}
+
+	tlsConn := tls.Client(c.conn, withServerName(config, c.host))
+	if err := tlsConn.Handshake(); err != nil {
+		return err
+	}
+
+	c.conn = tlsConn
+	c.text = textproto.NewConn(tlsConn)
+	c.tls = true
+	return nil
+}
+
+// TLS reports whether the connection is encrypted.
+func (c *Client) TLS() bool {
+	return c.tls
+}
+
+// Auth logs in with USER and PASS.
+func (c *Client) Auth(username, password string) error {
+	if _, err := c.cmd("USER %s", username); err != nil {
+		return err
+	}
+	_, err := c.cmd("PASS %s", password)
+	return err
+}
+
+// Stat returns the number of messages in the mailbox and their total size
+// in bytes.
+func (c *Client) Stat() (int, int, error) {
+	line, err := c.cmd("STAT")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var count, size int
+	if _, err := fmt.Sscanf(line, "%d %d", &count, &size); err != nil {
+		return 0, 0, fmt.Errorf("pop3: malformed STAT response %q", line)
+	}
+	return count, size, nil
+}
+
+// List returns the number and size of every message in the mailbox.
+func (c *Client) List() ([]MessageInfo, error) {
+	if _, err := c.cmd("LIST"); err != nil {
+		return nil, err
+	}
+
+	lines, err := c.readLines()
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]MessageInfo, 0, len(lines))
+	for _, line := range lines {
+		var info MessageInfo
+		if _, err := fmt.Sscanf(line, "%d %d", &info.Number, &info.Size); err != nil {
+			return nil, fmt.Errorf("pop3: malformed LIST entry %q", line)
+		}
+		messages = append(messages, info)
+	}
+	return messages, nil
+}
+
+// Retr returns the raw content of a message.
+func (c *Client) Retr(number int) ([]byte, error) {
+	if _, err := c.cmd("RETR %d", number); err != nil {
+		return nil, err
+	}
+	return c.readContent()
+}
+
+// Top returns the headers of a message and the first lines of its body.
+func (c *Client) Top(number int, lines int) ([]byte, error) {
+	if _, err := c.cmd("TOP %d %d", number, lines); err != nil {
+		return nil, err
+	}
+	return c.readContent()
+}
+
+// Message retrieves a message and parses it into the same form as messages
+// returned by the API.
+func (c *Client) Message(number int) (*mailosaur.Message, error) {
+	raw, err := c.Retr(number)
+	if err != nil {
+		return nil, err
+	}
+	return mailosaur.ParseEmail(raw)
+}
+
+// Dele marks a message for deletion. Messages are only deleted when the
+// session ends with Quit.
+func (c *Client) Dele(number int) error {
+	_, err := c.cmd("DELE %d", number)
+	return err
+}
+
+// Rset unmarks any messages marked for deletion.
+func (c *Client) Rset() error {
+	_, err := c.cmd("RSET")
+	return err
+}
+
+func (c *Client) Noop() error {
+	_, err := c.cmd("NOOP")
+	return err
+}
+
+// Quit ends the session, deleting any messages marked with Dele, and
+// closes the connection.
+func (c *Client) Quit() error {
+	_, err := c.cmd("QUIT")
+	c.text.Close()
+	return err
+}
+
+// Close closes the connection without deleting messages.
+func (c *Client) Close() error {
+	return c.text.Close()
+}
+
+func (c *Client) cmd(format string, args ...interface{}) (string, error) {
+	command := strings.SplitN(format, " ", 2)[0]
+
+	// A line break would let an argument send commands of its own
+	for _, arg := range args {
+		if s, ok := arg.(string); ok && strings.ContainsAny(s, "\r\n") {
+			return "", fmt.Errorf("pop3: %s argument must not contain a line break", command)
+		}
+	}
+
+	if err := c.text.PrintfLine(format, args...); err != nil {
+		return "", err
+	}
+
+	return c.response(command)
+}
+
+func (c *Client) response(command string) (string, error) {
+	line, err := c.text.ReadLine()
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case strings.HasPrefix(line, "+OK"):
+		return strings.TrimSpace(line[3:]), nil
+	case strings.HasPrefix(line, "-ERR"):
+		return "", &Error{Command: command, Message: strings.TrimSpace(line[4:])}
+	default:
+		return "", fmt.Errorf("pop3: unexpected response %q", line)
+	}
+}
+
+// readLines reads a multi-line response up to the terminating ".",
+// removing dot-stuffing.
+func (c *Client) readLines() ([]string, error) {
+	var lines []string
+	for {
+		line, err := c.text.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "." {
+			return lines, nil
+		}
+		lines = append(lines, strings.TrimPrefix(line, "."))
+	}
+}
+
+// readContent reads a multi-line message, keeping its CRLF line endings.
+func (c *Client) readContent() ([]byte, error) {
+	lines, err := c.readLines()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.WriteString(line)
+		buf.WriteString("\r\n")
+	}
+	return buf.Bytes(), nil
+}
+
+func withServerName(config *tls.Config, host string) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	}
+	if len(config.ServerName) == 0 {
+		config = config.Clone()
+		config.ServerName = host
+	}
+	return config
+}
+
+func hostOf(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
@@ -0,0 +1,361 @@
+package mailosaurpop3
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mailosaur/mailosaur-go"
+	"github.com/stretchr/testify/assert"
+)
+
+const welcomeEmail = "From: Mailosaur <noreply@example.com>\r\n" +
+	"To: jo@s1.mailosaur.net\r\n" +
+	"Subject: Welcome\r\n" +
+	"Date: Mon, 02 Jan 2006 15:04:05 +0000\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"Visit https://example.com/verify to verify your account.\r\n" +
+	".. and this line starts with a dot\r\n"
+
+const resetEmail = "From: noreply@example.com\r\n" +
+	"Subject: Reset your password\r\n" +
+	"\r\n" +
+	"Your code is 123456\r\n"
+
+// standIn is a minimal in-process POP3 server.
+type standIn struct {
+	listener net.Listener
+	tls      *tls.Config
+	implicit bool
+
+	mu       sync.Mutex
+	messages []string
+	deleted  map[int]bool
+	commands []string
+}
+
+func startStandIn(t *testing.T, config *tls.Config, implicit bool, messages ...string) *standIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if implicit {
+		listener = tls.NewListener(listener, config)
+	}
+
+	s := &standIn{listener: listener, tls: config, implicit: implicit, messages: messages}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *standIn) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *standIn) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	write := func(format string, args ...interface{}) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+
+	write("+OK stand-in ready")
+
+	user := ""
+	authenticated := false
+	deleted := map[int]bool{}
+
+	message := func(arg string) (int, string, bool) {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > len(s.messages) || deleted[n] {
+			write("-ERR no such message")
+			return 0, "", false
+		}
+		return n, s.messages[n-1], true
+	}
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		command := strings.ToUpper(fields[0])
+
+		s.mu.Lock()
+		s.commands = append(s.commands, command)
+		s.mu.Unlock()
+
+		if !authenticated && command != "USER" && command != "PASS" && command != "STLS" && command != "QUIT" {
+			write("-ERR authenticate first")
+			continue
+		}
+
+		switch command {
+		case "STLS":
+			if s.tls == nil || s.implicit {
+				write("-ERR TLS not available")
+				continue
+			}
+			write("+OK begin TLS")
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			r = bufio.NewReader(conn)
+		case "USER":
+			user = fields[1]
+			write("+OK")
+		case "PASS":
+			if user == "s1@mailosaur.net" && len(fields) > 1 && fields[1] == "secret" {
+				authenticated = true
+				write("+OK logged in")
+			} else {
+				write("-ERR invalid credentials")
+			}
+		case "STAT":
+			count, size := 0, 0
+			for i, m := range s.messages {
+				if !deleted[i+1] {
+					count++
+					size += len(m)
+				}
+			}
+			write("+OK %d %d", count, size)
+		case "LIST":
+			write("+OK")
+			for i, m := range s.messages {
+				if !deleted[i+1] {
+					write("%d %d", i+1, len(m))
+				}
+			}
+			write(".")
+		case "RETR", "TOP":
+			if len(fields) < 2 {
+				write("-ERR missing argument")
+				continue
+			}
+			_, content, ok := message(fields[1])
+			if !ok {
+				continue
+			}
+			lines := strings.Split(strings.TrimSuffix(content, "\r\n"), "\r\n")
+			if command == "TOP" {
+				n, _ := strconv.Atoi(fields[2])
+				for i, l := range lines {
+					if l == "" {
+						lines = lines[:minInt(len(lines), i+1+n)]
+						break
+					}
+				}
+			}
+			write("+OK")
+			for _, l := range lines {
+				if strings.HasPrefix(l, ".") {
+					l = "." + l
+				}
+				write("%s", l)
+			}
+			write(".")
+		case "DELE":
+			if n, _, ok := message(fields[1]); ok {
+				deleted[n] = true
+				write("+OK deleted")
+			}
+		case "RSET":
+			deleted = map[int]bool{}
+			write("+OK")
+		case "NOOP":
+			write("+OK")
+		case "QUIT":
+			s.mu.Lock()
+			s.deleted = deleted
+			s.mu.Unlock()
+			write("+OK bye")
+			return
+		default:
+			write("-ERR unknown command")
+		}
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func testTLSConfig(t *testing.T) (*tls.Config, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}, pool
+}
+
+func TestClient(t *testing.T) {
+	s := startStandIn(t, nil, false, welcomeEmail, resetEmail)
+
+	c, err := Dial(s.addr())
+	assert.NoError(t, err)
+	assert.False(t, c.TLS())
+
+	// Line breaks are refused before anything is sent
+	err = c.Auth("s1@mailosaur.net\r\nDELE 1", "secret")
+	assert.EqualError(t, err, "pop3: USER argument must not contain a line break")
+	err = c.Auth("s1@mailosaur.net", "secret\nDELE 2")
+	assert.EqualError(t, err, "pop3: PASS argument must not contain a line break")
+
+	err = c.Auth("s1@mailosaur.net", "wrong")
+	assert.EqualError(t, err, "pop3: PASS failed: invalid credentials")
+	assert.IsType(t, &Error{}, err)
+
+	assert.NoError(t, c.Auth("s1@mailosaur.net", "secret"))
+
+	count, size, err := c.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, len(welcomeEmail)+len(resetEmail), size)
+
+	list, err := c.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []MessageInfo{{1, len(welcomeEmail)}, {2, len(resetEmail)}}, list)
+
+	raw, err := c.Retr(1)
+	assert.NoError(t, err)
+	assert.Equal(t, welcomeEmail, string(raw))
+
+	top, err := c.Top(2, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "From: noreply@example.com\r\nSubject: Reset your password\r\n\r\n", string(top))
+
+	_, err = c.Retr(3)
+	assert.EqualError(t, err, "pop3: RETR failed: no such message")
+
+	assert.NoError(t, c.Noop())
+	assert.NoError(t, c.Dele(2))
+	count, _, _ = c.Stat()
+	assert.Equal(t, 1, count)
+
+	assert.NoError(t, c.Rset())
+	assert.NoError(t, c.Dele(1))
+	assert.NoError(t, c.Quit())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	assert.Equal(t, map[int]bool{1: true}, s.deleted)
+}
+
+func TestClientMessage(t *testing.T) {
+	s := startStandIn(t, nil, false, welcomeEmail)
+
+	c, err := Dial(s.addr())
+	assert.NoError(t, err)
+	defer c.Close()
+	assert.NoError(t, c.Auth("s1@mailosaur.net", "secret"))
+
+	message, err := c.Message(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Welcome", message.Subject)
+	assert.Equal(t, "noreply@example.com", message.From[0].Email)
+	assert.Equal(t, "https://example.com/verify", message.Text.Links[0].Href)
+	assert.Contains(t, message.Text.Body, "\n.. and this line starts with a dot")
+}
+
+func TestClientStartTLS(t *testing.T) {
+	config, pool := testTLSConfig(t)
+	s := startStandIn(t, config, false, resetEmail)
+
+	host, port, _ := net.SplitHostPort(s.addr())
+	portNumber, _ := strconv.Atoi(port)
+
+	c, err := Connect(&mailosaur.Endpoint{
+		Protocol: "pop3",
+		Host:     host,
+		Port:     portNumber,
+		Tls:      mailosaur.TlsStartTls,
+		Username: "s1@mailosaur.net",
+		Password: "secret",
+	}, &tls.Config{RootCAs: pool})
+	assert.NoError(t, err)
+	assert.True(t, c.TLS())
+
+	message, err := c.Message(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Reset your password", message.Subject)
+	assert.NoError(t, c.Quit())
+
+	// The certificate is verified
+	c, err = Dial(s.addr())
+	assert.NoError(t, err)
+	assert.Error(t, c.StartTLS(nil))
+}
+
+func TestClientImplicitTLS(t *testing.T) {
+	config, pool := testTLSConfig(t)
+	s := startStandIn(t, config, true, resetEmail)
+
+	c, err := DialTLS(s.addr(), &tls.Config{RootCAs: pool})
+	assert.NoError(t, err)
+	assert.True(t, c.TLS())
+
+	// STLS is refused once the connection is already encrypted
+	assert.Error(t, c.StartTLS(&tls.Config{RootCAs: pool}))
+
+	assert.NoError(t, c.Auth("s1@mailosaur.net", "secret"))
+	count, _, err := c.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.NoError(t, c.Quit())
+}
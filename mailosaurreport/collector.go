@@ -0,0 +1,163 @@
+package mailosaurreport
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mailosaur/mailosaur-go"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeError   = "error"
+)
+
+type Record struct {
+	Operation string    `json:"operation"`
+	Target    string    `json:"target,omitempty"`
+	Criteria  string    `json:"criteria,omitempty"`
+	Started   time.Time `json:"started"`
+	Duration  float64   `json:"durationSeconds"`
+	Polls     int       `json:"polls"`
+	Results   int       `json:"results"`
+	Outcome   string    `json:"outcome"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Collector records the operations made through a client, for export as
+// JUnit XML or JSON. It is safe for concurrent use.
+type Collector struct {
+	Name string
+
+	mu      sync.Mutex
+	records []*Record
+}
+
+// NewCollector creates a collector and registers it as the client's
+// observer.
+func NewCollector(name string, client *mailosaur.MailosaurClient) *Collector {
+	c := &Collector{Name: name}
+	if client != nil {
+		client.SetObserver(c)
+	}
+	return c
+}
+
+func (c *Collector) Observe(op *mailosaur.Operation) {
+	r := &Record{
+		Operation: op.Name,
+		Target:    op.Target,
+		Started:   op.Started,
+		Duration:  op.Duration.Seconds(),
+		Polls:     op.Polls,
+		Results:   op.Results,
+		Outcome:   OutcomeSuccess,
+	}
+
+	if op.Criteria != nil {
+		criteriaJson, _ := json.Marshal(op.Criteria)
+		r.Criteria = string(criteriaJson)
+	}
+
+	if op.Err != nil {
+		r.Error = op.Err.Error()
+		r.Outcome = OutcomeError
+		if op.TimedOut {
+			r.Outcome = OutcomeFailure
+		}
+	}
+
+	c.mu.Lock()
+	c.records = append(c.records, r)
+	c.mu.Unlock()
+}
+
+func (c *Collector) Records() []*Record {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	records := make([]*Record, len(c.records))
+	copy(records, c.records)
+	return records
+}
+
+func (c *Collector) Reset() {
+	c.mu.Lock()
+	c.records = nil
+	c.mu.Unlock()
+}
+
+func (c *Collector) name() string {
+	if len(c.Name) == 0 {
+		return "mailosaur"
+	}
+	return c.Name
+}
+
+// JUnit converts the records to a test suite, with timeouts reported as
+// failures and other errors as errors.
+func (c *Collector) JUnit() *JUnitTestSuite {
+	suite := &JUnitTestSuite{Name: c.name()}
+
+	records := c.Records()
+	if len(records) > 0 {
+		suite.Timestamp = records[0].Started.UTC().Format("2006-01-02T15:04:05")
+	}
+
+	for _, r := range records {
+		name := r.Operation
+		if len(r.Target) > 0 {
+			name += " " + r.Target
+		}
+
+		classname := c.name()
+		if idx := strings.Index(r.Operation, "."); idx > 0 {
+			classname += "." + r.Operation[:idx]
+		}
+
+		tc := &JUnitTestCase{
+			Name:      name,
+			Classname: classname,
+			Time:      r.Duration,
+			SystemOut: "polls: " + strconv.Itoa(r.Polls) + "\nresults: " + strconv.Itoa(r.Results),
+		}
+		if len(r.Criteria) > 0 {
+			tc.SystemOut += "\ncriteria: " + r.Criteria
+		}
+
+		switch r.Outcome {
+		case OutcomeFailure:
+			tc.Failure = &JUnitFailure{Message: r.Error, Type: "Timeout", Text: r.Error}
+		case OutcomeError:
+			tc.Error = &JUnitFailure{Message: r.Error, Type: "Error", Text: r.Error}
+		}
+
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Count()
+
+	return suite
+}
+
+func (c *Collector) WriteJUnit(w io.Writer) error {
+	return WriteJUnit(w, c.name(), c.JUnit())
+}
+
+func (c *Collector) WriteJSON(w io.Writer) error {
+	records := c.Records()
+	if records == nil {
+		records = []*Record{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Name    string    `json:"name"`
+		Records []*Record `json:"records"`
+	}{c.name(), records})
+}
@@ -0,0 +1,122 @@
+package mailosaurreport
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/mailosaur/mailosaur-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// rewriteTransport sends every request to the test server.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func testClient(t *testing.T, handler http.HandlerFunc) *mailosaur.MailosaurClient {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	target, _ := url.Parse(srv.URL)
+	return mailosaur.NewWithClient("key", &http.Client{Transport: &rewriteTransport{target: target}})
+}
+
+func TestCollector(t *testing.T) {
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/messages/search":
+			var criteria mailosaur.SearchCriteria
+			json.NewDecoder(r.Body).Decode(&criteria)
+			if criteria.SentTo == "found@abc.mailosaur.net" {
+				w.Write([]byte(`{"items":[{"id":"msg1"}]}`))
+			} else {
+				w.Write([]byte(`{"items":[]}`))
+			}
+		case r.URL.Path == "/api/messages/msg1":
+			w.Write([]byte(`{"id":"msg1","subject":"Hello"}`))
+		case r.URL.Path == "/api/analysis/spam/msg1":
+			w.Write([]byte(`{"score":1.5}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	collector := NewCollector("email tests", client)
+
+	_, err := client.Messages.Get(&mailosaur.MessageSearchParams{Server: "abc"}, &mailosaur.SearchCriteria{SentTo: "found@abc.mailosaur.net"})
+	assert.NoError(t, err)
+
+	_, err = client.Messages.Search(&mailosaur.MessageSearchParams{Server: "abc", Timeout: 1}, &mailosaur.SearchCriteria{SentTo: "missing@abc.mailosaur.net"})
+	assert.Error(t, err)
+
+	_, err = client.Analysis.Spam("msg1")
+	assert.NoError(t, err)
+
+	_, err = client.Analysis.Deliverability("msg1")
+	assert.Error(t, err)
+
+	records := collector.Records()
+	assert.Equal(t, 4, len(records))
+
+	assert.Equal(t, "messages.get", records[0].Operation)
+	assert.Equal(t, "abc", records[0].Target)
+	assert.Equal(t, OutcomeSuccess, records[0].Outcome)
+	assert.Equal(t, 1, records[0].Polls)
+	assert.Equal(t, 1, records[0].Results)
+	assert.Contains(t, records[0].Criteria, `"sentTo":"found@abc.mailosaur.net"`)
+
+	assert.Equal(t, "messages.search", records[1].Operation)
+	assert.Equal(t, OutcomeFailure, records[1].Outcome)
+	assert.Contains(t, records[1].Error, `The search criteria used for this query was [{"sentFrom":"","sentTo":"missing@abc.mailosaur.net"`)
+
+	assert.Equal(t, "analysis.spam", records[2].Operation)
+	assert.Equal(t, OutcomeSuccess, records[2].Outcome)
+
+	assert.Equal(t, "analysis.deliverability", records[3].Operation)
+	assert.Equal(t, OutcomeError, records[3].Outcome)
+	assert.Equal(t, "Not found, check input parameters.", records[3].Error)
+
+	var junit bytes.Buffer
+	assert.NoError(t, collector.WriteJUnit(&junit))
+
+	xml := junit.String()
+	assert.Contains(t, xml, `<testsuite name="email tests" tests="4" failures="1" errors="1" skipped="0"`)
+	assert.Contains(t, xml, `<testcase name="messages.search abc" classname="email tests.messages"`)
+	assert.Contains(t, xml, `<failure message="No matching messages found in time.`)
+	assert.Contains(t, xml, `<error message="Not found, check input parameters." type="Error">`)
+
+	var buf bytes.Buffer
+	assert.NoError(t, collector.WriteJSON(&buf))
+
+	var decoded struct {
+		Name    string    `json:"name"`
+		Records []*Record `json:"records"`
+	}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, "email tests", decoded.Name)
+	assert.Equal(t, 4, len(decoded.Records))
+
+	collector.Reset()
+	assert.Empty(t, collector.Records())
+}
+
+func TestCollectorEmpty(t *testing.T) {
+	collector := &Collector{}
+
+	var buf bytes.Buffer
+	assert.NoError(t, collector.WriteJSON(&buf))
+	assert.True(t, strings.Contains(buf.String(), `"records": []`))
+
+	assert.Equal(t, 0, collector.JUnit().Tests)
+}
@@ -0,0 +1,345 @@
+package mailosaurreport
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mailosaur/mailosaur-go"
+)
+
+type RuleChange struct {
+	Rule        string   `json:"rule"`
+	Description string   `json:"description,omitempty"`
+	Before      *float64 `json:"before,omitempty"`
+	After       *float64 `json:"after,omitempty"`
+}
+
+type ValueChange struct {
+	Name   string `json:"name"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+type Comparison struct {
+	ScoreBefore float64 `json:"scoreBefore"`
+	ScoreAfter  float64 `json:"scoreAfter"`
+
+	AddedRules    []*RuleChange `json:"addedRules,omitempty"`
+	RemovedRules  []*RuleChange `json:"removedRules,omitempty"`
+	RescoredRules []*RuleChange `json:"rescoredRules,omitempty"`
+
+	Authentication []*ValueChange `json:"authentication,omitempty"`
+
+	NewBlockListHits     []string `json:"newBlockListHits,omitempty"`
+	ClearedBlockListHits []string `json:"clearedBlockListHits,omitempty"`
+
+	Content []*ValueChange `json:"content,omitempty"`
+}
+
+func (c *Comparison) ScoreDelta() float64 {
+	return c.ScoreAfter - c.ScoreBefore
+}
+
+func (c *Comparison) HasChanges() bool {
+	return c.ScoreDelta() != 0 ||
+		len(c.AddedRules) > 0 ||
+		len(c.RemovedRules) > 0 ||
+		len(c.RescoredRules) > 0 ||
+		len(c.Authentication) > 0 ||
+		len(c.NewBlockListHits) > 0 ||
+		len(c.ClearedBlockListHits) > 0 ||
+		len(c.Content) > 0
+}
+
+// Regressed reports whether the later report is worse: a higher spam score,
+// a new block list hit, an authentication check that stopped passing or a
+// content flag that became set.
+func (c *Comparison) Regressed() bool {
+	if c.ScoreDelta() > 0 || len(c.NewBlockListHits) > 0 {
+		return true
+	}
+	for _, a := range c.Authentication {
+		if strings.EqualFold(a.Before, "pass") {
+			return true
+		}
+	}
+	for _, f := range c.Content {
+		if f.After == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+// CompareSpam lists the SpamAssassin rules that changed between two spam
+// analysis results.
+func CompareSpam(before *mailosaur.SpamAnalysisResult, after *mailosaur.SpamAnalysisResult) *Comparison {
+	c := &Comparison{}
+	var beforeRules, afterRules []*mailosaur.SpamAssassinRule
+
+	if before != nil {
+		c.ScoreBefore = before.Score
+		if before.SpamFilterResults != nil {
+			beforeRules = before.SpamFilterResults.SpamAssassin
+		}
+	}
+	if after != nil {
+		c.ScoreAfter = after.Score
+		if after.SpamFilterResults != nil {
+			afterRules = after.SpamFilterResults.SpamAssassin
+		}
+	}
+
+	c.compareRules(beforeRules, afterRules)
+	return c
+}
+
+// CompareDeliverability lists the changes between two deliverability
+// reports, including their SpamAssassin rules.
+func CompareDeliverability(before *mailosaur.DeliverabilityReport, after *mailosaur.DeliverabilityReport) *Comparison {
+	if before == nil {
+		before = &mailosaur.DeliverabilityReport{}
+	}
+	if after == nil {
+		after = &mailosaur.DeliverabilityReport{}
+	}
+
+	c := &Comparison{}
+
+	var beforeRules, afterRules []*mailosaur.SpamAssassinRule
+	if before.SpamAssassin != nil {
+		c.ScoreBefore = float64(before.SpamAssassin.Score)
+		beforeRules = before.SpamAssassin.Rules
+	}
+	if after.SpamAssassin != nil {
+		c.ScoreAfter = float64(after.SpamAssassin.Score)
+		afterRules = after.SpamAssassin.Rules
+	}
+	c.compareRules(beforeRules, afterRules)
+
+	c.compareAuth("SPF", before.Spf, after.Spf)
+	for i := 0; i < len(before.Dkim) || i < len(after.Dkim); i++ {
+		var b, a *mailosaur.EmailAuthenticationResult
+		if i < len(before.Dkim) {
+			b = before.Dkim[i]
+		}
+		if i < len(after.Dkim) {
+			a = after.Dkim[i]
+		}
+		c.compareAuth("DKIM #"+strconv.Itoa(i+1), b, a)
+	}
+	c.compareAuth("DMARC", before.Dmarc, after.Dmarc)
+
+	beforeHits := blockListHits(before.BlockLists)
+	afterHits := blockListHits(after.BlockLists)
+	for name := range afterHits {
+		if !beforeHits[name] {
+			c.NewBlockListHits = append(c.NewBlockListHits, name)
+		}
+	}
+	for name := range beforeHits {
+		if !afterHits[name] {
+			c.ClearedBlockListHits = append(c.ClearedBlockListHits, name)
+		}
+	}
+	sort.Strings(c.NewBlockListHits)
+	sort.Strings(c.ClearedBlockListHits)
+
+	beforeContent := contentValues(before.Content)
+	afterContent := contentValues(after.Content)
+	for _, name := range contentNames {
+		if beforeContent[name] != afterContent[name] {
+			c.Content = append(c.Content, &ValueChange{Name: name, Before: beforeContent[name], After: afterContent[name]})
+		}
+	}
+
+	return c
+}
+
+func (c *Comparison) compareRules(before []*mailosaur.SpamAssassinRule, after []*mailosaur.SpamAssassinRule) {
+	beforeByName := map[string]*mailosaur.SpamAssassinRule{}
+	for _, r := range before {
+		if r != nil {
+			beforeByName[r.Rule] = r
+		}
+	}
+	afterByName := map[string]*mailosaur.SpamAssassinRule{}
+	for _, r := range after {
+		if r != nil {
+			afterByName[r.Rule] = r
+		}
+	}
+
+	for _, r := range SortedRules(after) {
+		b, ok := beforeByName[r.Rule]
+		switch {
+		case !ok:
+			c.AddedRules = append(c.AddedRules, &RuleChange{Rule: r.Rule, Description: r.Description, After: score(r.Score)})
+		case b.Score != r.Score:
+			c.RescoredRules = append(c.RescoredRules, &RuleChange{Rule: r.Rule, Description: r.Description, Before: score(b.Score), After: score(r.Score)})
+		}
+	}
+
+	for _, r := range SortedRules(before) {
+		if _, ok := afterByName[r.Rule]; !ok {
+			c.RemovedRules = append(c.RemovedRules, &RuleChange{Rule: r.Rule, Description: r.Description, Before: score(r.Score)})
+		}
+	}
+}
+
+func (c *Comparison) compareAuth(name string, before *mailosaur.EmailAuthenticationResult, after *mailosaur.EmailAuthenticationResult) {
+	b := authResult(before)
+	a := authResult(after)
+	if !strings.EqualFold(a, b) {
+		c.Authentication = append(c.Authentication, &ValueChange{Name: name, Before: b, After: a})
+	}
+}
+
+func authResult(r *mailosaur.EmailAuthenticationResult) string {
+	if r == nil {
+		return "Missing"
+	}
+	return r.Result
+}
+
+func blockListHits(lists []*mailosaur.BlockListResult) map[string]bool {
+	hits := map[string]bool{}
+	for _, b := range lists {
+		if BlockListed(b) {
+			name := b.Name
+			if len(name) == 0 {
+				name = b.Id
+			}
+			hits[name] = true
+		}
+	}
+	return hits
+}
+
+var contentNames = []string{
+	"embed",
+	"iframe",
+	"object",
+	"script",
+	"shortUrls",
+	"missingAlt",
+	"missingListUnsubscribe",
+	"textSize",
+	"totalSize",
+}
+
+func contentValues(c *mailosaur.Content) map[string]string {
+	if c == nil {
+		return map[string]string{}
+	}
+	return map[string]string{
+		"embed":                  strconv.FormatBool(c.Embed),
+		"iframe":                 strconv.FormatBool(c.Iframe),
+		"object":                 strconv.FormatBool(c.Object),
+		"script":                 strconv.FormatBool(c.Script),
+		"shortUrls":              strconv.FormatBool(c.ShortUrls),
+		"missingAlt":             strconv.FormatBool(c.MissingAlt),
+		"missingListUnsubscribe": strconv.FormatBool(c.MissingListUnsubscribe),
+		"textSize":               strconv.Itoa(c.TextSize),
+		"totalSize":              strconv.Itoa(c.TotalSize),
+	}
+}
+
+func score(f float64) *float64 {
+	return &f
+}
+
+// FormatScore formats a spam score with as few digits as needed.
+func FormatScore(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func (c *Comparison) String() string {
+	var sb strings.Builder
+
+	delta := c.ScoreDelta()
+	fmt.Fprintf(&sb, "Spam score: %s -> %s", FormatScore(c.ScoreBefore), FormatScore(c.ScoreAfter))
+	if delta != 0 {
+		sign := ""
+		if delta > 0 {
+			sign = "+"
+		}
+		fmt.Fprintf(&sb, " (%s%s)", sign, FormatScore(delta))
+	}
+	sb.WriteString("\n")
+
+	if !c.HasChanges() {
+		sb.WriteString("No changes\n")
+		return sb.String()
+	}
+
+	for _, r := range c.AddedRules {
+		fmt.Fprintf(&sb, "+ %s (%s) %s\n", r.Rule, FormatScore(*r.After), r.Description)
+	}
+	for _, r := range c.RemovedRules {
+		fmt.Fprintf(&sb, "- %s (%s) %s\n", r.Rule, FormatScore(*r.Before), r.Description)
+	}
+	for _, r := range c.RescoredRules {
+		fmt.Fprintf(&sb, "~ %s (%s -> %s) %s\n", r.Rule, FormatScore(*r.Before), FormatScore(*r.After), r.Description)
+	}
+	for _, a := range c.Authentication {
+		fmt.Fprintf(&sb, "~ %s: %s -> %s\n", a.Name, a.Before, a.After)
+	}
+	for _, b := range c.NewBlockListHits {
+		fmt.Fprintf(&sb, "+ Listed on %s\n", b)
+	}
+	for _, b := range c.ClearedBlockListHits {
+		fmt.Fprintf(&sb, "- No longer listed on %s\n", b)
+	}
+	for _, f := range c.Content {
+		fmt.Fprintf(&sb, "~ content.%s: %s -> %s\n", f.Name, orMissing(f.Before), orMissing(f.After))
+	}
+
+	return sb.String()
+}
+
+func orMissing(s string) string {
+	if len(s) == 0 {
+		return "missing"
+	}
+	return s
+}
+
+func (c *Comparison) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c)
+}
+
+// SaveBaseline writes a spam analysis result or deliverability report to a
+// JSON file, to be compared against by a later run.
+func SaveBaseline(path string, report interface{}) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func LoadSpamBaseline(path string) (*mailosaur.SpamAnalysisResult, error) {
+	result := &mailosaur.SpamAnalysisResult{}
+	return result, loadJSON(path, result)
+}
+
+func LoadDeliverabilityBaseline(path string) (*mailosaur.DeliverabilityReport, error) {
+	result := &mailosaur.DeliverabilityReport{}
+	return result, loadJSON(path, result)
+}
+
+func loadJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
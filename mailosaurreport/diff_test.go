@@ -0,0 +1,117 @@
+package mailosaurreport
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/mailosaur/mailosaur-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareSpam(t *testing.T) {
+	before := testSpam()
+	after := &mailosaur.SpamAnalysisResult{
+		Score: 5.5,
+		SpamFilterResults: &mailosaur.SpamFilterResults{
+			SpamAssassin: []*mailosaur.SpamAssassinRule{
+				{Rule: "HTML_MESSAGE", Score: 0.001, Description: "HTML included in message"},
+				{Rule: "MIME_HTML_ONLY", Score: 2.5, Description: "Message only has text/html MIME parts"},
+				{Rule: "HTML_IMAGE_ONLY_16", Score: 1.6, Description: "HTML: images with 1200-1600 bytes of words"},
+				{Rule: "MISSING_DATE", Score: 1.36, Description: "Missing Date: header"},
+			},
+		},
+	}
+
+	c := CompareSpam(before, after)
+
+	assert.InDelta(t, 2.1, c.ScoreDelta(), 0.0001)
+	assert.True(t, c.HasChanges())
+	assert.True(t, c.Regressed())
+
+	assert.Equal(t, 1, len(c.AddedRules))
+	assert.Equal(t, "HTML_IMAGE_ONLY_16", c.AddedRules[0].Rule)
+	assert.Nil(t, c.AddedRules[0].Before)
+
+	assert.Equal(t, 1, len(c.RemovedRules))
+	assert.Equal(t, "URIBL_BLOCKED", c.RemovedRules[0].Rule)
+
+	assert.Equal(t, 1, len(c.RescoredRules))
+	assert.Equal(t, 2.1, *c.RescoredRules[0].Before)
+	assert.Equal(t, 2.5, *c.RescoredRules[0].After)
+
+	text := c.String()
+	assert.Contains(t, text, "Spam score: 3.4 -> 5.5 (+2.1)\n")
+	assert.Contains(t, text, "+ HTML_IMAGE_ONLY_16 (1.6) HTML: images with 1200-1600 bytes of words\n")
+	assert.Contains(t, text, "- URIBL_BLOCKED (0) ADMINISTRATOR NOTICE\n")
+	assert.Contains(t, text, "~ MIME_HTML_ONLY (2.1 -> 2.5)")
+}
+
+func TestCompareSpamUnchanged(t *testing.T) {
+	c := CompareSpam(testSpam(), testSpam())
+
+	assert.False(t, c.HasChanges())
+	assert.False(t, c.Regressed())
+	assert.Equal(t, "Spam score: 3.4 -> 3.4\nNo changes\n", c.String())
+}
+
+func TestCompareDeliverability(t *testing.T) {
+	before := testDeliverability()
+	after := testDeliverability()
+	after.Spf = &mailosaur.EmailAuthenticationResult{Result: "SoftFail"}
+	after.Dkim = after.Dkim[:1]
+	after.BlockLists = []*mailosaur.BlockListResult{
+		{Id: "spamhaus", Name: "Spamhaus", Result: "Listed"},
+		{Id: "barracuda", Name: "Barracuda", Result: "NotListed"},
+	}
+	after.Content.Script = false
+	after.Content.Iframe = true
+	after.Content.TotalSize = 50000
+
+	c := CompareDeliverability(before, after)
+
+	assert.Equal(t, []*ValueChange{
+		{Name: "SPF", Before: "Pass", After: "SoftFail"},
+		{Name: "DKIM #2", Before: "Fail", After: "Missing"},
+	}, c.Authentication)
+	assert.Equal(t, []string{"Spamhaus"}, c.NewBlockListHits)
+	assert.Equal(t, []string{"Barracuda"}, c.ClearedBlockListHits)
+	assert.Equal(t, []*ValueChange{
+		{Name: "iframe", Before: "false", After: "true"},
+		{Name: "script", Before: "true", After: "false"},
+		{Name: "totalSize", Before: "45000", After: "50000"},
+	}, c.Content)
+	assert.True(t, c.Regressed())
+
+	text := c.String()
+	assert.Contains(t, text, "~ SPF: Pass -> SoftFail\n")
+	assert.Contains(t, text, "+ Listed on Spamhaus\n")
+	assert.Contains(t, text, "- No longer listed on Barracuda\n")
+	assert.Contains(t, text, "~ content.iframe: false -> true\n")
+
+	var buf bytes.Buffer
+	assert.NoError(t, c.WriteJSON(&buf))
+
+	var decoded map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, []interface{}{"Spamhaus"}, decoded["newBlockListHits"])
+}
+
+func TestBaseline(t *testing.T) {
+	dir := t.TempDir()
+
+	assert.NoError(t, SaveBaseline(filepath.Join(dir, "spam.json"), testSpam()))
+	assert.NoError(t, SaveBaseline(filepath.Join(dir, "deliverability.json"), testDeliverability()))
+
+	spam, err := LoadSpamBaseline(filepath.Join(dir, "spam.json"))
+	assert.NoError(t, err)
+	assert.False(t, CompareSpam(spam, testSpam()).HasChanges())
+
+	report, err := LoadDeliverabilityBaseline(filepath.Join(dir, "deliverability.json"))
+	assert.NoError(t, err)
+	assert.False(t, CompareDeliverability(report, testDeliverability()).HasChanges())
+
+	_, err = LoadSpamBaseline(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,164 @@
+package mailosaurreport
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	"text/template"
+)
+
+var funcs = map[string]interface{}{
+	"badge": func(s Status) string {
+		switch s {
+		case StatusPass:
+			return "✅"
+		case StatusWarning:
+			return "⚠️"
+		case StatusFail:
+			return "❌"
+		default:
+			return "❔"
+		}
+	},
+	"score": FormatScore,
+	"join":  strings.Join,
+	"cell": func(s string) string {
+		return strings.NewReplacer("|", "\\|", "\r", " ", "\n", " ").Replace(s)
+	},
+}
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #1f2328; }
+table { border-collapse: collapse; margin-bottom: 2em; min-width: 40em; }
+th, td { border: 1px solid #d0d7de; padding: 0.4em 0.8em; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; }
+.badge { display: inline-block; border-radius: 1em; padding: 0.1em 0.7em; font-size: 0.85em; font-weight: 600; color: #fff; }
+.pass { background: #1a7f37; }
+.warning { background: #9a6700; }
+.fail { background: #cf222e; }
+.unknown { background: #6e7781; }
+.listed td { background: #ffebe9; }
+.num { text-align: right; font-variant-numeric: tabular-nums; }
+</style>
+</head>
+<body>
+<h1>{{.Title}} <span class="badge {{.OverallStatus}}">{{.OverallStatus}}</span></h1>
+{{- if .HasAuth}}
+<h2>Authentication</h2>
+<table>
+<tr><th>Check</th><th>Result</th><th>Description</th></tr>
+{{- range .Auth}}
+<tr><td>{{.Name}}</td><td><span class="badge {{.Status}}">{{.Result}}</span></td><td>{{.Description}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .HasBlockLists}}
+<h2>Block lists ({{.BlockListHits}} listed)</h2>
+<table>
+<tr><th>Block list</th><th>Id</th><th>Result</th></tr>
+{{- range .BlockLists}}
+<tr{{if .Listed}} class="listed"{{end}}><td>{{.Name}}</td><td>{{.Id}}</td><td><span class="badge {{if .Listed}}fail{{else}}pass{{end}}">{{.Result}}</span></td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .HasContent}}
+<h2>Content</h2>
+<table>
+<tr><th>Check</th><th>Value</th></tr>
+{{- range .Content}}
+<tr><td>{{.Name}}</td><td><span class="badge {{.Status}}">{{.Value}}</span></td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Dns}}
+<h2>DNS records</h2>
+<table>
+<tr><th>Type</th><th>Values</th></tr>
+{{- range .Dns}}
+<tr><td>{{.Type}}</td><td>{{range $i, $v := .Values}}{{if $i}}<br>{{end}}{{$v}}{{else}}<em>none</em>{{end}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .HasSpamScore}}
+<h2>SpamAssassin (score {{score .SpamScore}}{{if .SpamResult}}, {{.SpamResult}}{{end}})</h2>
+{{- if .HasSpamAssassin}}
+<table>
+<tr><th>Rule</th><th class="num">Score</th><th>Description</th></tr>
+{{- range .SpamAssassin}}
+<tr><td><code>{{.Rule}}</code></td><td class="num">{{score .Score}}</td><td>{{.Description}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+var markdownTemplate = template.Must(template.New("markdown").Funcs(funcs).Parse(`## {{badge .OverallStatus}} {{.Title}}
+{{if .HasAuth}}
+### Authentication
+
+| Check | Result | Description |
+| --- | --- | --- |
+{{- range .Auth}}
+| {{.Name}} | {{badge .Status}} {{cell .Result}} | {{cell .Description}} |
+{{- end}}
+{{end}}
+{{- if .HasBlockLists}}
+### Block lists
+
+{{if .BlockListHits}}❌ Listed on {{.BlockListHits}} of {{len .BlockLists}} block lists.
+
+| Block list | Result |
+| --- | --- |
+{{- range .BlockLists}}{{if .Listed}}
+| {{cell .Name}} | {{cell .Result}} |
+{{- end}}{{end}}
+{{else}}✅ Not listed on any of {{len .BlockLists}} block lists.
+{{end}}{{end}}
+{{- if .HasContent}}
+### Content
+
+| Check | Value |
+| --- | --- |
+{{- range .Content}}
+| {{.Name}} | {{badge .Status}} {{.Value}} |
+{{- end}}
+{{end}}
+{{- if .Dns}}
+### DNS records
+
+| Type | Values |
+| --- | --- |
+{{- range .Dns}}
+| {{.Type}} | {{if .Values}}{{cell (join .Values ", ")}}{{else}}_none_{{end}} |
+{{- end}}
+{{end}}
+{{- if .HasSpamScore}}
+### SpamAssassin
+
+Score: **{{score .SpamScore}}**{{if .SpamResult}} ({{.SpamResult}}){{end}}
+{{if .HasSpamAssassin}}
+| Rule | Score | Description |
+| --- | ---: | --- |
+{{- range .SpamAssassin}}
+| ` + "`{{.Rule}}`" + ` | {{score .Score}} | {{cell .Description}} |
+{{- end}}
+{{end}}
+{{- end}}`))
+
+// RenderHTML writes the report as a self-contained HTML page.
+func RenderHTML(w io.Writer, report *Report) error {
+	return htmlTemplate.Execute(w, newView(report))
+}
+
+// RenderMarkdown writes a summary of the report suitable for a pull request
+// comment.
+func RenderMarkdown(w io.Writer, report *Report) error {
+	return markdownTemplate.Execute(w, newView(report))
+}
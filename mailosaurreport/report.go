@@ -0,0 +1,234 @@
+// Package mailosaurreport renders and compares the results of Mailosaur
+// deliverability and spam analysis, for use in CI logs and artifacts.
+package mailosaurreport
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mailosaur/mailosaur-go"
+)
+
+type Status string
+
+const (
+	StatusPass    Status = "pass"
+	StatusWarning Status = "warning"
+	StatusFail    Status = "fail"
+	StatusUnknown Status = "unknown"
+)
+
+// Report is the input to the renderers. Either analysis may be nil.
+type Report struct {
+	Title          string
+	Deliverability *mailosaur.DeliverabilityReport
+	Spam           *mailosaur.SpamAnalysisResult
+}
+
+type authCheck struct {
+	Name        string
+	Result      string
+	Description string
+	Status      Status
+}
+
+type blockList struct {
+	Name   string
+	Id     string
+	Result string
+	Listed bool
+}
+
+type contentFlag struct {
+	Name   string
+	Value  string
+	Status Status
+}
+
+type dnsRecord struct {
+	Type   string
+	Values []string
+}
+
+type view struct {
+	Title           string
+	HasAuth         bool
+	Auth            []authCheck
+	BlockLists      []blockList
+	BlockListHits   int
+	HasContent      bool
+	Content         []contentFlag
+	Dns             []dnsRecord
+	HasSpamScore    bool
+	SpamScore       float64
+	SpamResult      string
+	SpamAssassin    []*mailosaur.SpamAssassinRule
+	OverallStatus   Status
+	HasBlockLists   bool
+	HasSpamAssassin bool
+}
+
+// AuthStatus maps an SPF, DKIM or DMARC result value onto a badge status.
+func AuthStatus(result *mailosaur.EmailAuthenticationResult) Status {
+	if result == nil {
+		return StatusUnknown
+	}
+
+	switch strings.ToLower(result.Result) {
+	case "pass":
+		return StatusPass
+	case "fail", "permerror", "temperror":
+		return StatusFail
+	case "":
+		return StatusUnknown
+	default:
+		return StatusWarning
+	}
+}
+
+// BlockListed reports whether a block list result is a hit.
+func BlockListed(result *mailosaur.BlockListResult) bool {
+	if result == nil {
+		return false
+	}
+	r := strings.ToLower(result.Result)
+	return r == "listed" || r == "fail" || r == "failed"
+}
+
+// SortedRules returns a copy of the rules ordered by descending score.
+func SortedRules(rules []*mailosaur.SpamAssassinRule) []*mailosaur.SpamAssassinRule {
+	sorted := make([]*mailosaur.SpamAssassinRule, 0, len(rules))
+	for _, r := range rules {
+		if r != nil {
+			sorted = append(sorted, r)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Score != sorted[j].Score {
+			return sorted[i].Score > sorted[j].Score
+		}
+		return sorted[i].Rule < sorted[j].Rule
+	})
+	return sorted
+}
+
+func newView(r *Report) *view {
+	v := &view{
+		Title:         r.Title,
+		OverallStatus: StatusPass,
+	}
+	if len(v.Title) == 0 {
+		v.Title = "Email analysis report"
+	}
+
+	if d := r.Deliverability; d != nil {
+		v.HasAuth = true
+		v.Auth = append(v.Auth, newAuthCheck("SPF", d.Spf))
+		for i, dkim := range d.Dkim {
+			name := "DKIM"
+			if len(d.Dkim) > 1 {
+				name += " #" + strconv.Itoa(i+1)
+			}
+			v.Auth = append(v.Auth, newAuthCheck(name, dkim))
+		}
+		if len(d.Dkim) == 0 {
+			v.Auth = append(v.Auth, newAuthCheck("DKIM", nil))
+		}
+		v.Auth = append(v.Auth, newAuthCheck("DMARC", d.Dmarc))
+
+		v.HasBlockLists = len(d.BlockLists) > 0
+		for _, b := range d.BlockLists {
+			if b == nil {
+				continue
+			}
+			listed := BlockListed(b)
+			if listed {
+				v.BlockListHits++
+			}
+			v.BlockLists = append(v.BlockLists, blockList{Name: b.Name, Id: b.Id, Result: b.Result, Listed: listed})
+		}
+
+		if c := d.Content; c != nil {
+			v.HasContent = true
+			v.Content = contentFlags(c)
+		}
+
+		if dns := d.DnsRecords; dns != nil {
+			v.Dns = []dnsRecord{
+				{Type: "A", Values: dns.A},
+				{Type: "MX", Values: dns.Mx},
+				{Type: "PTR", Values: dns.Ptr},
+			}
+		}
+
+		if sa := d.SpamAssassin; sa != nil {
+			v.HasSpamScore = true
+			v.SpamScore = float64(sa.Score)
+			v.SpamResult = sa.Result
+			v.SpamAssassin = SortedRules(sa.Rules)
+		}
+	}
+
+	// The spam analysis result carries a more precise score, so it takes
+	// precedence over the deliverability report's SpamAssassin section.
+	if s := r.Spam; s != nil {
+		v.HasSpamScore = true
+		v.SpamScore = s.Score
+		v.SpamResult = ""
+		if s.SpamFilterResults != nil {
+			v.SpamAssassin = SortedRules(s.SpamFilterResults.SpamAssassin)
+		}
+	}
+	v.HasSpamAssassin = len(v.SpamAssassin) > 0
+
+	for _, a := range v.Auth {
+		v.OverallStatus = worst(v.OverallStatus, a.Status)
+	}
+	if v.BlockListHits > 0 {
+		v.OverallStatus = StatusFail
+	}
+	for _, c := range v.Content {
+		v.OverallStatus = worst(v.OverallStatus, c.Status)
+	}
+
+	return v
+}
+
+func newAuthCheck(name string, result *mailosaur.EmailAuthenticationResult) authCheck {
+	check := authCheck{Name: name, Status: AuthStatus(result), Result: "Missing"}
+	if result != nil {
+		check.Result = result.Result
+		check.Description = result.Description
+	}
+	return check
+}
+
+func contentFlags(c *mailosaur.Content) []contentFlag {
+	flag := func(name string, set bool) contentFlag {
+		if set {
+			return contentFlag{Name: name, Value: "Yes", Status: StatusWarning}
+		}
+		return contentFlag{Name: name, Value: "No", Status: StatusPass}
+	}
+
+	return []contentFlag{
+		flag("Embed", c.Embed),
+		flag("Iframe", c.Iframe),
+		flag("Object", c.Object),
+		flag("Script", c.Script),
+		flag("Short URLs", c.ShortUrls),
+		flag("Missing alt text", c.MissingAlt),
+		flag("Missing List-Unsubscribe", c.MissingListUnsubscribe),
+		{Name: "Text size", Value: strconv.Itoa(c.TextSize) + " bytes", Status: StatusPass},
+		{Name: "Total size", Value: strconv.Itoa(c.TotalSize) + " bytes", Status: StatusPass},
+	}
+}
+
+func worst(a Status, b Status) Status {
+	rank := map[Status]int{StatusPass: 0, StatusUnknown: 1, StatusWarning: 2, StatusFail: 3}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
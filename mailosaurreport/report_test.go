@@ -0,0 +1,130 @@
+package mailosaurreport
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mailosaur/mailosaur-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func testDeliverability() *mailosaur.DeliverabilityReport {
+	return &mailosaur.DeliverabilityReport{
+		Spf: &mailosaur.EmailAuthenticationResult{Result: "Pass", Description: "Sender is authorized"},
+		Dkim: []*mailosaur.EmailAuthenticationResult{
+			{Result: "Pass", Description: "Signature valid"},
+			{Result: "Fail", Description: "Body hash did not verify"},
+		},
+		Dmarc: &mailosaur.EmailAuthenticationResult{Result: "None", Description: "No DMARC record"},
+		BlockLists: []*mailosaur.BlockListResult{
+			{Id: "spamhaus", Name: "Spamhaus", Result: "NotListed"},
+			{Id: "barracuda", Name: "Barracuda", Result: "Listed"},
+		},
+		Content: &mailosaur.Content{
+			Script:                 true,
+			TextSize:               1200,
+			TotalSize:              45000,
+			MissingListUnsubscribe: true,
+		},
+		DnsRecords: &mailosaur.DnsRecords{
+			A:  []string{"203.0.113.5"},
+			Mx: []string{"10 mx1.acme.example", "20 mx2.acme.example"},
+		},
+		SpamAssassin: &mailosaur.SpamAssassinResult{
+			Score:  2,
+			Result: "Pass",
+			Rules: []*mailosaur.SpamAssassinRule{
+				{Rule: "HTML_MESSAGE", Score: 0.001, Description: "HTML included in message"},
+				{Rule: "MISSING_DATE", Score: 1.36, Description: "Missing Date: header"},
+			},
+		},
+	}
+}
+
+func testSpam() *mailosaur.SpamAnalysisResult {
+	return &mailosaur.SpamAnalysisResult{
+		Score: 3.4,
+		SpamFilterResults: &mailosaur.SpamFilterResults{
+			SpamAssassin: []*mailosaur.SpamAssassinRule{
+				{Rule: "HTML_MESSAGE", Score: 0.001, Description: "HTML included in message"},
+				{Rule: "URIBL_BLOCKED", Score: 0, Description: "ADMINISTRATOR NOTICE"},
+				{Rule: "MIME_HTML_ONLY", Score: 2.1, Description: "Message only has text/html MIME parts"},
+				{Rule: "MISSING_DATE", Score: 1.36, Description: "Missing Date: header"},
+			},
+		},
+	}
+}
+
+func TestSortedRules(t *testing.T) {
+	rules := SortedRules(testSpam().SpamFilterResults.SpamAssassin)
+
+	assert.Equal(t, "MIME_HTML_ONLY", rules[0].Rule)
+	assert.Equal(t, "MISSING_DATE", rules[1].Rule)
+	assert.Equal(t, "HTML_MESSAGE", rules[2].Rule)
+	assert.Equal(t, "URIBL_BLOCKED", rules[3].Rule)
+}
+
+func TestAuthStatus(t *testing.T) {
+	assert.Equal(t, StatusPass, AuthStatus(&mailosaur.EmailAuthenticationResult{Result: "Pass"}))
+	assert.Equal(t, StatusFail, AuthStatus(&mailosaur.EmailAuthenticationResult{Result: "Fail"}))
+	assert.Equal(t, StatusWarning, AuthStatus(&mailosaur.EmailAuthenticationResult{Result: "SoftFail"}))
+	assert.Equal(t, StatusUnknown, AuthStatus(nil))
+}
+
+func TestRenderHTML(t *testing.T) {
+	var buf bytes.Buffer
+	err := RenderHTML(&buf, &Report{
+		Title:          "Welcome <email>",
+		Deliverability: testDeliverability(),
+		Spam:           testSpam(),
+	})
+	assert.NoError(t, err)
+
+	html := buf.String()
+	assert.True(t, strings.HasPrefix(html, "<!DOCTYPE html>"))
+	assert.Contains(t, html, "<title>Welcome &lt;email&gt;</title>")
+	assert.Contains(t, html, `<td>SPF</td><td><span class="badge pass">Pass</span>`)
+	assert.Contains(t, html, `<td>DKIM #2</td><td><span class="badge fail">Fail</span>`)
+	assert.Contains(t, html, `<td>DMARC</td><td><span class="badge warning">None</span>`)
+	assert.Contains(t, html, "Block lists (1 listed)")
+	assert.Contains(t, html, `<tr class="listed"><td>Barracuda</td>`)
+	assert.Contains(t, html, "10 mx1.acme.example<br>20 mx2.acme.example")
+	assert.Contains(t, html, "SpamAssassin (score 3.4)")
+	assert.True(t, strings.Index(html, "MIME_HTML_ONLY") < strings.Index(html, "MISSING_DATE"))
+	assert.NotContains(t, html, "<link")
+	assert.NotContains(t, html, "<script")
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	err := RenderMarkdown(&buf, &Report{
+		Title:          "Welcome email",
+		Deliverability: testDeliverability(),
+		Spam:           testSpam(),
+	})
+	assert.NoError(t, err)
+
+	md := buf.String()
+	assert.True(t, strings.HasPrefix(md, "## ❌ Welcome email\n"))
+	assert.Contains(t, md, "| SPF | ✅ Pass | Sender is authorized |")
+	assert.Contains(t, md, "| DKIM #2 | ❌ Fail | Body hash did not verify |")
+	assert.Contains(t, md, "❌ Listed on 1 of 2 block lists.")
+	assert.Contains(t, md, "| Barracuda | Listed |")
+	assert.NotContains(t, md, "| Spamhaus |")
+	assert.Contains(t, md, "| Missing List-Unsubscribe | ⚠️ Yes |")
+	assert.Contains(t, md, "| PTR | _none_ |")
+	assert.Contains(t, md, "Score: **3.4**")
+	assert.Contains(t, md, "| `MIME_HTML_ONLY` | 2.1 | Message only has text/html MIME parts |")
+}
+
+func TestRenderSpamOnly(t *testing.T) {
+	var buf bytes.Buffer
+	err := RenderMarkdown(&buf, &Report{Spam: testSpam()})
+	assert.NoError(t, err)
+
+	md := buf.String()
+	assert.True(t, strings.HasPrefix(md, "## ✅ Email analysis report\n"))
+	assert.NotContains(t, md, "### Authentication")
+	assert.Contains(t, md, "### SpamAssassin")
+}
@@ -0,0 +1,280 @@
+package mailosaursnapshot
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mailosaur/mailosaur-go"
+)
+
+const (
+	maskId       = "<id>"
+	maskServer   = "<server>"
+	maskReceived = "<received>"
+	maskCode     = "<code>"
+	maskHeader   = "<masked>"
+	maskToken    = "<token>"
+)
+
+// Rule replaces every match of Pattern with Replacement. When Headers is set
+// the rule only applies to the values of those header fields, otherwise it
+// applies to the subject, bodies, header values and link URLs.
+type Rule struct {
+	Name        string
+	Pattern     *regexp.Regexp
+	Replacement string
+	Headers     []string
+}
+
+type Normaliser struct {
+	// Rules are applied in order, after the message's own Id, Server and
+	// extracted codes have been masked.
+	Rules []Rule
+
+	// MaskHeaders lists header fields whose values are replaced entirely.
+	MaskHeaders []string
+
+	// IgnoreHeaders lists header fields left out of the snapshot.
+	IgnoreHeaders []string
+
+	// TrackingParams lists query string parameters whose values are masked
+	// in links.
+	TrackingParams []string
+
+	// MaskCodes masks the codes Mailosaur extracted from the message.
+	MaskCodes bool
+}
+
+func DefaultRules() []Rule {
+	return []Rule{
+		{
+			Name:        "uuid",
+			Pattern:     regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`),
+			Replacement: "<uuid>",
+		},
+		{
+			Name:        "rfc1123-date",
+			Pattern:     regexp.MustCompile(`\b(?:(?:Mon|Tue|Wed|Thu|Fri|Sat|Sun), )?\d{1,2} (?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec) \d{4} \d{2}:\d{2}(?::\d{2})?(?: [+-]\d{4}| [A-Z]{2,5})?(?: \([A-Z]{2,5}\))?`),
+			Replacement: "<date>",
+		},
+		{
+			Name:        "iso-date",
+			Pattern:     regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}(?:[T ]\d{2}:\d{2}(?::\d{2}(?:\.\d+)?)?(?:Z|[+-]\d{2}:?\d{2})?)?\b`),
+			Replacement: "<date>",
+		},
+	}
+}
+
+func DefaultNormaliser() *Normaliser {
+	return &Normaliser{
+		Rules: DefaultRules(),
+		MaskHeaders: []string{
+			"Message-ID",
+			"Date",
+			"In-Reply-To",
+			"References",
+			"X-Message-ID",
+		},
+		IgnoreHeaders: []string{
+			"Received",
+			"X-Received",
+			"Return-Path",
+			"DKIM-Signature",
+			"ARC-Seal",
+			"ARC-Message-Signature",
+			"ARC-Authentication-Results",
+			"Authentication-Results",
+			"Received-SPF",
+			"X-Google-DKIM-Signature",
+			"X-Gm-Message-State",
+			"X-Google-Smtp-Source",
+		},
+		TrackingParams: []string{
+			"token",
+			"t",
+			"sig",
+			"signature",
+			"code",
+			"otp",
+			"key",
+			"hash",
+			"utm_source",
+			"utm_medium",
+			"utm_campaign",
+			"utm_term",
+			"utm_content",
+		},
+		MaskCodes: true,
+	}
+}
+
+func (n *Normaliser) Normalise(message *mailosaur.Message) *Snapshot {
+	s := &Snapshot{
+		Id:       maskId,
+		Server:   maskServer,
+		Received: maskReceived,
+		Type:     message.Type,
+	}
+
+	replacer := n.messageReplacer(message)
+	text := func(v string) string {
+		return n.applyRules(replacer.Replace(v), "")
+	}
+
+	s.Subject = text(message.Subject)
+	s.From = addresses(message.From, text)
+	s.To = addresses(message.To, text)
+	s.Cc = addresses(message.Cc, text)
+	s.Bcc = addresses(message.Bcc, text)
+
+	if message.Text != nil {
+		s.Text = text(message.Text.Body)
+	}
+	if message.Html != nil {
+		s.Html = text(message.Html.Body)
+	}
+
+	if message.Metadata != nil {
+		for _, h := range message.Metadata.Headers {
+			if h == nil || containsFold(n.IgnoreHeaders, h.Field) {
+				continue
+			}
+
+			value := maskHeader
+			if !containsFold(n.MaskHeaders, h.Field) {
+				value = n.applyRules(replacer.Replace(h.Value), h.Field)
+			}
+
+			s.Headers = append(s.Headers, &Header{Field: strings.ToLower(h.Field), Value: value})
+		}
+
+		sort.SliceStable(s.Headers, func(i, j int) bool {
+			return s.Headers[i].Field < s.Headers[j].Field
+		})
+	}
+
+	for _, c := range []*mailosaur.MessageContent{message.Html, message.Text} {
+		if c == nil {
+			continue
+		}
+		for _, l := range c.Links {
+			if l == nil {
+				continue
+			}
+			s.Links = append(s.Links, &Link{
+				Href: text(n.maskTrackingParams(replacer.Replace(l.Href))),
+				Text: text(l.Text),
+			})
+		}
+	}
+
+	for _, a := range message.Attachments {
+		if a == nil {
+			continue
+		}
+		s.Attachments = append(s.Attachments, &Attachment{
+			FileName:    text(a.FileName),
+			ContentType: a.ContentType,
+			ContentId:   a.ContentId,
+			Length:      a.Length,
+		})
+	}
+
+	return s
+}
+
+// messageReplacer masks values that are known to vary for every message.
+func (n *Normaliser) messageReplacer(message *mailosaur.Message) *strings.Replacer {
+	var pairs []string
+	if len(message.Id) > 0 {
+		pairs = append(pairs, message.Id, maskId)
+	}
+	if len(message.Server) > 0 {
+		pairs = append(pairs, message.Server, maskServer)
+	}
+
+	if n.MaskCodes {
+		seen := map[string]bool{}
+		for _, c := range []*mailosaur.MessageContent{message.Html, message.Text} {
+			if c == nil {
+				continue
+			}
+			for _, code := range c.Codes {
+				if code == nil || len(code.Value) == 0 || seen[code.Value] {
+					continue
+				}
+				seen[code.Value] = true
+				pairs = append(pairs, code.Value, maskCode)
+			}
+		}
+	}
+
+	return strings.NewReplacer(pairs...)
+}
+
+func (n *Normaliser) applyRules(value string, header string) string {
+	for _, r := range n.Rules {
+		if r.Pattern == nil {
+			continue
+		}
+		if len(r.Headers) > 0 && (len(header) == 0 || !containsFold(r.Headers, header)) {
+			continue
+		}
+		value = r.Pattern.ReplaceAllString(value, r.Replacement)
+	}
+	return value
+}
+
+func (n *Normaliser) maskTrackingParams(href string) string {
+	if len(n.TrackingParams) == 0 {
+		return href
+	}
+
+	u, err := url.Parse(href)
+	if err != nil || len(u.RawQuery) == 0 {
+		return href
+	}
+
+	parts := strings.Split(u.RawQuery, "&")
+	for i, p := range parts {
+		key := p
+		if idx := strings.Index(p, "="); idx >= 0 {
+			key = p[:idx]
+		}
+		if name, err := url.QueryUnescape(key); err == nil && containsFold(n.TrackingParams, name) {
+			parts[i] = key + "=" + maskToken
+		}
+	}
+	u.RawQuery = strings.Join(parts, "&")
+
+	return u.String()
+}
+
+func addresses(list []*mailosaur.MessageAddress, text func(string) string) []string {
+	var result []string
+	for _, a := range list {
+		if a == nil {
+			continue
+		}
+		value := a.Email
+		if len(value) == 0 {
+			value = a.Phone
+		}
+		if len(a.Name) > 0 {
+			value = a.Name + " <" + value + ">"
+		}
+		result = append(result, text(value))
+	}
+	return result
+}
+
+func containsFold(list []string, value string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+// Package mailosaursnapshot compares received messages against golden files,
+// so that unintended changes to email templates fail a test run.
+//
+// Golden files are rewritten by running the tests with -mailosaur.update:
+//
+//	go test ./... -args -mailosaur.update
+//
+// A boolean -update flag registered by the test package is honoured too.
+package mailosaursnapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mailosaur/mailosaur-go"
+)
+
+// The flag has a distinctive name so that it does not clash with an -update
+// flag defined by the test package itself.
+var update = flag.Bool("mailosaur.update", false, "rewrite mailosaur golden snapshot files")
+
+type Header struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+type Link struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+type Attachment struct {
+	FileName    string `json:"fileName"`
+	ContentType string `json:"contentType"`
+	ContentId   string `json:"contentId,omitempty"`
+	Length      int    `json:"length"`
+}
+
+type Snapshot struct {
+	Id          string        `json:"id"`
+	Type        string        `json:"type"`
+	Server      string        `json:"server"`
+	Received    string        `json:"received"`
+	Subject     string        `json:"subject"`
+	From        []string      `json:"from"`
+	To          []string      `json:"to"`
+	Cc          []string      `json:"cc,omitempty"`
+	Bcc         []string      `json:"bcc,omitempty"`
+	Headers     []*Header     `json:"headers,omitempty"`
+	Links       []*Link       `json:"links,omitempty"`
+	Attachments []*Attachment `json:"attachments,omitempty"`
+	Text        string        `json:"text"`
+	Html        string        `json:"html"`
+}
+
+type Snapshotter struct {
+	// Dir is where golden files are kept, defaulting to testdata/snapshots.
+	Dir        string
+	Normaliser *Normaliser
+
+	// Update rewrites golden files instead of comparing against them. It is
+	// also enabled by running the tests with -mailosaur.update, or with
+	// -update when the test package defines its own update flag.
+	Update bool
+}
+
+func New() *Snapshotter {
+	return &Snapshotter{
+		Dir:        filepath.Join("testdata", "snapshots"),
+		Normaliser: DefaultNormaliser(),
+	}
+}
+
+// Match compares the message against the golden file for name using the
+// default settings.
+func Match(t testing.TB, name string, message *mailosaur.Message) bool {
+	t.Helper()
+	return New().Match(t, name, message)
+}
+
+func (s *Snapshotter) Match(t testing.TB, name string, message *mailosaur.Message) bool {
+	t.Helper()
+
+	if message == nil {
+		t.Errorf("Snapshot %q: expected a message but got nil", name)
+		return false
+	}
+
+	actual, err := s.Encode(message)
+	if err != nil {
+		t.Errorf("Snapshot %q: %s", name, err)
+		return false
+	}
+
+	path := s.Path(name)
+
+	if s.Update || updating() {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Errorf("Snapshot %q: %s", name, err)
+			return false
+		}
+		if err := os.WriteFile(path, actual, 0644); err != nil {
+			t.Errorf("Snapshot %q: %s", name, err)
+			return false
+		}
+		return true
+	}
+
+	expected, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Errorf("Snapshot %q: golden file %s does not exist, run the tests with -mailosaur.update to create it", name, path)
+		return false
+	}
+	if err != nil {
+		t.Errorf("Snapshot %q: %s", name, err)
+		return false
+	}
+
+	if !bytes.Equal(normaliseLineEndings(expected), actual) {
+		t.Errorf("Snapshot %q does not match %s (run with -mailosaur.update to accept):\n%s", name, path, lineDiff(string(expected), string(actual)))
+		return false
+	}
+
+	return true
+}
+
+// updating reports whether golden files should be rewritten. An -update
+// flag is only looked up when it is used, as it is registered by the test
+// package rather than by this one.
+func updating() bool {
+	if *update {
+		return true
+	}
+	if f := flag.Lookup("update"); f != nil {
+		if getter, ok := f.Value.(flag.Getter); ok {
+			if value, ok := getter.Get().(bool); ok {
+				return value
+			}
+		}
+	}
+	return false
+}
+
+func (s *Snapshotter) Encode(message *mailosaur.Message) ([]byte, error) {
+	n := s.Normaliser
+	if n == nil {
+		n = DefaultNormaliser()
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(n.Normalise(message)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func (s *Snapshotter) Path(name string) string {
+	dir := s.Dir
+	if len(dir) == 0 {
+		dir = filepath.Join("testdata", "snapshots")
+	}
+	return filepath.Join(dir, unsafeName.ReplaceAllString(name, "_")+".golden.json")
+}
+
+func normaliseLineEndings(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+}
+
+// lineDiff renders a minimal unified-style diff of two texts.
+func lineDiff(expected string, actual string) string {
+	a := strings.Split(strings.TrimSuffix(expected, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(actual, "\n"), "\n")
+
+	// Longest common subsequence table
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("--- expected\n+++ actual\n")
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			fmt.Fprintf(&sb, "+ %s\n", b[j])
+			j++
+		default:
+			fmt.Fprintf(&sb, "- %s\n", a[i])
+			i++
+		}
+	}
+
+	return sb.String()
+}
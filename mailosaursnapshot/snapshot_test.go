@@ -0,0 +1,140 @@
+package mailosaursnapshot
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mailosaur/mailosaur-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockT struct {
+	testing.TB
+	errors []string
+}
+
+func (m *mockT) Helper() {}
+
+func (m *mockT) Errorf(format string, args ...interface{}) {
+	m.errors = append(m.errors, fmt.Sprintf(format, args...))
+}
+
+func testMessage(id string, code string) *mailosaur.Message {
+	return &mailosaur.Message{
+		Id:       id,
+		Type:     "Email",
+		Server:   "abcd1234",
+		Received: time.Now(),
+		Subject:  "Your code is " + code,
+		From:     []*mailosaur.MessageAddress{{Name: "Acme", Email: "noreply@acme.example"}},
+		To:       []*mailosaur.MessageAddress{{Email: "jo@abcd1234.mailosaur.net"}},
+		Html: &mailosaur.MessageContent{
+			Body:  "<p>Use " + code + " before " + time.Now().Format(time.RFC1123Z) + "</p>",
+			Links: []*mailosaur.Link{{Href: "https://acme.example/verify?token=" + id + "x&lang=en", Text: "Verify"}},
+			Codes: []*mailosaur.Code{{Value: code}},
+		},
+		Text: &mailosaur.MessageContent{
+			Body:  "Use " + code + " (ref " + id + ")",
+			Codes: []*mailosaur.Code{{Value: code}},
+		},
+		Attachments: []*mailosaur.Attachment{
+			{Id: id, FileName: "invoice.pdf", ContentType: "application/pdf", Length: 1024},
+		},
+		Metadata: &mailosaur.Metadata{
+			Headers: []*mailosaur.MessageHeader{
+				{Field: "Subject", Value: "Your code is " + code},
+				{Field: "Message-ID", Value: "<" + id + "@acme.example>"},
+				{Field: "Received", Value: "from mx.acme.example"},
+				{Field: "X-Campaign", Value: "batch-" + time.Now().Format("2006-01-02")},
+			},
+		},
+	}
+}
+
+func TestNormalise(t *testing.T) {
+	s := DefaultNormaliser().Normalise(testMessage("5f4c8d", "123456"))
+
+	assert.Equal(t, "<id>", s.Id)
+	assert.Equal(t, "<server>", s.Server)
+	assert.Equal(t, "<received>", s.Received)
+	assert.Equal(t, "Your code is <code>", s.Subject)
+	assert.Equal(t, []string{"jo@<server>.mailosaur.net"}, s.To)
+	assert.Equal(t, "<p>Use <code> before <date></p>", s.Html)
+	assert.Equal(t, "Use <code> (ref <id>)", s.Text)
+	assert.Equal(t, "https://acme.example/verify?token=<token>&lang=en", s.Links[0].Href)
+
+	assert.Equal(t, 3, len(s.Headers))
+	assert.Equal(t, "message-id", s.Headers[0].Field)
+	assert.Equal(t, "<masked>", s.Headers[0].Value)
+	assert.Equal(t, "x-campaign", s.Headers[2].Field)
+	assert.Equal(t, "batch-<date>", s.Headers[2].Value)
+}
+
+func TestCustomRule(t *testing.T) {
+	n := DefaultNormaliser()
+	n.Rules = append(n.Rules, Rule{
+		Name:        "reference",
+		Pattern:     regexp.MustCompile(`batch-\S+`),
+		Replacement: "batch-<ref>",
+		Headers:     []string{"X-Campaign"},
+	})
+
+	s := n.Normalise(testMessage("5f4c8d", "123456"))
+	assert.Equal(t, "batch-<ref>", s.Headers[2].Value)
+}
+
+func TestMatch(t *testing.T) {
+	dir := t.TempDir()
+	s := New()
+	s.Dir = dir
+
+	m := &mockT{}
+	assert.False(t, s.Match(m, "welcome", testMessage("first", "123456")))
+	assert.Contains(t, m.errors[0], "does not exist")
+
+	s.Update = true
+	assert.True(t, s.Match(t, "welcome", testMessage("first", "123456")))
+	_, err := os.Stat(s.Path("welcome"))
+	assert.NoError(t, err)
+
+	// Volatile values differ, but the normalised snapshot is the same
+	s.Update = false
+	assert.True(t, s.Match(t, "welcome", testMessage("second", "987654")))
+
+	changed := testMessage("third", "111111")
+	changed.Text.Body = "Enter " + "111111"
+
+	m = &mockT{}
+	assert.False(t, s.Match(m, "welcome", changed))
+	assert.Equal(t, 1, len(m.errors))
+	assert.Contains(t, m.errors[0], `-   "text": "Use <code> (ref <id>)",`)
+	assert.Contains(t, m.errors[0], `+   "text": "Enter <code>",`)
+}
+
+func TestPath(t *testing.T) {
+	s := &Snapshotter{Dir: "golden"}
+	assert.True(t, strings.HasSuffix(s.Path("password reset/en"), "password_reset_en.golden.json"))
+}
+
+// The usual golden file flag, defined by a test package that uses snapshots
+var ownUpdate = flag.Bool("update", false, "rewrite golden files")
+
+func TestUpdateFlag(t *testing.T) {
+	assert.False(t, updating())
+
+	flag.Set("update", "true")
+	defer flag.Set("update", "false")
+	assert.True(t, *ownUpdate)
+	assert.True(t, updating())
+
+	s := New()
+	s.Dir = t.TempDir()
+	assert.True(t, s.Match(t, "welcome", testMessage("first", "123456")))
+	_, err := os.Stat(s.Path("welcome"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,173 @@
+package mailosaurusage
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mailosaur/mailosaur-go"
+)
+
+// WriteCSV writes one row per rollup, with a header row. The environment
+// column is included when environment is not empty, so that exports from
+// several accounts can be concatenated.
+func WriteCSV(w io.Writer, rollups []*Rollup, environment string) error {
+	cw := csv.NewWriter(w)
+
+	header := []string{"period_start", "period_end", "email", "sms"}
+	if len(environment) > 0 {
+		header = append([]string{"environment"}, header...)
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, r := range rollups {
+		row := []string{
+			r.Start.Format(time.RFC3339),
+			r.End.Format(time.RFC3339),
+			strconv.Itoa(r.Email),
+			strconv.Itoa(r.Sms),
+		}
+		if len(environment) > 0 {
+			row = append([]string{environment}, row...)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+const (
+	Gauge   = "gauge"
+	Counter = "counter"
+)
+
+// Metric is a family of samples in the Prometheus text exposition format.
+type Metric struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []*Sample
+}
+
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+func (m *Metric) Add(value float64, labels map[string]string) {
+	m.Samples = append(m.Samples, &Sample{Labels: labels, Value: value})
+}
+
+// WriteMetrics writes metrics in the Prometheus text exposition format
+// (version 0.0.4). Metrics without samples are skipped.
+func WriteMetrics(w io.Writer, metrics ...*Metric) error {
+	bw := bufio.NewWriter(w)
+
+	for _, m := range metrics {
+		if len(m.Samples) == 0 {
+			continue
+		}
+
+		if len(m.Help) > 0 {
+			fmt.Fprintf(bw, "# HELP %s %s\n", m.Name, escapeHelp(m.Help))
+		}
+		if len(m.Type) > 0 {
+			fmt.Fprintf(bw, "# TYPE %s %s\n", m.Name, m.Type)
+		}
+
+		for _, s := range m.Samples {
+			fmt.Fprintf(bw, "%s%s %s\n", m.Name, formatLabels(s.Labels), strconv.FormatFloat(s.Value, 'g', -1, 64))
+		}
+	}
+
+	return bw.Flush()
+}
+
+// UsageMetrics returns gauges for the account limits and current usage of
+// each resource, and for any projections. labels, such as an environment
+// name, are added to every sample.
+func UsageMetrics(labels map[string]string, limits *mailosaur.UsageAccountLimits, projections []*Projection) []*Metric {
+	limit := &Metric{Name: "mailosaur_usage_limit", Help: "Account limit for the current period.", Type: Gauge}
+	current := &Metric{Name: "mailosaur_usage_current", Help: "Usage in the current period.", Type: Gauge}
+	projected := &Metric{Name: "mailosaur_usage_projected", Help: "Projected usage at the end of the current period.", Type: Gauge}
+	rate := &Metric{Name: "mailosaur_usage_daily_rate", Help: "Average daily usage over the projection window.", Type: Gauge}
+
+	if limits != nil {
+		resources := []struct {
+			name  string
+			value *mailosaur.UsageAccountLimit
+		}{
+			{mailosaur.QuotaServers, limits.Servers},
+			{mailosaur.QuotaUsers, limits.Users},
+			{mailosaur.QuotaEmail, limits.Email},
+			{mailosaur.QuotaSms, limits.Sms},
+		}
+
+		for _, r := range resources {
+			if r.value == nil {
+				continue
+			}
+			l := withLabel(labels, "resource", r.name)
+			limit.Add(float64(r.value.Limit), l)
+			current.Add(float64(r.value.Current), l)
+		}
+	}
+
+	for _, p := range projections {
+		l := withLabel(labels, "resource", p.Resource)
+		projected.Add(float64(p.Projected), l)
+		rate.Add(p.DailyRate, l)
+	}
+
+	return []*Metric{limit, current, projected, rate}
+}
+
+func WritePrometheus(w io.Writer, labels map[string]string, limits *mailosaur.UsageAccountLimits, projections []*Projection) error {
+	return WriteMetrics(w, UsageMetrics(labels, limits, projections)...)
+}
+
+func withLabel(labels map[string]string, name string, value string) map[string]string {
+	result := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		result[k] = v
+	}
+	result[name] = value
+	return result
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + `="` + labelEscaper.Replace(labels[name]) + `"`
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
@@ -0,0 +1,104 @@
+package mailosaurusage
+
+import (
+	"math"
+	"time"
+
+	"github.com/mailosaur/mailosaur-go"
+)
+
+type ProjectionOptions struct {
+	// Now defaults to the current time.
+	Now time.Time
+	// Window is the trailing period used to measure the rate of
+	// consumption, defaulting to 7 days.
+	Window time.Duration
+	// PeriodEnd is when the account limits reset, defaulting to the start
+	// of the next calendar month in UTC.
+	PeriodEnd time.Time
+}
+
+// Projection estimates usage of a resource at the end of the limit period,
+// assuming consumption continues at the rate seen over the window.
+type Projection struct {
+	Resource  string
+	Current   int
+	Limit     int
+	DailyRate float64
+	Projected int
+	PeriodEnd time.Time
+	// ExhaustedAt is when the limit will be reached, or zero if that is
+	// not expected before the period ends.
+	ExhaustedAt time.Time
+}
+
+func (p *Projection) Exceeds() bool {
+	return p.Limit > 0 && p.Projected > p.Limit
+}
+
+// Project returns email and SMS projections. Resources without a limit are
+// left out.
+func Project(transactions []*mailosaur.UsageTransaction, limits *mailosaur.UsageAccountLimits, options *ProjectionOptions) []*Projection {
+	o := ProjectionOptions{}
+	if options != nil {
+		o = *options
+	}
+	if o.Now.IsZero() {
+		o.Now = time.Now()
+	}
+	if o.Window == 0 {
+		o.Window = 7 * 24 * time.Hour
+	}
+	if o.PeriodEnd.IsZero() {
+		o.PeriodEnd = PeriodEnd(PeriodStart(o.Now.UTC(), Monthly), Monthly)
+	}
+
+	windowStart := o.Now.Add(-o.Window)
+	var email, sms int
+	for _, t := range transactions {
+		if t != nil && !t.Timestamp.Before(windowStart) && !t.Timestamp.After(o.Now) {
+			email += t.Email
+			sms += t.Sms
+		}
+	}
+
+	days := o.Window.Hours() / 24
+
+	var result []*Projection
+	if limits != nil && limits.Email != nil && limits.Email.Limit > 0 {
+		result = append(result, project(mailosaur.QuotaEmail, limits.Email, float64(email)/days, &o))
+	}
+	if limits != nil && limits.Sms != nil && limits.Sms.Limit > 0 {
+		result = append(result, project(mailosaur.QuotaSms, limits.Sms, float64(sms)/days, &o))
+	}
+
+	return result
+}
+
+func project(resource string, limit *mailosaur.UsageAccountLimit, rate float64, o *ProjectionOptions) *Projection {
+	p := &Projection{
+		Resource:  resource,
+		Current:   limit.Current,
+		Limit:     limit.Limit,
+		DailyRate: rate,
+		PeriodEnd: o.PeriodEnd,
+	}
+
+	remaining := o.PeriodEnd.Sub(o.Now).Hours() / 24
+	if remaining < 0 {
+		remaining = 0
+	}
+	p.Projected = limit.Current + int(math.Round(rate*remaining))
+
+	if limit.Limit > 0 && rate > 0 {
+		daysLeft := float64(limit.Limit-limit.Current) / rate
+		if daysLeft < 0 {
+			daysLeft = 0
+		}
+		if daysLeft <= remaining {
+			p.ExhaustedAt = o.Now.Add(time.Duration(daysLeft * 24 * float64(time.Hour)))
+		}
+	}
+
+	return p
+}
@@ -0,0 +1,112 @@
+// Package mailosaurusage aggregates Mailosaur usage transactions, projects
+// consumption against account limits and exports both as CSV or in the
+// Prometheus text exposition format.
+package mailosaurusage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/mailosaur/mailosaur-go"
+)
+
+type Period string
+
+const (
+	Daily   Period = "daily"
+	Weekly  Period = "weekly"
+	Monthly Period = "monthly"
+)
+
+// Rollup is the total usage for one period, from Start up to but not
+// including End.
+type Rollup struct {
+	Start time.Time
+	End   time.Time
+	Email int
+	Sms   int
+}
+
+// Aggregate groups transactions into periods in the given location (UTC
+// when nil). Weeks start on Monday. Periods without any transactions,
+// between the first and last, are included with zero counts.
+func Aggregate(transactions []*mailosaur.UsageTransaction, period Period, loc *time.Location) []*Rollup {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	buckets := map[time.Time]*Rollup{}
+	var first, last time.Time
+
+	for _, t := range transactions {
+		if t == nil {
+			continue
+		}
+
+		start := PeriodStart(t.Timestamp.In(loc), period)
+		r, ok := buckets[start]
+		if !ok {
+			r = &Rollup{Start: start, End: PeriodEnd(start, period)}
+			buckets[start] = r
+		}
+		r.Email += t.Email
+		r.Sms += t.Sms
+
+		if first.IsZero() || start.Before(first) {
+			first = start
+		}
+		if start.After(last) {
+			last = start
+		}
+	}
+
+	if len(buckets) == 0 {
+		return []*Rollup{}
+	}
+
+	for start := first; !start.After(last); start = PeriodEnd(start, period) {
+		if _, ok := buckets[start]; !ok {
+			buckets[start] = &Rollup{Start: start, End: PeriodEnd(start, period)}
+		}
+	}
+
+	result := make([]*Rollup, 0, len(buckets))
+	for _, r := range buckets {
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Start.Before(result[j].Start)
+	})
+
+	return result
+}
+
+// PeriodStart returns the start of the period containing t, in t's
+// location.
+func PeriodStart(t time.Time, period Period) time.Time {
+	year, month, day := t.Date()
+
+	switch period {
+	case Weekly:
+		// Monday is the first day of the week
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, t.Location())
+	case Monthly:
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// PeriodEnd returns the start of the period following the one that starts
+// at start.
+func PeriodEnd(start time.Time, period Period) time.Time {
+	switch period {
+	case Weekly:
+		return start.AddDate(0, 0, 7)
+	case Monthly:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
@@ -0,0 +1,150 @@
+package mailosaurusage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mailosaur/mailosaur-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func at(day int, hour int) time.Time {
+	return time.Date(2024, 1, day, hour, 0, 0, 0, time.UTC)
+}
+
+func testTransactions() []*mailosaur.UsageTransaction {
+	return []*mailosaur.UsageTransaction{
+		{Timestamp: at(1, 9), Email: 10, Sms: 1},
+		{Timestamp: at(1, 23), Email: 5},
+		{Timestamp: at(3, 12), Email: 7, Sms: 2},
+		{Timestamp: at(8, 0), Email: 1},
+		{Timestamp: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Sms: 4},
+	}
+}
+
+func TestAggregateDaily(t *testing.T) {
+	rollups := Aggregate(testTransactions()[:3], Daily, nil)
+
+	assert.Equal(t, 3, len(rollups))
+	assert.Equal(t, at(1, 0), rollups[0].Start)
+	assert.Equal(t, at(2, 0), rollups[0].End)
+	assert.Equal(t, 15, rollups[0].Email)
+	assert.Equal(t, 1, rollups[0].Sms)
+
+	// Gaps are filled
+	assert.Equal(t, at(2, 0), rollups[1].Start)
+	assert.Equal(t, 0, rollups[1].Email)
+
+	assert.Equal(t, 7, rollups[2].Email)
+}
+
+func TestAggregateWeeklyAndMonthly(t *testing.T) {
+	weekly := Aggregate(testTransactions(), Weekly, nil)
+
+	// 1 Jan 2024 is a Monday
+	assert.Equal(t, at(1, 0), weekly[0].Start)
+	assert.Equal(t, 22, weekly[0].Email)
+	assert.Equal(t, at(8, 0), weekly[1].Start)
+	assert.Equal(t, 1, weekly[1].Email)
+	assert.Equal(t, time.Date(2024, 1, 29, 0, 0, 0, 0, time.UTC), weekly[len(weekly)-1].Start)
+	assert.Equal(t, 4, weekly[len(weekly)-1].Sms)
+
+	monthly := Aggregate(testTransactions(), Monthly, nil)
+	assert.Equal(t, 2, len(monthly))
+	assert.Equal(t, 23, monthly[0].Email)
+	assert.Equal(t, 3, monthly[0].Sms)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), monthly[0].End)
+
+	assert.Equal(t, 0, len(Aggregate(nil, Daily, nil)))
+}
+
+func TestAggregateInLocation(t *testing.T) {
+	loc := time.FixedZone("UTC-5", -5*3600)
+	rollups := Aggregate(testTransactions()[:2], Daily, loc)
+
+	// 23:00 UTC is 18:00 in UTC-5, so both fall on 1 January locally
+	assert.Equal(t, 1, len(rollups))
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, loc), rollups[0].Start)
+
+	// 09:00 UTC on 1 January is 04:00 locally
+	assert.Equal(t, 15, rollups[0].Email)
+}
+
+func TestProject(t *testing.T) {
+	transactions := []*mailosaur.UsageTransaction{
+		{Timestamp: at(5, 0), Email: 700, Sms: 7},
+		{Timestamp: at(9, 0), Email: 700, Sms: 7},
+		// Outside the window
+		{Timestamp: at(1, 0), Email: 5000},
+	}
+	limits := &mailosaur.UsageAccountLimits{
+		Email:   &mailosaur.UsageAccountLimit{Limit: 5000, Current: 2000},
+		Sms:     &mailosaur.UsageAccountLimit{Limit: 100, Current: 14},
+		Servers: &mailosaur.UsageAccountLimit{Limit: 10, Current: 2},
+	}
+
+	projections := Project(transactions, limits, &ProjectionOptions{Now: at(11, 0)})
+	assert.Equal(t, 2, len(projections))
+
+	email := projections[0]
+	assert.Equal(t, mailosaur.QuotaEmail, email.Resource)
+	assert.Equal(t, 200.0, email.DailyRate)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), email.PeriodEnd)
+	// 21 days remain at 200 a day
+	assert.Equal(t, 6200, email.Projected)
+	assert.True(t, email.Exceeds())
+	assert.Equal(t, at(26, 0), email.ExhaustedAt)
+
+	sms := projections[1]
+	assert.Equal(t, 2.0, sms.DailyRate)
+	assert.Equal(t, 56, sms.Projected)
+	assert.False(t, sms.Exceeds())
+	assert.True(t, sms.ExhaustedAt.IsZero())
+
+	// Resources without a limit are left out
+	limits.Sms.Limit = 0
+	projections = Project(transactions, limits, &ProjectionOptions{Now: at(11, 0)})
+	assert.Equal(t, 1, len(projections))
+	assert.Equal(t, mailosaur.QuotaEmail, projections[0].Resource)
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteCSV(&buf, Aggregate(testTransactions()[:2], Daily, nil), "staging"))
+
+	assert.Equal(t, "environment,period_start,period_end,email,sms\n"+
+		"staging,2024-01-01T00:00:00Z,2024-01-02T00:00:00Z,15,1\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, WriteCSV(&buf, nil, ""))
+	assert.Equal(t, "period_start,period_end,email,sms\n", buf.String())
+}
+
+func TestWritePrometheus(t *testing.T) {
+	limits := &mailosaur.UsageAccountLimits{
+		Email: &mailosaur.UsageAccountLimit{Limit: 5000, Current: 2000},
+	}
+	projections := []*Projection{{Resource: mailosaur.QuotaEmail, Projected: 6200, DailyRate: 200.5}}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WritePrometheus(&buf, map[string]string{"environment": `st"aging`}, limits, projections))
+
+	expected := strings.Join([]string{
+		"# HELP mailosaur_usage_limit Account limit for the current period.",
+		"# TYPE mailosaur_usage_limit gauge",
+		`mailosaur_usage_limit{environment="st\"aging",resource="email"} 5000`,
+		"# HELP mailosaur_usage_current Usage in the current period.",
+		"# TYPE mailosaur_usage_current gauge",
+		`mailosaur_usage_current{environment="st\"aging",resource="email"} 2000`,
+		"# HELP mailosaur_usage_projected Projected usage at the end of the current period.",
+		"# TYPE mailosaur_usage_projected gauge",
+		`mailosaur_usage_projected{environment="st\"aging",resource="email"} 6200`,
+		"# HELP mailosaur_usage_daily_rate Average daily usage over the projection window.",
+		"# TYPE mailosaur_usage_daily_rate gauge",
+		`mailosaur_usage_daily_rate{environment="st\"aging",resource="email"} 200.5`,
+		"",
+	}, "\n")
+	assert.Equal(t, expected, buf.String())
+}
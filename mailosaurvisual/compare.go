@@ -0,0 +1,147 @@
+// Package mailosaurvisual compares email client previews against stored
+// baseline screenshots, so that rendering regressions can fail a test run.
+package mailosaurvisual
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+type CompareOptions struct {
+	// Tolerance is the largest per-channel colour difference, from 0 to 1,
+	// at which two pixels are still considered equal.
+	Tolerance float64
+
+	// Ignore lists regions, in baseline coordinates, that are excluded from
+	// the comparison.
+	Ignore []image.Rectangle
+}
+
+type Diff struct {
+	DiffPixels    int
+	IgnoredPixels int
+	TotalPixels   int
+
+	// Image highlights differing pixels in red over a faded copy of the
+	// actual image, with ignored regions shaded blue.
+	Image *image.RGBA
+}
+
+func (d *Diff) Ratio() float64 {
+	compared := d.TotalPixels - d.IgnoredPixels
+	if compared <= 0 {
+		return 0
+	}
+	return float64(d.DiffPixels) / float64(compared)
+}
+
+var (
+	diffColor    = color.RGBA{R: 255, A: 255}
+	ignoreColor  = color.RGBA{B: 255, A: 255}
+	missingColor = color.RGBA{R: 255, G: 0, B: 255, A: 255}
+)
+
+// Compare diffs two images pixel by pixel. When the sizes differ, pixels that
+// only exist in one of the images are counted as different.
+func Compare(baseline image.Image, actual image.Image, options CompareOptions) *Diff {
+	bb := baseline.Bounds()
+	ab := actual.Bounds()
+
+	width := maxInt(bb.Dx(), ab.Dx())
+	height := maxInt(bb.Dy(), ab.Dy())
+
+	d := &Diff{
+		TotalPixels: width * height,
+		Image:       image.NewRGBA(image.Rect(0, 0, width, height)),
+	}
+	draw.Draw(d.Image, d.Image.Bounds(), image.White, image.Point{}, draw.Src)
+
+	limit := uint32(options.Tolerance * 0xffff)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			p := image.Pt(x, y)
+			inBaseline := p.In(image.Rect(0, 0, bb.Dx(), bb.Dy()))
+			inActual := p.In(image.Rect(0, 0, ab.Dx(), ab.Dy()))
+
+			if ignored(p, options.Ignore) {
+				d.IgnoredPixels++
+				if inActual {
+					d.Image.Set(x, y, blend(fade(actual.At(ab.Min.X+x, ab.Min.Y+y)), ignoreColor))
+				} else {
+					d.Image.Set(x, y, ignoreColor)
+				}
+				continue
+			}
+
+			if !inBaseline || !inActual {
+				d.DiffPixels++
+				d.Image.Set(x, y, missingColor)
+				continue
+			}
+
+			b := baseline.At(bb.Min.X+x, bb.Min.Y+y)
+			a := actual.At(ab.Min.X+x, ab.Min.Y+y)
+
+			if equalWithin(b, a, limit) {
+				d.Image.Set(x, y, fade(a))
+			} else {
+				d.DiffPixels++
+				d.Image.Set(x, y, diffColor)
+			}
+		}
+	}
+
+	return d
+}
+
+func ignored(p image.Point, regions []image.Rectangle) bool {
+	for _, r := range regions {
+		if p.In(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalWithin(a color.Color, b color.Color, limit uint32) bool {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+
+	return delta(ar, br) <= limit &&
+		delta(ag, bg) <= limit &&
+		delta(ab, bb) <= limit &&
+		delta(aa, ba) <= limit
+}
+
+func delta(a uint32, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// fade converts a colour to a light grey, so that highlighted differences
+// stand out in the diff image.
+func fade(c color.Color) color.Color {
+	g := color.GrayModel.Convert(c).(color.Gray)
+	return color.Gray{Y: 192 + g.Y/4}
+}
+
+func blend(base color.Color, overlay color.RGBA) color.Color {
+	r, g, b, _ := base.RGBA()
+	return color.RGBA{
+		R: uint8((r>>8)/2 + uint32(overlay.R)/2),
+		G: uint8((g>>8)/2 + uint32(overlay.G)/2),
+		B: uint8((b>>8)/2 + uint32(overlay.B)/2),
+		A: 255,
+	}
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
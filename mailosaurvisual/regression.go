@@ -0,0 +1,202 @@
+package mailosaurvisual
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type Options struct {
+	// BaselineDir holds one PNG per email client, named after
+	// EmailClient.Name.
+	BaselineDir string
+
+	// DiffDir is where diff images are written for failed comparisons. No
+	// diff images are written when it is empty.
+	DiffDir string
+
+	Tolerance float64
+
+	// MaxDiffRatio is the fraction of compared pixels that may differ before
+	// a client fails.
+	MaxDiffRatio float64
+
+	// Ignore lists regions to exclude per email client name. Regions under
+	// the "*" key apply to every client.
+	Ignore map[string][]image.Rectangle
+
+	// UpdateBaselines writes the actual preview as the new baseline when a
+	// client has no baseline yet, instead of failing it.
+	UpdateBaselines bool
+}
+
+type Result struct {
+	EmailClient  string
+	Passed       bool
+	NewBaseline  bool
+	DiffPixels   int
+	TotalPixels  int
+	DiffRatio    float64
+	BaselinePath string
+	DiffPath     string
+	Error        error
+}
+
+type Report struct {
+	Results []*Result
+}
+
+func (r *Report) Passed() bool {
+	for _, res := range r.Results {
+		if !res.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Report) Failed() []*Result {
+	var failed []*Result
+	for _, res := range r.Results {
+		if !res.Passed {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+func (r *Report) String() string {
+	var sb strings.Builder
+	for _, res := range r.Results {
+		status := "PASS"
+		if !res.Passed {
+			status = "FAIL"
+		}
+
+		fmt.Fprintf(&sb, "%s %s", status, res.EmailClient)
+		switch {
+		case res.Error != nil:
+			fmt.Fprintf(&sb, ": %s", res.Error)
+		case res.NewBaseline:
+			sb.WriteString(": baseline created")
+		default:
+			fmt.Fprintf(&sb, ": %d of %d pixels differ (%.3f%%)", res.DiffPixels, res.TotalPixels, res.DiffRatio*100)
+			if len(res.DiffPath) > 0 {
+				fmt.Fprintf(&sb, ", see %s", res.DiffPath)
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+type Comparer struct {
+	options Options
+}
+
+func New(options Options) *Comparer {
+	return &Comparer{options: options}
+}
+
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func (c *Comparer) BaselinePath(emailClient string) string {
+	return filepath.Join(c.options.BaselineDir, unsafeName.ReplaceAllString(emailClient, "_")+".png")
+}
+
+func (c *Comparer) diffPath(emailClient string) string {
+	return filepath.Join(c.options.DiffDir, unsafeName.ReplaceAllString(emailClient, "_")+".diff.png")
+}
+
+// Compare checks a single preview, as returned by FilesService.GetPreview,
+// against the baseline for the email client.
+func (c *Comparer) Compare(emailClient string, preview []byte) *Result {
+	res := &Result{
+		EmailClient:  emailClient,
+		BaselinePath: c.BaselinePath(emailClient),
+	}
+
+	actual, err := png.Decode(bytes.NewReader(preview))
+	if err != nil {
+		res.Error = fmt.Errorf("decoding preview: %w", err)
+		return res
+	}
+
+	baselineFile, err := os.ReadFile(res.BaselinePath)
+	if os.IsNotExist(err) && c.options.UpdateBaselines {
+		if err := writeFile(res.BaselinePath, preview); err != nil {
+			res.Error = err
+			return res
+		}
+		res.Passed = true
+		res.NewBaseline = true
+		return res
+	}
+	if err != nil {
+		res.Error = fmt.Errorf("reading baseline: %w", err)
+		return res
+	}
+
+	baseline, err := png.Decode(bytes.NewReader(baselineFile))
+	if err != nil {
+		res.Error = fmt.Errorf("decoding baseline: %w", err)
+		return res
+	}
+
+	var ignore []image.Rectangle
+	ignore = append(ignore, c.options.Ignore["*"]...)
+	ignore = append(ignore, c.options.Ignore[emailClient]...)
+
+	d := Compare(baseline, actual, CompareOptions{
+		Tolerance: c.options.Tolerance,
+		Ignore:    ignore,
+	})
+
+	res.DiffPixels = d.DiffPixels
+	res.TotalPixels = d.TotalPixels
+	res.DiffRatio = d.Ratio()
+	res.Passed = res.DiffRatio <= c.options.MaxDiffRatio
+
+	if !res.Passed && len(c.options.DiffDir) > 0 {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, d.Image); err != nil {
+			res.Error = err
+			return res
+		}
+		res.DiffPath = c.diffPath(emailClient)
+		if err := writeFile(res.DiffPath, buf.Bytes()); err != nil {
+			res.Error = err
+		}
+	}
+
+	return res
+}
+
+// CompareAll checks a set of previews keyed by email client name, returning
+// a report ordered by client name.
+func (c *Comparer) CompareAll(previews map[string][]byte) *Report {
+	names := make([]string, 0, len(previews))
+	for name := range previews {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	report := &Report{}
+	for _, name := range names {
+		report.Results = append(report.Results, c.Compare(name, previews[name]))
+	}
+	return report
+}
+
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,142 @@
+package mailosaurvisual
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	data, err := os.ReadFile(filepath.Join("..", "testing", name))
+	assert.NoError(t, err)
+	return data
+}
+
+// modified returns a copy of the PNG with a solid square drawn over it.
+func modified(t *testing.T, data []byte, r image.Rectangle) []byte {
+	img, err := png.Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+
+	out := image.NewRGBA(img.Bounds())
+	draw.Draw(out, out.Bounds(), img, img.Bounds().Min, draw.Src)
+	draw.Draw(out, r, &image.Uniform{C: color.RGBA{G: 255, A: 255}}, image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, out))
+	return buf.Bytes()
+}
+
+func TestCompareIdentical(t *testing.T) {
+	cat := readFixture(t, "cat.png")
+	img, _ := png.Decode(bytes.NewReader(cat))
+
+	d := Compare(img, img, CompareOptions{})
+	assert.Equal(t, 0, d.DiffPixels)
+	assert.Equal(t, 300*199, d.TotalPixels)
+	assert.Equal(t, 0.0, d.Ratio())
+}
+
+func TestCompareDifferentSizes(t *testing.T) {
+	cat, _ := png.Decode(bytes.NewReader(readFixture(t, "cat.png")))
+	dog, _ := png.Decode(bytes.NewReader(readFixture(t, "dog.png")))
+
+	d := Compare(cat, dog, CompareOptions{})
+	assert.Equal(t, 500*375, d.TotalPixels)
+	assert.True(t, d.DiffPixels >= 500*375-300*199)
+	assert.Equal(t, image.Rect(0, 0, 500, 375), d.Image.Bounds())
+}
+
+func TestRegression(t *testing.T) {
+	dir := t.TempDir()
+	cat := readFixture(t, "cat.png")
+	changed := modified(t, cat, image.Rect(10, 10, 60, 60))
+
+	c := New(Options{
+		BaselineDir:     filepath.Join(dir, "baseline"),
+		DiffDir:         filepath.Join(dir, "diff"),
+		MaxDiffRatio:    0.001,
+		UpdateBaselines: true,
+	})
+
+	// First run records baselines
+	report := c.CompareAll(map[string][]byte{
+		"outlook-2016":     cat,
+		"iphone-applemail": cat,
+	})
+	assert.True(t, report.Passed())
+	assert.True(t, report.Results[0].NewBaseline)
+	assert.Equal(t, "iphone-applemail", report.Results[0].EmailClient)
+
+	report = c.CompareAll(map[string][]byte{
+		"outlook-2016":     changed,
+		"iphone-applemail": cat,
+	})
+	assert.False(t, report.Passed())
+	assert.Equal(t, 1, len(report.Failed()))
+
+	failed := report.Failed()[0]
+	assert.Equal(t, "outlook-2016", failed.EmailClient)
+	assert.Equal(t, 50*50, failed.DiffPixels)
+	assert.NoError(t, failed.Error)
+	assert.FileExists(t, failed.DiffPath)
+	assert.Contains(t, report.String(), "FAIL outlook-2016: 2500 of 59700 pixels differ")
+}
+
+func TestRegressionIgnoreRegion(t *testing.T) {
+	dir := t.TempDir()
+	cat := readFixture(t, "cat.png")
+
+	c := New(Options{
+		BaselineDir: dir,
+		Ignore: map[string][]image.Rectangle{
+			"gmail": {image.Rect(0, 0, 100, 100)},
+		},
+	})
+	assert.NoError(t, os.WriteFile(c.BaselinePath("gmail"), cat, 0644))
+
+	res := c.Compare("gmail", modified(t, cat, image.Rect(10, 10, 60, 60)))
+	assert.True(t, res.Passed)
+	assert.Equal(t, 0, res.DiffPixels)
+}
+
+func TestRegressionTolerance(t *testing.T) {
+	dir := t.TempDir()
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.RGBA{R: 100, G: 100, B: 100, A: 255}}, image.Point{}, draw.Src)
+	var baseline bytes.Buffer
+	png.Encode(&baseline, img)
+
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.RGBA{R: 104, G: 100, B: 100, A: 255}}, image.Point{}, draw.Src)
+	var actual bytes.Buffer
+	png.Encode(&actual, img)
+
+	strict := New(Options{BaselineDir: dir})
+	assert.NoError(t, os.WriteFile(strict.BaselinePath("gmail"), baseline.Bytes(), 0644))
+	assert.False(t, strict.Compare("gmail", actual.Bytes()).Passed)
+
+	tolerant := New(Options{BaselineDir: dir, Tolerance: 0.02})
+	assert.True(t, tolerant.Compare("gmail", actual.Bytes()).Passed)
+}
+
+func TestRegressionMissingBaseline(t *testing.T) {
+	c := New(Options{BaselineDir: t.TempDir()})
+
+	res := c.Compare("gmail", readFixture(t, "cat.png"))
+	assert.False(t, res.Passed)
+	assert.Error(t, res.Error)
+}
+
+func TestRegressionInvalidPreview(t *testing.T) {
+	c := New(Options{BaselineDir: t.TempDir()})
+
+	res := c.Compare("gmail", []byte("not a png"))
+	assert.False(t, res.Passed)
+	assert.Error(t, res.Error)
+}
@@ -0,0 +1,226 @@
+// Package mailosaurwebhook receives Mailosaur message webhooks, so tests
+// can be pushed new messages instead of polling for them. A Receiver is an
+// http.Handler that verifies each request's signature, decodes the message
+// and passes it to registered handlers and pending waits.
+package mailosaurwebhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mailosaur/mailosaur-go"
+)
+
+// DefaultSignatureHeader and Sign are the defaults for how requests are
+// signed. Check them against the signing settings of your webhook, and set
+// Options.SignatureHeader and Options.Sign to match if they differ.
+const DefaultSignatureHeader = "X-Mailosaur-Signature"
+
+type Options struct {
+	// Secret verifies the signature of each request. Without a secret
+	// every request is refused, unless Insecure is set.
+	Secret string
+	// Insecure accepts unsigned requests when there is no secret. Anyone
+	// who can reach the receiver can then deliver messages to it, so this
+	// is only suitable for local use.
+	Insecure bool
+	// SignatureHeader defaults to DefaultSignatureHeader.
+	SignatureHeader string
+	// Sign returns the expected signature of a request body, defaulting to
+	// the package's Sign function.
+	Sign func(secret string, body []byte) string
+	// MaxBodySize defaults to 10MB.
+	MaxBodySize int64
+	// Recent is how many messages are remembered for waits that start
+	// after the message arrived. It defaults to 100.
+	Recent int
+	// PollInterval is how often Get falls back to searching the API while
+	// it waits for a webhook. It defaults to 5 seconds.
+	PollInterval time.Duration
+}
+
+// Handler is called with each message received.
+type Handler func(message *mailosaur.Message)
+
+type Receiver struct {
+	secret          string
+	insecure        bool
+	signatureHeader string
+	sign            func(secret string, body []byte) string
+	maxBodySize     int64
+	recentSize      int
+	pollInterval    time.Duration
+
+	mu       sync.Mutex
+	handlers []Handler
+	waiters  []*waiter
+	recent   []*mailosaur.Message
+}
+
+type waiter struct {
+	server        string
+	criteria      *mailosaur.SearchCriteria
+	receivedAfter time.Time
+	ch            chan *mailosaur.Message
+}
+
+func (w *waiter) matches(m *mailosaur.Message) bool {
+	return (len(w.server) == 0 || w.server == m.Server) &&
+		!m.Received.Before(w.receivedAfter) &&
+		w.criteria.Matches(m)
+}
+
+func NewReceiver(options *Options) *Receiver {
+	if options == nil {
+		options = &Options{}
+	}
+
+	r := &Receiver{
+		secret:          options.Secret,
+		insecure:        options.Insecure,
+		signatureHeader: options.SignatureHeader,
+		sign:            options.Sign,
+		maxBodySize:     options.MaxBodySize,
+		recentSize:      options.Recent,
+		pollInterval:    options.PollInterval,
+	}
+
+	if len(r.signatureHeader) == 0 {
+		r.signatureHeader = DefaultSignatureHeader
+	}
+	if r.sign == nil {
+		r.sign = Sign
+	}
+	if r.maxBodySize <= 0 {
+		r.maxBodySize = 10 * 1024 * 1024
+	}
+	if r.recentSize <= 0 {
+		r.recentSize = 100
+	}
+	if r.pollInterval <= 0 {
+		r.pollInterval = 5 * time.Second
+	}
+
+	return r
+}
+
+// Sign returns the signature of a webhook body: the base64 encoded
+// HMAC-SHA256 of the body, keyed with the secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Handle registers a handler for every message received. Handlers run on
+// the request goroutine, so slow work should be handed off.
+func (r *Receiver) Handle(handler Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers = append(r.handlers, handler)
+}
+
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, r.maxBodySize))
+	if err != nil {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if !r.verify(req.Header.Get(r.signatureHeader), body) {
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	message := &mailosaur.Message{}
+	if err := json.Unmarshal(body, message); err != nil || len(message.Id) == 0 {
+		http.Error(w, "Invalid message", http.StatusBadRequest)
+		return
+	}
+
+	r.Deliver(message)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (r *Receiver) verify(signature string, body []byte) bool {
+	if len(r.secret) == 0 {
+		return r.insecure
+	}
+
+	if len(signature) == 0 {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(r.sign(r.secret, body)))
+}
+
+// Deliver passes a message to pending waits and handlers, as if it had
+// been received by webhook.
+func (r *Receiver) Deliver(message *mailosaur.Message) {
+	r.mu.Lock()
+
+	r.recent = append(r.recent, message)
+	if len(r.recent) > r.recentSize {
+		r.recent = r.recent[len(r.recent)-r.recentSize:]
+	}
+
+	// Each waiter takes the first matching message
+	pending := r.waiters[:0]
+	for _, w := range r.waiters {
+		if w.matches(message) {
+			w.ch <- message
+			continue
+		}
+		pending = append(pending, w)
+	}
+	r.waiters = pending
+
+	handlers := append([]Handler{}, r.handlers...)
+	r.mu.Unlock()
+
+	for _, h := range handlers {
+		h(message)
+	}
+}
+
+// wait registers a waiter, resolving it straight away with the newest
+// recent message that matches.
+func (r *Receiver) wait(server string, criteria *mailosaur.SearchCriteria, receivedAfter time.Time) *waiter {
+	w := &waiter{server: server, criteria: criteria, receivedAfter: receivedAfter, ch: make(chan *mailosaur.Message, 1)}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := len(r.recent) - 1; i >= 0; i-- {
+		if w.matches(r.recent[i]) {
+			w.ch <- r.recent[i]
+			return w
+		}
+	}
+
+	r.waiters = append(r.waiters, w)
+	return w
+}
+
+func (r *Receiver) cancel(w *waiter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, x := range r.waiters {
+		if x == w {
+			r.waiters = append(r.waiters[:i], r.waiters[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package mailosaurwebhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mailosaur/mailosaur-go"
+	"github.com/mailosaur/mailosaur-go/mailosaurcatcher"
+	"github.com/stretchr/testify/assert"
+)
+
+func post(t *testing.T, url string, signature string, message *mailosaur.Message) int {
+	body, _ := json.Marshal(message)
+	if signature == "sign" {
+		signature = Sign("secret", body)
+	}
+
+	req, _ := http.NewRequest("POST", url, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if len(signature) > 0 {
+		req.Header.Set(DefaultSignatureHeader, signature)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func newMessage(id string, subject string) *mailosaur.Message {
+	return &mailosaur.Message{
+		Id:       id,
+		Server:   "abc123",
+		Subject:  subject,
+		Received: time.Now(),
+		To:       []*mailosaur.MessageAddress{{Email: "jo@abc123.mailosaur.net"}},
+	}
+}
+
+func TestReceiver(t *testing.T) {
+	receiver := NewReceiver(&Options{Secret: "secret"})
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+
+	var handled []string
+	receiver.Handle(func(m *mailosaur.Message) {
+		handled = append(handled, m.Subject)
+	})
+
+	assert.Equal(t, http.StatusOK, post(t, srv.URL, "sign", newMessage("m1", "Welcome")))
+	assert.Equal(t, http.StatusUnauthorized, post(t, srv.URL, "", newMessage("m2", "Unsigned")))
+	assert.Equal(t, http.StatusUnauthorized, post(t, srv.URL, Sign("wrong", []byte("{}")), newMessage("m3", "Wrong secret")))
+	assert.Equal(t, http.StatusUnauthorized, post(t, srv.URL, "not base64!", newMessage("m4", "Malformed")))
+	assert.Equal(t, http.StatusBadRequest, post(t, srv.URL, "sign", &mailosaur.Message{Subject: "No ID"}))
+
+	assert.Equal(t, []string{"Welcome"}, handled)
+
+	resp, _ := http.Get(srv.URL)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestReceiverWithoutSecret(t *testing.T) {
+	// Unsigned requests are refused unless explicitly allowed
+	refusing := httptest.NewServer(NewReceiver(nil))
+	defer refusing.Close()
+	assert.Equal(t, http.StatusUnauthorized, post(t, refusing.URL, "", newMessage("m1", "Welcome")))
+
+	insecure := httptest.NewServer(NewReceiver(&Options{Insecure: true}))
+	defer insecure.Close()
+	assert.Equal(t, http.StatusOK, post(t, insecure.URL, "", newMessage("m1", "Welcome")))
+}
+
+func TestReceiverCustomSignature(t *testing.T) {
+	receiver := NewReceiver(&Options{
+		Secret:          "secret",
+		SignatureHeader: "X-Signature",
+		Sign: func(secret string, body []byte) string {
+			return "sha256=" + Sign(secret, body)
+		},
+	})
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+
+	body, _ := json.Marshal(newMessage("m1", "Welcome"))
+	req, _ := http.NewRequest("POST", srv.URL, bytes.NewReader(body))
+	req.Header.Set("X-Signature", "sha256="+Sign("secret", body))
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The default header and format are not accepted
+	assert.Equal(t, http.StatusUnauthorized, post(t, srv.URL, "sign", newMessage("m2", "Welcome")))
+}
+
+func TestGetFromWebhook(t *testing.T) {
+	receiver := NewReceiver(&Options{Secret: "secret"})
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		post(t, srv.URL, "sign", newMessage("m1", "Welcome"))
+		post(t, srv.URL, "sign", newMessage("m2", "Reset your password"))
+	}()
+
+	message, err := receiver.Get(nil, &mailosaur.MessageSearchParams{Server: "abc123"}, &mailosaur.SearchCriteria{Subject: "reset"})
+	assert.NoError(t, err)
+	assert.Equal(t, "m2", message.Id)
+
+	// Messages that arrived before the wait started are matched, newest first
+	receiver.Deliver(newMessage("m3", "Reset your password"))
+	message, err = receiver.Get(nil, &mailosaur.MessageSearchParams{Server: "abc123"}, &mailosaur.SearchCriteria{Subject: "reset"})
+	assert.NoError(t, err)
+	assert.Equal(t, "m3", message.Id)
+
+	// Unless they are too old or for another server
+	_, err = receiver.Get(nil, &mailosaur.MessageSearchParams{Server: "abc123", ReceivedAfter: time.Now().Add(time.Minute), Timeout: 1}, &mailosaur.SearchCriteria{Subject: "reset"})
+	assert.Equal(t, ErrTimeout, err)
+	_, err = receiver.Get(nil, &mailosaur.MessageSearchParams{Server: "other", Timeout: 1}, &mailosaur.SearchCriteria{})
+	assert.Equal(t, ErrTimeout, err)
+
+	// Nil criteria match any message
+	receiver.Deliver(newMessage("m4", "Welcome"))
+	message, err = receiver.Get(nil, &mailosaur.MessageSearchParams{Server: "abc123"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "m4", message.Id)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		receiver.Deliver(newMessage("m5", "Welcome"))
+	}()
+	message, err = receiver.Get(nil, &mailosaur.MessageSearchParams{Server: "abc123", ReceivedAfter: time.Now().Add(time.Millisecond)}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "m5", message.Id)
+
+	receiver.mu.Lock()
+	assert.Equal(t, 0, len(receiver.waiters))
+	receiver.mu.Unlock()
+}
+
+func TestGetFallsBackToPolling(t *testing.T) {
+	catcher := mailosaurcatcher.New(nil)
+	api := httptest.NewServer(catcher)
+	defer api.Close()
+
+	client := mailosaur.New("key")
+	client.SetBaseUrl(api.URL)
+
+	receiver := NewReceiver(&Options{PollInterval: 50 * time.Millisecond})
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		catcher.Deliver("noreply@example.com", []string{"jo@abc123.mailosaur.net"}, []byte("Subject: Missed webhook\r\n\r\nHello\r\n"))
+	}()
+
+	message, err := receiver.Get(client, &mailosaur.MessageSearchParams{Server: "abc123", Timeout: 5}, &mailosaur.SearchCriteria{SentTo: "jo@abc123.mailosaur.net"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Missed webhook", message.Subject)
+	assert.Equal(t, "Hello", message.Text.Body[:5])
+}
@@ -0,0 +1,88 @@
+package mailosaurwebhook
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mailosaur/mailosaur-go"
+)
+
+var ErrTimeout = errors.New("mailosaurwebhook: no matching message was received in time")
+
+// Get waits for a message matching the criteria, in the same way as
+// MessagesService.Get. It resolves as soon as a matching message arrives
+// by webhook, while also searching the API every PollInterval in case a
+// webhook is missed. With a nil client it relies on webhooks alone.
+//
+// As with MessagesService.Get, the timeout defaults to 10 seconds and only
+// messages received in the last hour are matched. Nil criteria match any
+// message.
+func (r *Receiver) Get(client *mailosaur.MailosaurClient, params *mailosaur.MessageSearchParams, criteria *mailosaur.SearchCriteria) (*mailosaur.Message, error) {
+	if criteria == nil {
+		criteria = &mailosaur.SearchCriteria{}
+	}
+
+	receivedAfter := params.ReceivedAfter
+	if receivedAfter.IsZero() {
+		receivedAfter = time.Now().Add(-time.Hour)
+	}
+
+	timeout := time.Duration(params.Timeout) * time.Second
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	w := r.wait(params.Server, criteria, receivedAfter)
+	defer r.cancel(w)
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	var poll <-chan time.Time
+	if client != nil {
+		ticker := time.NewTicker(r.pollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+
+		// The message may have arrived before the receiver was listening
+		if m, err := r.poll(client, params.Server, criteria, receivedAfter, w); m != nil || err != nil {
+			return m, err
+		}
+	}
+
+	for {
+		select {
+		case m := <-w.ch:
+			return m, nil
+		case <-deadline.C:
+			return nil, ErrTimeout
+		case <-poll:
+			if m, err := r.poll(client, params.Server, criteria, receivedAfter, w); m != nil || err != nil {
+				return m, err
+			}
+		}
+	}
+}
+
+// poll searches the API once, preferring a message that has already
+// arrived by webhook.
+func (r *Receiver) poll(client *mailosaur.MailosaurClient, server string, criteria *mailosaur.SearchCriteria, receivedAfter time.Time, w *waiter) (*mailosaur.Message, error) {
+	select {
+	case m := <-w.ch:
+		return m, nil
+	default:
+	}
+
+	errorOnTimeout := false
+	result, err := client.Messages.Search(&mailosaur.MessageSearchParams{
+		Server:         server,
+		ReceivedAfter:  receivedAfter,
+		ItemsPerPage:   1,
+		ErrorOnTimeout: &errorOnTimeout,
+	}, criteria)
+	if err != nil || len(result.Items) == 0 {
+		return nil, err
+	}
+
+	return client.Messages.GetById(result.Items[0].Id)
+}
@@ -136,6 +136,65 @@ type SearchCriteria struct {
 	Match    string `json:"match"`
 }
 
+// Matches applies the criteria to a message locally, in the same way as the
+// search API: addresses must match exactly, subject and body by substring,
+// all ignoring case. Match "ANY" requires only one criterion to match.
+func (c *SearchCriteria) Matches(m *Message) bool {
+	var results []bool
+
+	var mailFrom string
+	var rcptTo []*MessageAddress
+	if m.Metadata != nil {
+		mailFrom = m.Metadata.MailFrom
+		rcptTo = m.Metadata.RcptTo
+	}
+
+	if len(c.SentFrom) > 0 {
+		results = append(results, hasAddress(m.From, c.SentFrom) || strings.EqualFold(mailFrom, c.SentFrom))
+	}
+	if len(c.SentTo) > 0 {
+		results = append(results, hasAddress(m.To, c.SentTo) || hasAddress(m.Cc, c.SentTo) ||
+			hasAddress(m.Bcc, c.SentTo) || hasAddress(rcptTo, c.SentTo))
+	}
+	if len(c.Subject) > 0 {
+		results = append(results, containsFold(m.Subject, c.Subject))
+	}
+	if len(c.Body) > 0 {
+		found := false
+		for _, content := range []*MessageContent{m.Text, m.Html} {
+			if content != nil && containsFold(content.Body, c.Body) {
+				found = true
+			}
+		}
+		results = append(results, found)
+	}
+
+	matchAny := strings.EqualFold(c.Match, "ANY")
+	for _, result := range results {
+		if result && matchAny {
+			return true
+		}
+		if !result && !matchAny {
+			return false
+		}
+	}
+
+	return !matchAny || len(results) == 0
+}
+
+func hasAddress(addresses []*MessageAddress, email string) bool {
+	for _, a := range addresses {
+		if strings.EqualFold(a.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
 type MessageSearchParams struct {
 	Server         string
 	ReceivedAfter  time.Time
@@ -186,30 +245,42 @@ func (s *MessagesService) Get(params *MessageSearchParams, criteria *SearchCrite
 	params.Page = 0
 	params.ItemsPerPage = 1
 
-	result, err := s.Search(params, criteria)
+	op := &Operation{Name: "messages.get", Target: params.Server, Criteria: criteria, Started: time.Now()}
+
+	result, polls, err := s.search(params, criteria)
+	op.Polls = polls
 	if err != nil {
+		op.Err = err
+		s.client.observe(op)
 		return nil, err
 	}
 
-	return s.GetById(result.Items[0].Id)
+	message, err := s.GetById(result.Items[0].Id)
+	op.Results = 1
+	op.Err = err
+	s.client.observe(op)
+
+	return message, err
 }
 
 func (s *MessagesService) Search(params *MessageSearchParams, criteria *SearchCriteria) (*MessageListResult, error) {
-	pollCount := 0
-	startTime := time.Now()
-
-	u := buildPagePath(
-		"api/messages/search?server="+params.Server,
-		params.Page,
-		params.ItemsPerPage,
-		params.ReceivedAfter,
-		params.Dir,
-	)
+	op := &Operation{Name: "messages.search", Target: params.Server, Criteria: criteria, Started: time.Now()}
 
-	// Default value for Match
-	if len(criteria.Match) == 0 {
-		criteria.Match = "ALL"
+	result, polls, err := s.search(params, criteria)
+	op.Polls = polls
+	op.Err = err
+	if result != nil {
+		op.Results = len(result.Items)
 	}
+	s.client.observe(op)
+
+	return result, err
+}
+
+// search returns the result along with the number of requests made
+func (s *MessagesService) search(params *MessageSearchParams, criteria *SearchCriteria) (*MessageListResult, int, error) {
+	pollCount := 0
+	startTime := time.Now()
 
 	// Default value for ErrorOnTimeout
 	if params.ErrorOnTimeout == nil {
@@ -218,43 +289,24 @@ func (s *MessagesService) Search(params *MessageSearchParams, criteria *SearchCr
 	}
 
 	for {
-		result, delayHeader, err := s.client.executeRequestWithDelayHeader(&MessageListResult{}, "POST", u, criteria, 200)
+		result, delayHeader, err := s.searchOnce(params, criteria)
 
 		if err != nil {
-			return nil, err
+			return nil, pollCount + 1, err
 		}
 
-		if params.Timeout == 0 || len(result.(*MessageListResult).Items) != 0 {
-			return result.(*MessageListResult), nil
+		if params.Timeout == 0 || len(result.Items) != 0 {
+			return result, pollCount + 1, nil
 		}
 
-		delayPattern := "1000"
-		if len(delayHeader) != 0 {
-			delayPattern = delayHeader
-		}
-		delayPatternSplit := strings.Split(delayPattern, ",")
-
-		var delayPatternValues []int
-
-		for _, v := range delayPatternSplit {
-			var n int
-			n, _ = strconv.Atoi(strings.TrimSpace(v))
-			delayPatternValues = append(delayPatternValues, n)
-		}
-
-		var delay int
-		if pollCount >= len(delayPatternValues) {
-			delay = delayPatternValues[len(delayPatternValues)-1] / 1000
-		} else {
-			delay = delayPatternValues[pollCount] / 1000
-		}
+		delay := pollDelay(delayHeader, pollCount) / 1000
 
 		pollCount++
 
 		// Stop if timeout will be exceeded
 		if time.Since(startTime).Seconds()+float64(delay) > float64(params.Timeout) {
 			if *params.ErrorOnTimeout == false {
-				return result.(*MessageListResult), nil
+				return result, pollCount, nil
 			}
 
 			criteriaJson, _ := json.Marshal(criteria)
@@ -262,13 +314,60 @@ func (s *MessagesService) Search(params *MessageSearchParams, criteria *SearchCr
 				Message:   "No matching messages found in time. By default, only messages received in the last hour are checked (use receivedAfter to override this). The search criteria used for this query was [" + string(criteriaJson) + "] which timed out after " + fmt.Sprint(params.Timeout) + "s",
 				ErrorType: "search_timeout",
 			}
-			return nil, err
+			return nil, pollCount, err
 		}
 
 		time.Sleep(time.Duration(delay) * time.Second)
 	}
 }
 
+// searchOnce makes a single search request, returning the result along
+// with the x-ms-delay header that says when to poll again.
+func (s *MessagesService) searchOnce(params *MessageSearchParams, criteria *SearchCriteria) (*MessageListResult, string, error) {
+	u := buildPagePath(
+		"api/messages/search?server="+params.Server,
+		params.Page,
+		params.ItemsPerPage,
+		params.ReceivedAfter,
+		params.Dir,
+	)
+
+	// Default value for Match
+	if len(criteria.Match) == 0 {
+		criteria.Match = "ALL"
+	}
+
+	result, delayHeader, err := s.client.executeRequestWithDelayHeader(&MessageListResult{}, "POST", u, criteria, 200)
+	if err != nil {
+		return nil, "", err
+	}
+	return result.(*MessageListResult), delayHeader, nil
+}
+
+// pollDelay returns the milliseconds to wait before the next poll, from a
+// comma separated x-ms-delay pattern. The last delay repeats once the
+// pattern runs out, and it defaults to a second.
+func pollDelay(delayHeader string, pollCount int) int {
+	delayPattern := "1000"
+	if len(delayHeader) != 0 {
+		delayPattern = delayHeader
+	}
+	delayPatternSplit := strings.Split(delayPattern, ",")
+
+	var delayPatternValues []int
+
+	for _, v := range delayPatternSplit {
+		var n int
+		n, _ = strconv.Atoi(strings.TrimSpace(v))
+		delayPatternValues = append(delayPatternValues, n)
+	}
+
+	if pollCount >= len(delayPatternValues) {
+		return delayPatternValues[len(delayPatternValues)-1]
+	}
+	return delayPatternValues[pollCount]
+}
+
 func (s *MessagesService) GetById(id string) (*Message, error) {
 	result, err := s.client.HttpGet(&Message{}, "api/messages/"+id)
 	return result.(*Message), err
@@ -283,17 +382,32 @@ func (s *MessagesService) DeleteAll(server string) error {
 }
 
 func (s *MessagesService) Create(server string, messageCreateOptions *MessageCreateOptions) (*Message, error) {
+	if messageCreateOptions.Send {
+		return s.send("api/messages?server="+server, messageCreateOptions)
+	}
+
 	result, err := s.client.HttpPost(&Message{}, "api/messages?server="+server, messageCreateOptions)
 	return result.(*Message), err
 }
 
 func (s *MessagesService) Forward(id string, messageForwardOptions *MessageForwardOptions) (*Message, error) {
-	result, err := s.client.HttpPost(&Message{}, "api/messages/"+id+"/forward", messageForwardOptions)
-	return result.(*Message), err
+	return s.send("api/messages/"+id+"/forward", messageForwardOptions)
 }
 
 func (s *MessagesService) Reply(id string, messageReplyOptions *MessageReplyOptions) (*Message, error) {
-	result, err := s.client.HttpPost(&Message{}, "api/messages/"+id+"/reply", messageReplyOptions)
+	return s.send("api/messages/"+id+"/reply", messageReplyOptions)
+}
+
+// send makes a request that sends an email, checking the quota guard first
+func (s *MessagesService) send(path string, body interface{}) (*Message, error) {
+	if err := s.client.CheckQuota(QuotaEmail, 1); err != nil {
+		return nil, err
+	}
+
+	result, err := s.client.HttpPost(&Message{}, path, body)
+	if err == nil {
+		s.client.consumeQuota(QuotaEmail, 1)
+	}
 	return result.(*Message), err
 }
 
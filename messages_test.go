@@ -13,6 +13,10 @@ import (
 )
 
 func init() {
+	if !live {
+		return
+	}
+
 	baseUrl := os.Getenv("MAILOSAUR_BASE_URL")
 	server = os.Getenv("MAILOSAUR_SERVER")
 	verifiedDomain = os.Getenv("MAILOSAUR_VERIFIED_DOMAIN")
@@ -38,6 +42,7 @@ func init() {
 }
 
 func TestMessageList(t *testing.T) {
+	skipUnlessLive(t)
 	result, err := client.Messages.List(&MessageListParams{Server: server})
 	assert.NoError(t, err)
 	assert.Equal(t, 5, len(result.Items))
@@ -50,6 +55,7 @@ func TestMessageList(t *testing.T) {
 }
 
 func TestMessageListReceivedAfter(t *testing.T) {
+	skipUnlessLive(t)
 	pastDate := time.Now().Add(time.Duration(-10) * time.Minute)
 
 	pastEmails, _ := client.Messages.List(&MessageListParams{
@@ -66,6 +72,7 @@ func TestMessageListReceivedAfter(t *testing.T) {
 }
 
 func TestMessageGet(t *testing.T) {
+	skipUnlessLive(t)
 	host := os.Getenv("MAILOSAUR_SMTP_HOST")
 	if len(host) == 0 {
 		host = "mailosaur.net"
@@ -85,6 +92,7 @@ func TestMessageGet(t *testing.T) {
 }
 
 func TestMessageGetById(t *testing.T) {
+	skipUnlessLive(t)
 	emailToRetrieve := emails[0]
 	email, _ := client.Messages.GetById(emailToRetrieve.Id)
 	validateEmail(t, email)
@@ -92,6 +100,7 @@ func TestMessageGetById(t *testing.T) {
 }
 
 func TestMessageGetByIdNotFound(t *testing.T) {
+	skipUnlessLive(t)
 	_, err := client.Messages.GetById("efe907e9-74ed-4113-a3e0-a3d41d914765")
 
 	assert.Error(t, err)
@@ -99,6 +108,7 @@ func TestMessageGetByIdNotFound(t *testing.T) {
 }
 
 func TestSearchNoCriteriaError(t *testing.T) {
+	skipUnlessLive(t)
 	_, err := client.Messages.Search(&MessageSearchParams{Server: server}, &SearchCriteria{})
 
 	assert.Error(t, err)
@@ -106,6 +116,7 @@ func TestSearchNoCriteriaError(t *testing.T) {
 }
 
 func TestSearchTimeoutErrorSuppressed(t *testing.T) {
+	skipUnlessLive(t)
 	f := false
 	result, _ := client.Messages.Search(&MessageSearchParams{
 		Server:         server,
@@ -118,7 +129,32 @@ func TestSearchTimeoutErrorSuppressed(t *testing.T) {
 	assert.Equal(t, 0, len(result.Items))
 }
 
+func TestSearchTimeoutObserved(t *testing.T) {
+	skipUnlessLive(t)
+	var observed *Operation
+	client.SetObserver(ObserverFunc(func(op *Operation) {
+		observed = op
+	}))
+	defer client.SetObserver(nil)
+
+	_, err := client.Messages.Search(&MessageSearchParams{
+		Server:  server,
+		Timeout: 1,
+	}, &SearchCriteria{
+		SentFrom: "neverfound@example.com",
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, "messages.search", observed.Name)
+	assert.Equal(t, server, observed.Target)
+	assert.Equal(t, "neverfound@example.com", observed.Criteria.SentFrom)
+	assert.True(t, observed.TimedOut)
+	assert.True(t, observed.Polls > 0)
+	assert.Equal(t, err, observed.Err)
+}
+
 func TestSearchBySentFrom(t *testing.T) {
+	skipUnlessLive(t)
 	targetEmail := emails[1]
 
 	result, _ := client.Messages.Search(&MessageSearchParams{
@@ -133,6 +169,7 @@ func TestSearchBySentFrom(t *testing.T) {
 }
 
 func TestSearchBySentTo(t *testing.T) {
+	skipUnlessLive(t)
 	targetEmail := emails[1]
 
 	result, _ := client.Messages.Search(&MessageSearchParams{
@@ -147,6 +184,7 @@ func TestSearchBySentTo(t *testing.T) {
 }
 
 func TestSearchByBody(t *testing.T) {
+	skipUnlessLive(t)
 	targetEmail := emails[1]
 	uniqueString := targetEmail.Subject[:8]
 
@@ -162,6 +200,7 @@ func TestSearchByBody(t *testing.T) {
 }
 
 func TestSearchBySubject(t *testing.T) {
+	skipUnlessLive(t)
 	targetEmail := emails[1]
 	uniqueString := targetEmail.Subject[:8]
 
@@ -177,6 +216,7 @@ func TestSearchBySubject(t *testing.T) {
 }
 
 func TestSearchWithMatchAll(t *testing.T) {
+	skipUnlessLive(t)
 	targetEmail := emails[1]
 	uniqueString := targetEmail.Subject[:8]
 
@@ -192,6 +232,7 @@ func TestSearchWithMatchAll(t *testing.T) {
 }
 
 func TestSearchWithMatchAny(t *testing.T) {
+	skipUnlessLive(t)
 	targetEmail := emails[1]
 	uniqueString := targetEmail.Subject[:8]
 
@@ -207,6 +248,7 @@ func TestSearchWithMatchAny(t *testing.T) {
 }
 
 func TestSearchWithSpecialCharacters(t *testing.T) {
+	skipUnlessLive(t)
 	result, _ := client.Messages.Search(&MessageSearchParams{
 		Server: server,
 	}, &SearchCriteria{
@@ -217,6 +259,7 @@ func TestSearchWithSpecialCharacters(t *testing.T) {
 }
 
 func TestSpamAnalysis(t *testing.T) {
+	skipUnlessLive(t)
 	targetId := emails[0].Id
 	result, _ := client.Analysis.Spam(targetId)
 
@@ -227,6 +270,7 @@ func TestSpamAnalysis(t *testing.T) {
 }
 
 func TestDeliverability(t *testing.T) {
+	skipUnlessLive(t)
 	targetId := emails[0].Id
 	result, _ := client.Analysis.Deliverability(targetId)
 
@@ -264,6 +308,7 @@ func TestDeliverability(t *testing.T) {
 }
 
 func TestDeleteMessage(t *testing.T) {
+	skipUnlessLive(t)
 	targetEmailId := emails[4].Id
 
 	err := client.Messages.Delete(targetEmailId)
@@ -277,6 +322,7 @@ func TestDeleteMessage(t *testing.T) {
 }
 
 func TestCreateSendText(t *testing.T) {
+	skipUnlessLive(t)
 	if verifiedDomain == "mailosaur.net" {
 		t.Skip()
 	}
@@ -296,6 +342,7 @@ func TestCreateSendText(t *testing.T) {
 }
 
 func TestCreateSendHtml(t *testing.T) {
+	skipUnlessLive(t)
 	if verifiedDomain == "mailosaur.net" {
 		t.Skip()
 	}
@@ -315,6 +362,7 @@ func TestCreateSendHtml(t *testing.T) {
 }
 
 func TestCreateSendWithCc(t *testing.T) {
+	skipUnlessLive(t)
 	if verifiedDomain == "mailosaur.net" {
 		t.Skip()
 	}
@@ -338,6 +386,7 @@ func TestCreateSendWithCc(t *testing.T) {
 }
 
 func TestCreateSendWithAttachment(t *testing.T) {
+	skipUnlessLive(t)
 
 	result, _ := client.Messages.List(&MessageListParams{Server: server})
 	emails = result.Items
@@ -374,6 +423,7 @@ func TestCreateSendWithAttachment(t *testing.T) {
 }
 
 func TestForwardText(t *testing.T) {
+	skipUnlessLive(t)
 	if verifiedDomain == "mailosaur.net" {
 		t.Skip()
 	}
@@ -392,6 +442,7 @@ func TestForwardText(t *testing.T) {
 }
 
 func TestForwardHtml(t *testing.T) {
+	skipUnlessLive(t)
 	if verifiedDomain == "mailosaur.net" {
 		t.Skip()
 	}
@@ -410,6 +461,7 @@ func TestForwardHtml(t *testing.T) {
 }
 
 func TestForwardWithCc(t *testing.T) {
+	skipUnlessLive(t)
 	if verifiedDomain == "mailosaur.net" {
 		t.Skip()
 	}
@@ -432,6 +484,7 @@ func TestForwardWithCc(t *testing.T) {
 }
 
 func TestReplyText(t *testing.T) {
+	skipUnlessLive(t)
 	if verifiedDomain == "mailosaur.net" {
 		t.Skip()
 	}
@@ -449,6 +502,7 @@ func TestReplyText(t *testing.T) {
 }
 
 func TestReplyHtml(t *testing.T) {
+	skipUnlessLive(t)
 	if verifiedDomain == "mailosaur.net" {
 		t.Skip()
 	}
@@ -466,6 +520,7 @@ func TestReplyHtml(t *testing.T) {
 }
 
 func TestReplyWithCc(t *testing.T) {
+	skipUnlessLive(t)
 	if verifiedDomain == "mailosaur.net" {
 		t.Skip()
 	}
@@ -487,6 +542,7 @@ func TestReplyWithCc(t *testing.T) {
 }
 
 func TestReplyWithAttachment(t *testing.T) {
+	skipUnlessLive(t)
 	if verifiedDomain == "mailosaur.net" {
 		t.Skip()
 	}
@@ -615,3 +671,22 @@ func validateAttachmentMetadata(t *testing.T, email *Message) {
 	assert.Equal(t, "dog.png", file2.FileName)
 	assert.Equal(t, "image/png", file2.ContentType)
 }
+
+func TestSearchCriteriaMatches(t *testing.T) {
+	m := &Message{
+		From:     []*MessageAddress{{Email: "noreply@example.com"}},
+		To:       []*MessageAddress{{Email: "jo@abc123.mailosaur.net"}},
+		Subject:  "Reset your password",
+		Text:     &MessageContent{Body: "Your code is 123456"},
+		Metadata: &Metadata{MailFrom: "bounce@example.com", RcptTo: []*MessageAddress{{Email: "bcc@abc123.mailosaur.net"}}},
+	}
+
+	assert.True(t, (&SearchCriteria{}).Matches(m))
+	assert.True(t, (&SearchCriteria{SentTo: "JO@abc123.mailosaur.net"}).Matches(m))
+	assert.True(t, (&SearchCriteria{SentTo: "bcc@abc123.mailosaur.net"}).Matches(m))
+	assert.True(t, (&SearchCriteria{SentFrom: "bounce@example.com", Subject: "reset"}).Matches(m))
+	assert.False(t, (&SearchCriteria{SentTo: "jo@abc123", Subject: "reset"}).Matches(m))
+	assert.True(t, (&SearchCriteria{SentTo: "jo@abc123", Body: "123456", Match: "ANY"}).Matches(m))
+	assert.False(t, (&SearchCriteria{Subject: "welcome", Body: "welcome", Match: "ANY"}).Matches(m))
+	assert.False(t, (&SearchCriteria{Body: "code"}).Matches(&Message{}))
+}
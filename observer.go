@@ -0,0 +1,54 @@
+package mailosaur
+
+import (
+	"time"
+)
+
+// Operation describes a single call made through the client that may poll
+// or wait, such as a message search or a preview download.
+type Operation struct {
+	Name     string
+	Target   string
+	Criteria *SearchCriteria
+	Started  time.Time
+	Duration time.Duration
+	Polls    int
+	Results  int
+	TimedOut bool
+	Err      error
+}
+
+type Observer interface {
+	Observe(op *Operation)
+}
+
+type ObserverFunc func(op *Operation)
+
+func (f ObserverFunc) Observe(op *Operation) {
+	f(op)
+}
+
+// SetObserver registers an observer that is notified after each message
+// search or wait, preview download and analysis call. Pass nil to remove it.
+func (c *MailosaurClient) SetObserver(observer Observer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.observer = observer
+}
+
+func (c *MailosaurClient) observe(op *Operation) {
+	c.mu.RLock()
+	observer := c.observer
+	c.mu.RUnlock()
+
+	if observer == nil {
+		return
+	}
+
+	op.Duration = time.Since(op.Started)
+	if e, ok := op.Err.(*mailosaurError); ok {
+		op.TimedOut = e.ErrorType == "search_timeout" || e.ErrorType == "preview_timeout"
+	}
+
+	observer.Observe(op)
+}
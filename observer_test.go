@@ -0,0 +1,46 @@
+package mailosaur
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserverChangedWhileInFlight(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(&SpamAnalysisResult{})
+	}))
+
+	var observed int32
+	observer := ObserverFunc(func(op *Operation) {
+		atomic.AddInt32(&observed, 1)
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := client.Analysis.Spam("m1")
+			assert.NoError(t, err)
+		}()
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				client.SetObserver(nil)
+			} else {
+				client.SetObserver(observer)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	client.SetObserver(observer)
+	before := atomic.LoadInt32(&observed)
+	client.Analysis.Spam("m1")
+	assert.Equal(t, before+1, atomic.LoadInt32(&observed))
+}
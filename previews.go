@@ -1,5 +1,16 @@
 package mailosaur
 
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
 type PreviewsService struct {
 	client *MailosaurClient
 }
@@ -13,7 +24,163 @@ type EmailClientListResult struct {
 	Items []*EmailClient `json:"items"`
 }
 
+type PreviewBatchOptions struct {
+	// EmailClients lists the email client names to generate previews for.
+	// When empty, every client returned by ListEmailClients that matches
+	// Filter is used.
+	EmailClients []string
+	Filter       func(*EmailClient) bool
+
+	// Concurrency is the number of previews downloaded at once, defaulting to 4.
+	Concurrency int
+
+	// Timeout is the number of seconds to wait for each preview, defaulting to 120.
+	Timeout int
+
+	// Dir, when set, is where previews are written as <emailClient>.png
+	// instead of being held in memory.
+	Dir string
+}
+
+type PreviewBatchResult struct {
+	Previews map[string][]byte
+	Files    map[string]string
+	Errors   map[string]error
+}
+
+// Err combines the per-client errors into one, or returns nil if every
+// preview was downloaded.
+func (r *PreviewBatchResult) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(r.Errors))
+	for name := range r.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	messages := make([]string, 0, len(names))
+	for _, name := range names {
+		messages = append(messages, fmt.Sprintf("%s: %s", name, r.Errors[name]))
+	}
+
+	return errors.New("Failed to download " + fmt.Sprint(len(names)) + " preview(s): " + strings.Join(messages, "; "))
+}
+
 func (s *PreviewsService) ListEmailClients() (*EmailClientListResult, error) {
 	result, err := s.client.HttpGet(&EmailClientListResult{}, "api/screenshots/clients")
 	return result.(*EmailClientListResult), err
 }
+
+// GenerateAndDownload generates previews of a message and downloads them
+// concurrently. Only failures to list clients or request the previews are
+// returned as an error; failures for individual clients are collected in
+// the result.
+func (s *PreviewsService) GenerateAndDownload(messageId string, options *PreviewBatchOptions) (*PreviewBatchResult, error) {
+	if options == nil {
+		options = &PreviewBatchOptions{}
+	}
+
+	emailClients := options.EmailClients
+	if len(emailClients) == 0 {
+		list, err := s.ListEmailClients()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range list.Items {
+			if options.Filter == nil || options.Filter(c) {
+				emailClients = append(emailClients, c.Name)
+			}
+		}
+	}
+
+	result := &PreviewBatchResult{
+		Previews: map[string][]byte{},
+		Files:    map[string]string{},
+		Errors:   map[string]error{},
+	}
+
+	if len(emailClients) == 0 {
+		return result, nil
+	}
+
+	if len(options.Dir) > 0 {
+		if err := os.MkdirAll(options.Dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	generated, err := s.client.Messages.GeneratePreviews(messageId, &PreviewRequestOptions{EmailClients: emailClients})
+	if err != nil {
+		return nil, err
+	}
+
+	pending := map[string]bool{}
+	for _, name := range emailClients {
+		pending[name] = true
+	}
+
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = 120
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan *Preview)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for preview := range jobs {
+				data, err := s.client.Files.getPreview(preview.Id, timeout)
+
+				var path string
+				if err == nil && len(options.Dir) > 0 {
+					path = filepath.Join(options.Dir, previewFileName(preview.EmailClient))
+					err = os.WriteFile(path, data, 0644)
+				}
+
+				mu.Lock()
+				if err != nil {
+					result.Errors[preview.EmailClient] = err
+				} else if len(path) > 0 {
+					result.Files[preview.EmailClient] = path
+				} else {
+					result.Previews[preview.EmailClient] = data
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, preview := range generated.Items {
+		delete(pending, preview.EmailClient)
+		jobs <- preview
+	}
+	close(jobs)
+	wg.Wait()
+
+	for name := range pending {
+		result.Errors[name] = &mailosaurError{
+			Message:   "No preview was generated for email client [" + name + "].",
+			ErrorType: "preview_not_generated",
+		}
+	}
+
+	return result, nil
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func previewFileName(emailClient string) string {
+	return unsafeFileNameChars.ReplaceAllString(emailClient, "_") + ".png"
+}
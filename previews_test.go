@@ -4,12 +4,17 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func init() {
+	if !live {
+		return
+	}
+
 	baseUrl := os.Getenv("MAILOSAUR_BASE_URL")
 	server = os.Getenv("MAILOSAUR_SERVER")
 
@@ -26,6 +31,7 @@ func init() {
 }
 
 func TestListEmailClients(t *testing.T) {
+	skipUnlessLive(t)
 	result, err := client.Previews.ListEmailClients()
 	assert.NoError(t, err)
 
@@ -33,6 +39,7 @@ func TestListEmailClients(t *testing.T) {
 }
 
 func TestGenerateEmailPreviews(t *testing.T) {
+	skipUnlessLive(t)
 	randomString := getRandomString()
 	host := os.Getenv("MAILOSAUR_SMTP_HOST")
 	if len(host) == 0 {
@@ -58,3 +65,47 @@ func TestGenerateEmailPreviews(t *testing.T) {
 
 	assert.True(t, len(bytes) > 1)
 }
+
+func TestGenerateAndDownloadPreviews(t *testing.T) {
+	skipUnlessLive(t)
+	randomString := getRandomString()
+	host := os.Getenv("MAILOSAUR_SMTP_HOST")
+	if len(host) == 0 {
+		host = "mailosaur.net"
+	}
+
+	testEmailAddress := fmt.Sprintf("%s@%s.%s", randomString, server, host)
+
+	sendEmail(client, server, testEmailAddress)
+
+	email, _ := client.Messages.Get(&MessageSearchParams{
+		Server: server,
+	}, &SearchCriteria{
+		SentTo: testEmailAddress,
+	})
+
+	dir := t.TempDir()
+	emailClient := "iphone-16plus-applemail-lightmode-portrait"
+
+	result, err := client.Previews.GenerateAndDownload(email.Id, &PreviewBatchOptions{
+		EmailClients: []string{emailClient},
+		Dir:          dir,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, result.Err())
+
+	path := result.Files[emailClient]
+	assert.Equal(t, filepath.Join(dir, emailClient+".png"), path)
+
+	bytes, _ := os.ReadFile(path)
+	assert.True(t, len(bytes) > 1)
+}
+
+func TestPreviewBatchResultErr(t *testing.T) {
+	result := &PreviewBatchResult{Errors: map[string]error{}}
+	assert.NoError(t, result.Err())
+
+	result.Errors["outlook"] = &mailosaurError{Message: "gone"}
+	result.Errors["gmail"] = &mailosaurError{Message: "timeout"}
+	assert.EqualError(t, result.Err(), "Failed to download 2 preview(s): gmail: timeout; outlook: gone")
+}
@@ -0,0 +1,200 @@
+package mailosaur
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	QuotaServers = "servers"
+	QuotaUsers   = "users"
+	QuotaEmail   = "email"
+	QuotaSms     = "sms"
+)
+
+type QuotaOptions struct {
+	// Headroom is the percentage of each limit held in reserve. With a
+	// headroom of 10, operations are refused once they would take usage
+	// past 90% of the limit.
+	Headroom float64
+	// TTL is how long account limits are cached, defaulting to 5 minutes.
+	// A failure to fetch them is also remembered for the TTL, during which
+	// every check passes.
+	TTL time.Duration
+	// WarnOnly reports operations that would exceed the headroom through
+	// Warn, but still allows them.
+	WarnOnly bool
+	// Warn is passed a *QuotaError for each operation allowed by WarnOnly,
+	// and the error when account limits cannot be fetched. It defaults to
+	// writing to the standard logger.
+	Warn func(err error)
+}
+
+// QuotaError is returned, before any request is made, when an operation
+// would take usage of a resource past the allowance set by the quota guard.
+type QuotaError struct {
+	Resource  string
+	Current   int
+	Requested int
+	Limit     int
+	// Allowed is the limit less the configured headroom
+	Allowed int
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("Quota guard refused %d %s: usage is %d of %d, and the configured headroom allows %d.", e.Requested, e.Resource, e.Current, e.Limit, e.Allowed)
+}
+
+type quotaGuard struct {
+	options QuotaOptions
+	usage   *UsageService
+
+	mu        sync.Mutex
+	limits    map[string]*UsageAccountLimit
+	fetchedAt time.Time
+	failedAt  time.Time
+}
+
+// SetQuotaGuard makes the client check account limits before creating
+// servers, waiting for SMS messages and sending email. Limits are fetched
+// on the first check and cached for the TTL. Pass nil to remove the guard.
+func (c *MailosaurClient) SetQuotaGuard(options *QuotaOptions) {
+	var guard *quotaGuard
+	if options != nil {
+		o := *options
+		if o.TTL == 0 {
+			o.TTL = 5 * time.Minute
+		}
+		if o.Warn == nil {
+			o.Warn = func(err error) {
+				log.Printf("mailosaur: %s", err)
+			}
+		}
+		guard = &quotaGuard{options: o, usage: c.Usage}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.quota = guard
+}
+
+func (c *MailosaurClient) loadQuota() *quotaGuard {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.quota
+}
+
+// CheckQuota returns a *QuotaError if count more of the resource would
+// exceed the allowance of the quota guard. Use it before bulk operations
+// to fail the whole run up front. It always passes when no guard is set.
+func (c *MailosaurClient) CheckQuota(resource string, count int) error {
+	quota := c.loadQuota()
+	if quota == nil {
+		return nil
+	}
+	return quota.check(resource, count)
+}
+
+// consumeQuota records usage made by the client, so that checks remain
+// accurate until the cached limits expire.
+func (c *MailosaurClient) consumeQuota(resource string, count int) {
+	quota := c.loadQuota()
+	if quota == nil {
+		return
+	}
+	quota.consume(resource, count)
+}
+
+func (g *quotaGuard) check(resource string, count int) error {
+	if err := g.refresh(); err != nil {
+		// Failing open, as the service still enforces its own limits
+		g.options.Warn(fmt.Errorf("quota guard could not fetch usage limits: %s", err))
+		return nil
+	}
+
+	g.mu.Lock()
+	err := g.exceeds(resource, count)
+	g.mu.Unlock()
+
+	if err == nil {
+		return nil
+	}
+
+	// Warn is called without the lock held, as it may use the client
+	if g.options.WarnOnly {
+		g.options.Warn(err)
+		return nil
+	}
+
+	return err
+}
+
+// refresh fetches the account limits once the cached copy has expired. The
+// lock is not held during the request, so that other checks are not held
+// up behind it. After a failure it backs off for the TTL, with checks
+// passing in the meantime, rather than adding a request to every operation
+// while the API is unavailable.
+func (g *quotaGuard) refresh() error {
+	g.mu.Lock()
+	fresh := g.limits != nil && time.Since(g.fetchedAt) <= g.options.TTL
+	backingOff := time.Since(g.failedAt) <= g.options.TTL
+	g.mu.Unlock()
+
+	if fresh || backingOff {
+		return nil
+	}
+
+	limits, err := g.usage.Limits()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err != nil {
+		// Stale limits are no longer trusted
+		g.limits = nil
+		g.failedAt = time.Now()
+		return err
+	}
+
+	g.limits = map[string]*UsageAccountLimit{
+		QuotaServers: limits.Servers,
+		QuotaUsers:   limits.Users,
+		QuotaEmail:   limits.Email,
+		QuotaSms:     limits.Sms,
+	}
+	g.fetchedAt = time.Now()
+	return nil
+}
+
+// exceeds returns a *QuotaError if count more of the resource would take
+// usage past the allowance. It must be called with the lock held.
+func (g *quotaGuard) exceeds(resource string, count int) *QuotaError {
+	limit := g.limits[resource]
+	if limit == nil || limit.Limit <= 0 {
+		return nil
+	}
+
+	allowed := int(float64(limit.Limit) * (100 - g.options.Headroom) / 100)
+	if limit.Current+count <= allowed {
+		return nil
+	}
+
+	return &QuotaError{
+		Resource:  resource,
+		Current:   limit.Current,
+		Requested: count,
+		Limit:     limit.Limit,
+		Allowed:   allowed,
+	}
+}
+
+func (g *quotaGuard) consume(resource string, count int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if limit := g.limits[resource]; limit != nil {
+		limit.Current += count
+	}
+}
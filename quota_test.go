@@ -0,0 +1,147 @@
+package mailosaur
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func quotaHandler(limitsRequests *int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/usage/limits":
+			atomic.AddInt32(limitsRequests, 1)
+			w.Write([]byte(`{"servers":{"limit":10,"current":8},"users":{"limit":5,"current":1},"email":{"limit":1000,"current":850},"sms":{"limit":0,"current":0}}`))
+		case "/api/servers":
+			w.Write([]byte(`{"id":"abcd1234","name":"created"}`))
+		default:
+			w.Write([]byte(`{"id":"m1"}`))
+		}
+	})
+}
+
+func TestQuotaGuardRefuses(t *testing.T) {
+	var limitsRequests int32
+	client := newTestClient(t, quotaHandler(&limitsRequests))
+	client.SetQuotaGuard(&QuotaOptions{Headroom: 10})
+
+	// 9 of 10 servers allowed, 8 in use
+	_, err := client.Servers.Create(ServerCreateOptions{Name: "first"})
+	assert.NoError(t, err)
+
+	server, err := client.Servers.Create(ServerCreateOptions{Name: "second"})
+	assert.Nil(t, server)
+	quotaErr, ok := err.(*QuotaError)
+	assert.True(t, ok)
+	assert.Equal(t, QuotaServers, quotaErr.Resource)
+	assert.Equal(t, 9, quotaErr.Current)
+	assert.Equal(t, 9, quotaErr.Allowed)
+	assert.Equal(t, 10, quotaErr.Limit)
+	assert.Equal(t, "Quota guard refused 1 servers: usage is 9 of 10, and the configured headroom allows 9.", err.Error())
+
+	// Limits were fetched once and cached
+	assert.Equal(t, int32(1), limitsRequests)
+
+	assert.NoError(t, client.CheckQuota(QuotaEmail, 50))
+	assert.Error(t, client.CheckQuota(QuotaEmail, 51))
+
+	// No limit is applied to resources without one
+	assert.NoError(t, client.CheckQuota(QuotaSms, 1000))
+}
+
+func TestQuotaGuardSend(t *testing.T) {
+	var limitsRequests int32
+	client := newTestClient(t, quotaHandler(&limitsRequests))
+	client.SetQuotaGuard(&QuotaOptions{Headroom: 15})
+
+	_, err := client.Messages.Forward("m1", &MessageForwardOptions{To: "someone@example.com"})
+	assert.IsType(t, &QuotaError{}, err)
+
+	// Drafts are not sent, so are not checked
+	_, err = client.Messages.Create("abcd1234", &MessageCreateOptions{Subject: "Draft"})
+	assert.NoError(t, err)
+}
+
+func TestQuotaGuardWarnOnly(t *testing.T) {
+	var limitsRequests int32
+	client := newTestClient(t, quotaHandler(&limitsRequests))
+
+	var warnings []*QuotaError
+	client.SetQuotaGuard(&QuotaOptions{Headroom: 50, WarnOnly: true, Warn: func(err error) {
+		warnings = append(warnings, err.(*QuotaError))
+	}})
+
+	_, err := client.Servers.Create(ServerCreateOptions{Name: "first"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(warnings))
+	assert.Equal(t, 5, warnings[0].Allowed)
+}
+
+func TestQuotaGuardWarnCanUseClient(t *testing.T) {
+	var limitsRequests int32
+	client := newTestClient(t, quotaHandler(&limitsRequests))
+
+	var warnings int
+	client.SetQuotaGuard(&QuotaOptions{Headroom: 50, WarnOnly: true, Warn: func(err error) {
+		warnings++
+		client.CheckQuota(QuotaUsers, 1)
+	}})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Servers.Create(ServerCreateOptions{Name: "first"})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+		assert.Equal(t, 1, warnings)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Warn deadlocked calling back into the client")
+	}
+}
+
+func TestQuotaGuardTTL(t *testing.T) {
+	var limitsRequests int32
+	client := newTestClient(t, quotaHandler(&limitsRequests))
+	client.SetQuotaGuard(&QuotaOptions{TTL: time.Nanosecond})
+
+	assert.NoError(t, client.CheckQuota(QuotaUsers, 1))
+	time.Sleep(time.Millisecond)
+	assert.NoError(t, client.CheckQuota(QuotaUsers, 1))
+	assert.Equal(t, int32(2), limitsRequests)
+
+	client.SetQuotaGuard(nil)
+	assert.NoError(t, client.CheckQuota(QuotaServers, 100))
+	assert.Equal(t, int32(2), limitsRequests)
+}
+
+func TestQuotaGuardFailsOpen(t *testing.T) {
+	var limitsRequests int32
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&limitsRequests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	var warnings []error
+	client.SetQuotaGuard(&QuotaOptions{TTL: 100 * time.Millisecond, Warn: func(err error) {
+		warnings = append(warnings, err)
+	}})
+
+	// The failure is reported once, then remembered for the TTL
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, client.CheckQuota(QuotaServers, 100))
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&limitsRequests))
+	assert.Equal(t, 1, len(warnings))
+	assert.Contains(t, warnings[0].Error(), "quota guard could not fetch usage limits")
+
+	time.Sleep(150 * time.Millisecond)
+	assert.NoError(t, client.CheckQuota(QuotaServers, 100))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&limitsRequests))
+	assert.Equal(t, 2, len(warnings))
+}
@@ -0,0 +1,185 @@
+package mailosaur
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RateLimitPolling  = "polling"
+	RateLimitMutating = "mutating"
+)
+
+// RateLimit is a token bucket allowing Rate requests per second on
+// average, with bursts of up to Burst requests. A zero Rate is unlimited.
+type RateLimit struct {
+	Rate float64
+	// Burst defaults to 1.
+	Burst int
+}
+
+type RateLimitOptions struct {
+	// Polling limits requests that read, including every poll made while
+	// waiting in Messages.Get, Search, WaitForSms and GetPreview.
+	Polling RateLimit
+	// Mutating limits requests that create, change or delete.
+	Mutating RateLimit
+	// FailFast returns a *RateLimitError instead of waiting when the budget
+	// is exhausted.
+	FailFast bool
+	// MaxWait returns a *RateLimitError instead of waiting longer than this
+	// for a request. Zero waits as long as needed.
+	MaxWait time.Duration
+}
+
+// RateLimitError is returned, before any request is made, when the rate
+// limiter is set to fail rather than wait.
+type RateLimitError struct {
+	Budget     string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("Client rate limit for %s requests exceeded, retry after %s.", e.Budget, e.RetryAfter)
+}
+
+type RateLimitBudgetStats struct {
+	Requests int64
+	// Delayed is the number of requests that waited for the budget
+	Delayed  int64
+	WaitTime time.Duration
+	Rejected int64
+}
+
+type RateLimitStats struct {
+	Polling  RateLimitBudgetStats
+	Mutating RateLimitBudgetStats
+}
+
+type rateLimiter struct {
+	options  RateLimitOptions
+	polling  *tokenBucket
+	mutating *tokenBucket
+}
+
+type tokenBucket struct {
+	name  string
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	stats  RateLimitBudgetStats
+}
+
+// SetRateLimit limits the rate of requests made by every service of the
+// client, across all goroutines. Pass nil to remove the limit.
+func (c *MailosaurClient) SetRateLimit(options *RateLimitOptions) {
+	var limiter *rateLimiter
+	if options != nil {
+		limiter = &rateLimiter{
+			options:  *options,
+			polling:  newTokenBucket(RateLimitPolling, options.Polling),
+			mutating: newTokenBucket(RateLimitMutating, options.Mutating),
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limiter = limiter
+}
+
+func (c *MailosaurClient) loadLimiter() *rateLimiter {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.limiter
+}
+
+// RateLimitStats returns the number of requests made, delayed and rejected
+// by the rate limiter so far.
+func (c *MailosaurClient) RateLimitStats() RateLimitStats {
+	limiter := c.loadLimiter()
+	if limiter == nil {
+		return RateLimitStats{}
+	}
+	return RateLimitStats{
+		Polling:  limiter.polling.snapshot(),
+		Mutating: limiter.mutating.snapshot(),
+	}
+}
+
+// wait blocks until the request is within budget, or returns a
+// *RateLimitError if the options say not to wait.
+func (l *rateLimiter) wait(method string, path string) error {
+	bucket := l.mutating
+	if method == "GET" || isSearchPath(path) {
+		bucket = l.polling
+	}
+
+	delay, err := bucket.reserve(l.options.FailFast, l.options.MaxWait)
+	if err != nil {
+		return err
+	}
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	return nil
+}
+
+// isSearchPath reports whether a POST is a search, which polls rather than
+// changes anything.
+func isSearchPath(path string) bool {
+	return strings.HasPrefix(path, "api/messages/search")
+}
+
+func newTokenBucket(name string, limit RateLimit) *tokenBucket {
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{name: name, rate: limit.Rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// reserve takes a token, returning how long to wait until it is available.
+// Tokens can be taken ahead of time, so that concurrent callers queue up
+// in order rather than all waking at once.
+func (b *tokenBucket) reserve(failFast bool, maxWait time.Duration) (time.Duration, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stats.Requests++
+	if b.rate <= 0 {
+		return 0, nil
+	}
+
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, nil
+	}
+
+	delay := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	if failFast || (maxWait > 0 && delay > maxWait) {
+		b.stats.Rejected++
+		return 0, &RateLimitError{Budget: b.name, RetryAfter: delay}
+	}
+
+	b.tokens--
+	b.stats.Delayed++
+	b.stats.WaitTime += delay
+	return delay, nil
+}
+
+func (b *tokenBucket) snapshot() RateLimitBudgetStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
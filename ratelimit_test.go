@@ -0,0 +1,157 @@
+package mailosaur
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func rateLimitHandler(requests *int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		switch {
+		case r.Method == "DELETE":
+			w.WriteHeader(204)
+		case r.URL.Path == "/api/messages/search":
+			json.NewEncoder(w).Encode(&MessageListResult{Items: []*MessageSummary{}})
+		default:
+			json.NewEncoder(w).Encode(&ServerListResult{})
+		}
+	})
+}
+
+func TestRateLimitBlocks(t *testing.T) {
+	var requests int32
+	client := newTestClient(t, rateLimitHandler(&requests))
+	client.SetRateLimit(&RateLimitOptions{Polling: RateLimit{Rate: 20, Burst: 2}})
+
+	started := time.Now()
+	for i := 0; i < 5; i++ {
+		_, err := client.Servers.List()
+		assert.NoError(t, err)
+	}
+
+	// Two requests are allowed straight away, then one every 50ms
+	assert.True(t, time.Since(started) >= 140*time.Millisecond)
+	assert.Equal(t, int32(5), atomic.LoadInt32(&requests))
+
+	stats := client.RateLimitStats()
+	assert.Equal(t, int64(5), stats.Polling.Requests)
+	assert.Equal(t, int64(3), stats.Polling.Delayed)
+	assert.True(t, stats.Polling.WaitTime > 0)
+	assert.Equal(t, RateLimitBudgetStats{}, stats.Mutating)
+}
+
+func TestRateLimitFailFast(t *testing.T) {
+	var requests int32
+	client := newTestClient(t, rateLimitHandler(&requests))
+	client.SetRateLimit(&RateLimitOptions{Polling: RateLimit{Rate: 1}, Mutating: RateLimit{Rate: 1}, FailFast: true})
+
+	_, err := client.Servers.List()
+	assert.NoError(t, err)
+
+	_, err = client.Messages.Search(&MessageSearchParams{Server: "s1"}, &SearchCriteria{})
+	assert.IsType(t, &RateLimitError{}, err)
+	assert.Equal(t, RateLimitPolling, err.(*RateLimitError).Budget)
+	assert.True(t, err.(*RateLimitError).RetryAfter > 900*time.Millisecond)
+
+	// Mutating calls have a separate budget
+	assert.NoError(t, client.Servers.Delete("s1"))
+	err = client.Servers.Delete("s1")
+	assert.Equal(t, RateLimitMutating, err.(*RateLimitError).Budget)
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	assert.Equal(t, int64(1), client.RateLimitStats().Polling.Rejected)
+	assert.Equal(t, int64(1), client.RateLimitStats().Mutating.Rejected)
+}
+
+func TestRateLimitMaxWait(t *testing.T) {
+	var requests int32
+	client := newTestClient(t, rateLimitHandler(&requests))
+	client.SetRateLimit(&RateLimitOptions{Polling: RateLimit{Rate: 10}, MaxWait: 250 * time.Millisecond})
+
+	// Concurrent requests queue up 100ms apart, so the fourth would have to
+	// wait 300ms
+	var wg sync.WaitGroup
+	var rejected int32
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Servers.List(); err != nil {
+				assert.IsType(t, &RateLimitError{}, err)
+				atomic.AddInt32(&rejected, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), rejected)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+	assert.Equal(t, int64(1), client.RateLimitStats().Polling.Rejected)
+}
+
+func TestRateLimitConcurrent(t *testing.T) {
+	var requests int32
+	client := newTestClient(t, rateLimitHandler(&requests))
+	client.SetRateLimit(&RateLimitOptions{Polling: RateLimit{Rate: 50}})
+
+	started := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.Servers.List()
+		}()
+	}
+	wg.Wait()
+
+	// The budget is shared, so ten requests at 50 per second take 180ms
+	assert.True(t, time.Since(started) >= 170*time.Millisecond)
+	assert.Equal(t, int32(10), atomic.LoadInt32(&requests))
+	assert.Equal(t, int64(10), client.RateLimitStats().Polling.Requests)
+}
+
+func TestRateLimitRemoved(t *testing.T) {
+	client := newTestClient(t, rateLimitHandler(new(int32)))
+	client.SetRateLimit(&RateLimitOptions{Polling: RateLimit{Rate: 1}, FailFast: true})
+	client.SetRateLimit(nil)
+
+	for i := 0; i < 3; i++ {
+		_, err := client.Servers.List()
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, RateLimitStats{}, client.RateLimitStats())
+}
+
+func TestRateLimitChangedWhileInFlight(t *testing.T) {
+	var requests int32
+	client := newTestClient(t, rateLimitHandler(&requests))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			client.Servers.List()
+		}()
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				client.SetRateLimit(nil)
+			} else {
+				client.SetRateLimit(&RateLimitOptions{Polling: RateLimit{Rate: 1000}})
+			}
+			client.RateLimitStats()
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(10), atomic.LoadInt32(&requests))
+}
@@ -1,9 +1,10 @@
 package mailosaur
 
 import (
+	"encoding/json"
 	"fmt"
 	"math/rand"
-	"os"
+	"reflect"
 )
 
 type ServersService struct {
@@ -11,9 +12,117 @@ type ServersService struct {
 }
 
 type Server struct {
-	Id       string `json:"id"`
-	Name     string `json:"name"`
-	Messages int    `json:"messages"`
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// Users are the IDs of the account users who can access the server. An
+	// empty list gives access to every user on the account.
+	Users    []string `json:"users"`
+	Messages int      `json:"messages"`
+	// RetentionDays is how long messages are kept before they are deleted.
+	// Zero uses the account default.
+	RetentionDays   int               `json:"retentionDays,omitempty"`
+	ForwardingRules []*ForwardingRule `json:"forwardingRules,omitempty"`
+	// Smtp and Pop3 are read only, and are ignored by Update.
+	Smtp *ServerConnection `json:"smtp,omitempty"`
+	Pop3 *ServerConnection `json:"pop3,omitempty"`
+
+	// original holds the server as it was received, so that Update can
+	// send only the fields that have changed
+	original *Server
+}
+
+// ForwardingRule automatically forwards messages that match it. Field is
+// one of "from", "to" or "subject", and Operator is one of "endsWith",
+// "startsWith", "contains" or "equals".
+type ForwardingRule struct {
+	Id        string `json:"id,omitempty"`
+	Field     string `json:"field"`
+	Operator  string `json:"operator"`
+	Value     string `json:"value"`
+	ForwardTo string `json:"forwardTo"`
+}
+
+type ServerConnection struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	// Password may be empty in list results, use GetPassword instead.
+	Password string `json:"password,omitempty"`
+}
+
+func (s *Server) UnmarshalJSON(data []byte) error {
+	type plain Server
+	if err := json.Unmarshal(data, (*plain)(s)); err != nil {
+		return err
+	}
+
+	// Decode a second time for a deep copy of the received values
+	original := &plain{}
+	if err := json.Unmarshal(data, original); err != nil {
+		return err
+	}
+	s.original = (*Server)(original)
+	return nil
+}
+
+// ServerUpdateOptions changes only the fields that are set. Users,
+// RetentionDays and ForwardingRules are pointers so that they can be reset
+// by setting an empty list or zero.
+type ServerUpdateOptions struct {
+	Name            string             `json:"name,omitempty"`
+	Users           *[]string          `json:"users,omitempty"`
+	RetentionDays   *int               `json:"retentionDays,omitempty"`
+	ForwardingRules *[]*ForwardingRule `json:"forwardingRules,omitempty"`
+}
+
+// Changes returns the updates needed to turn the server, as it was
+// received from the API, into its current state. For a server that was
+// not received from the API, every field that is set is included.
+func (s *Server) Changes() ServerUpdateOptions {
+	options := ServerUpdateOptions{}
+	original := s.original
+	if original == nil {
+		original = &Server{}
+	}
+
+	if s.Name != original.Name {
+		options.Name = s.Name
+	}
+
+	if !equalStrings(s.Users, original.Users) {
+		users := s.Users
+		if users == nil {
+			users = []string{}
+		}
+		options.Users = &users
+	}
+
+	if s.RetentionDays != original.RetentionDays {
+		retentionDays := s.RetentionDays
+		options.RetentionDays = &retentionDays
+	}
+
+	if (len(s.ForwardingRules) > 0 || len(original.ForwardingRules) > 0) && !reflect.DeepEqual(s.ForwardingRules, original.ForwardingRules) {
+		rules := s.ForwardingRules
+		if rules == nil {
+			rules = []*ForwardingRule{}
+		}
+		options.ForwardingRules = &rules
+	}
+
+	return options
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
 }
 
 type ServerListResult struct {
@@ -30,7 +139,14 @@ func (s *ServersService) List() (*ServerListResult, error) {
 }
 
 func (s *ServersService) Create(serverCreateOptions ServerCreateOptions) (*Server, error) {
+	if err := s.client.CheckQuota(QuotaServers, 1); err != nil {
+		return nil, err
+	}
+
 	result, err := s.client.HttpPost(&Server{}, "api/servers", serverCreateOptions)
+	if err == nil {
+		s.client.consumeQuota(QuotaServers, 1)
+	}
 	return result.(*Server), err
 }
 
@@ -39,6 +155,54 @@ func (s *ServersService) Get(id string) (*Server, error) {
 	return result.(*Server), err
 }
 
+// GetByName returns the server with exactly this name. It is an error for
+// no server, or more than one server, to have the name.
+func (s *ServersService) GetByName(name string) (*Server, error) {
+	result, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	named := serversNamed(result.Items, name)
+
+	switch len(named) {
+	case 0:
+		return nil, &mailosaurError{
+			Message:   "No server named [" + name + "] was found.",
+			ErrorType: "invalid_request",
+		}
+	case 1:
+		return named[0], nil
+	default:
+		return nil, &mailosaurError{
+			Message:   fmt.Sprintf("Expected one server named [%s] but found %d.", name, len(named)),
+			ErrorType: "invalid_request",
+		}
+	}
+}
+
+// GetOrCreate returns the server with this name, creating it if there is
+// none.
+func (s *ServersService) GetOrCreate(name string) (*Server, error) {
+	result, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	named := serversNamed(result.Items, name)
+	if len(named) > 1 {
+		return nil, &mailosaurError{
+			Message:   fmt.Sprintf("Expected one server named [%s] but found %d.", name, len(named)),
+			ErrorType: "invalid_request",
+		}
+	}
+	if len(named) == 1 {
+		return named[0], nil
+	}
+
+	return s.Create(ServerCreateOptions{Name: name})
+}
+
 func (s *ServersService) GetPassword(id string) (string, error) {
 	type Result struct {
 		Value string `json:"value"`
@@ -50,8 +214,16 @@ func (s *ServersService) GetPassword(id string) (string, error) {
 	return parsed.Value, err
 }
 
+// Update saves the changes made to a server since it was received from
+// the API. Only the fields that changed are sent, so settings that were
+// not touched are never overwritten.
 func (s *ServersService) Update(id string, server *Server) (*Server, error) {
-	result, err := s.client.HttpPut(&Server{}, "api/servers/"+id, server)
+	return s.Patch(id, server.Changes())
+}
+
+// Patch changes only the fields that are set in options.
+func (s *ServersService) Patch(id string, options ServerUpdateOptions) (*Server, error) {
+	result, err := s.client.HttpPut(&Server{}, "api/servers/"+id, options)
 	return result.(*Server), err
 }
 
@@ -60,12 +232,17 @@ func (s *ServersService) Delete(id string) error {
 }
 
 func (s *ServersService) GenerateEmailAddress(id string) string {
-	host := os.Getenv("MAILOSAUR_SMTP_HOST")
-	if len(host) == 0 {
-		host = "mailosaur.net"
-	}
+	return fmt.Sprintf("%s@%s.%s", getRandomString(), id, smtpHost())
+}
 
-	return fmt.Sprintf("%s@%s.%s", getRandomString(), id, host)
+func serversNamed(servers []*Server, name string) []*Server {
+	var named []*Server
+	for _, server := range servers {
+		if server.Name == name {
+			named = append(named, server)
+		}
+	}
+	return named
 }
 
 func getRandomString() string {
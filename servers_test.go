@@ -1,6 +1,7 @@
 package mailosaur
 
 import (
+	"encoding/json"
 	"os"
 	"strings"
 	"testing"
@@ -20,6 +21,7 @@ func init() {
 }
 
 func TestList(t *testing.T) {
+	skipUnlessLive(t)
 	result, err := client.Servers.List()
 	assert.NoError(t, err)
 
@@ -27,6 +29,7 @@ func TestList(t *testing.T) {
 }
 
 func TestGetNotFound(t *testing.T) {
+	skipUnlessLive(t)
 	// Should throw if server is not found
 	_, err := client.Servers.Get("efe907e9-74ed-4113-a3e0-a3d41d914765")
 
@@ -35,6 +38,7 @@ func TestGetNotFound(t *testing.T) {
 }
 
 func TestCrud(t *testing.T) {
+	skipUnlessLive(t)
 	serverName := "My GO test"
 
 	// Create a new server
@@ -82,6 +86,7 @@ func TestCrud(t *testing.T) {
 }
 
 func TestFailedCreate(t *testing.T) {
+	skipUnlessLive(t)
 	serverCreateOptions := ServerCreateOptions{}
 
 	_, err := client.Servers.Create(serverCreateOptions)
@@ -95,3 +100,79 @@ func TestFailedCreate(t *testing.T) {
 	assert.Equal(t, 400, mErr.HttpStatusCode)
 	assert.True(t, strings.Contains(mErr.HttpResponseBody, "{\"type\":"))
 }
+
+func TestServerUnmarshal(t *testing.T) {
+	var server Server
+	err := json.Unmarshal([]byte(`{
+		"id": "s1",
+		"name": "Signup",
+		"users": ["u1", "u2"],
+		"messages": 3,
+		"retentionDays": 14,
+		"forwardingRules": [{"id": "r1", "field": "to", "operator": "endsWith", "value": "@example.com", "forwardTo": "qa@example.com"}],
+		"smtp": {"host": "mailosaur.net", "port": 2525, "username": "s1@mailosaur.net", "password": "secret"},
+		"pop3": {"host": "mailosaur.net", "port": 110, "username": "s1@mailosaur.net"}
+	}`), &server)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"u1", "u2"}, server.Users)
+	assert.Equal(t, 14, server.RetentionDays)
+	assert.Equal(t, "qa@example.com", server.ForwardingRules[0].ForwardTo)
+	assert.Equal(t, 2525, server.Smtp.Port)
+	assert.Equal(t, "secret", server.Smtp.Password)
+	assert.Equal(t, "", server.Pop3.Password)
+
+	// Nothing has changed since it was received
+	assert.Equal(t, ServerUpdateOptions{}, server.Changes())
+}
+
+func TestServerChanges(t *testing.T) {
+	var server Server
+	json.Unmarshal([]byte(`{"id": "s1", "name": "Signup", "users": ["u1"], "retentionDays": 14,
+		"forwardingRules": [{"field": "to", "operator": "contains", "value": "a", "forwardTo": "b@example.com"}]}`), &server)
+
+	server.Name = "Sign-up"
+	assert.Equal(t, ServerUpdateOptions{Name: "Sign-up"}, server.Changes())
+
+	// Changes inside a rule are detected, as the original is a deep copy
+	server.ForwardingRules[0].Value = "b"
+	changes := server.Changes()
+	assert.Equal(t, "b", (*changes.ForwardingRules)[0].Value)
+
+	server.Users = nil
+	server.ForwardingRules = nil
+	changes = server.Changes()
+	assert.Equal(t, []string{}, *changes.Users)
+	assert.Equal(t, []*ForwardingRule{}, *changes.ForwardingRules)
+
+	// Without an original, only the fields that are set are changes
+	changes = (&Server{RetentionDays: 7}).Changes()
+	assert.Equal(t, 7, *changes.RetentionDays)
+
+	// Going back to the account default is a change
+	server.RetentionDays = 0
+	assert.Equal(t, 0, *server.Changes().RetentionDays)
+}
+
+func TestServersUpdateSendsChanges(t *testing.T) {
+	f := &fakeServers{servers: []*Server{{Id: "s1", Name: "Signup", Users: []string{"u1"}, RetentionDays: 14}}}
+	client := newTestClient(t, f)
+
+	server, err := client.Servers.Get("s1")
+	assert.NoError(t, err)
+
+	server.Name = "Sign-up"
+	_, err = client.Servers.Update(server.Id, server)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "Sign-up"}, f.updates[0])
+
+	_, err = client.Servers.Patch("s1", ServerUpdateOptions{Users: &[]string{}})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"users": []interface{}{}}, f.updates[1])
+
+	server, _ = client.Servers.Get("s1")
+	server.RetentionDays = 0
+	_, err = client.Servers.Update(server.Id, server)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"retentionDays": float64(0)}, f.updates[2])
+}
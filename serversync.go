@@ -0,0 +1,261 @@
+package mailosaur
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ServerSpec describes a server that should exist on the account.
+type ServerSpec struct {
+	Name string `json:"name" yaml:"name"`
+	// Env is the prefix of the variables written by WriteEnv. It defaults
+	// to MAILOSAUR_ followed by the name in upper case.
+	Env string `json:"env,omitempty" yaml:"env,omitempty"`
+}
+
+type ServerSyncConfig struct {
+	Servers []ServerSpec `json:"servers" yaml:"servers"`
+}
+
+// LoadServerSyncConfig reads the desired servers from a .json, .yaml or
+// .yml file.
+func LoadServerSyncConfig(path string) (*ServerSyncConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ServerSyncConfig{}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(config)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(config)
+	default:
+		return nil, fmt.Errorf("unsupported server config file type %q", filepath.Ext(path))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+type ServerSyncOptions struct {
+	// Delete removes servers that are not in the desired set. Without it
+	// other servers on the account are never touched.
+	Delete bool
+}
+
+type ServerSyncAction struct {
+	SyncAction
+	Spec ServerSpec
+	// Server is the existing server, or the created one once applied
+	Server *Server
+}
+
+func (a *ServerSyncAction) String() string {
+	id := ""
+	if a.Server != nil {
+		id = a.Server.Id
+	}
+	return a.describe(id, "")
+}
+
+type ServerSyncPlan struct {
+	Actions []*ServerSyncAction
+}
+
+func (p *ServerSyncPlan) steps() []syncStep {
+	steps := make([]syncStep, len(p.Actions))
+	for i, a := range p.Actions {
+		steps[i] = a
+	}
+	return steps
+}
+
+// HasChanges reports whether applying the plan would change anything.
+func (p *ServerSyncPlan) HasChanges() bool {
+	return syncHasChanges(p.steps())
+}
+
+func (p *ServerSyncPlan) String() string {
+	return syncPlanString(p.steps(), false)
+}
+
+// PlanSync compares the desired servers, matched by name, with those on the
+// account and returns the actions needed to reconcile them, without making
+// any changes. Printing the plan gives a dry run.
+func (s *ServersService) PlanSync(desired []ServerSpec, options *ServerSyncOptions) (*ServerSyncPlan, error) {
+	if options == nil {
+		options = &ServerSyncOptions{}
+	}
+
+	names := make([]string, len(desired))
+	for i, d := range desired {
+		names[i] = d.Name
+	}
+	seen, err := checkSyncNames("server", names)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	existingNames := make([]string, len(existing.Items))
+	for i, server := range existing.Items {
+		existingNames[i] = server.Name
+	}
+	byName, surplus := matchSyncNames(seen, existingNames)
+
+	plan := &ServerSyncPlan{}
+	for _, d := range desired {
+		action := &ServerSyncAction{SyncAction: SyncAction{Name: d.Name}, Spec: d}
+		if i, ok := byName[d.Name]; ok {
+			action.Server = existing.Items[i]
+		}
+
+		if action.Server == nil {
+			action.Action = SyncCreate
+		} else {
+			action.Action = SyncKeep
+		}
+		plan.Actions = append(plan.Actions, action)
+	}
+
+	if options.Delete {
+		for _, i := range surplus {
+			server := existing.Items[i]
+			plan.Actions = append(plan.Actions, &ServerSyncAction{SyncAction: SyncAction{Action: SyncDelete, Name: server.Name}, Server: server})
+		}
+	}
+
+	return plan, nil
+}
+
+// ApplySync carries out a plan from PlanSync, stopping at the first error.
+// Actions that completed are marked as applied.
+func (s *ServersService) ApplySync(plan *ServerSyncPlan) error {
+	for _, a := range plan.Actions {
+		var err error
+
+		switch a.Action {
+		case SyncCreate:
+			var server *Server
+			server, err = s.Create(ServerCreateOptions{Name: a.Name})
+			if err == nil {
+				a.Server = server
+			}
+		case SyncDelete:
+			err = s.Delete(a.Server.Id)
+		}
+
+		if err != nil {
+			return err
+		}
+		a.Applied = true
+	}
+
+	return nil
+}
+
+// Sync plans and applies changes in one step, returning the plan so that
+// the changes can be reported.
+func (s *ServersService) Sync(desired []ServerSpec, options *ServerSyncOptions) (*ServerSyncPlan, error) {
+	plan, err := s.PlanSync(desired, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, s.ApplySync(plan)
+}
+
+// WriteEnv writes the ID and SMTP password of every server in the plan as
+// env-file lines, such as MAILOSAUR_SIGNUP_SERVER and
+// MAILOSAUR_SIGNUP_PASSWORD. Servers that are deleted, or not yet created
+// in a dry run, are skipped. Nothing is written if any server lacks a
+// usable variable name, two servers would share one, or a value contains a
+// line break.
+func (s *ServersService) WriteEnv(w io.Writer, plan *ServerSyncPlan) error {
+	var sb strings.Builder
+	seen := map[string]string{}
+
+	for _, a := range plan.Actions {
+		if a.Action == SyncDelete || a.Server == nil {
+			continue
+		}
+
+		prefix := a.Spec.Env
+		if len(prefix) == 0 {
+			name := envName(a.Name)
+			if len(name) == 0 {
+				return &mailosaurError{Message: "The server [" + a.Name + "] has no letters or digits to name its variables after, so it needs an env prefix.", ErrorType: "invalid_request"}
+			}
+			prefix = "MAILOSAUR_" + name
+		}
+
+		if other, ok := seen[prefix]; ok {
+			return &mailosaurError{Message: "The servers [" + other + "] and [" + a.Name + "] would both write " + prefix + " variables, so one of them needs a different env prefix.", ErrorType: "invalid_request"}
+		}
+		seen[prefix] = a.Name
+
+		password, err := s.GetPassword(a.Server.Id)
+		if err != nil {
+			return err
+		}
+
+		for _, v := range []struct{ suffix, value string }{{"_SERVER", a.Server.Id}, {"_PASSWORD", password}} {
+			if strings.ContainsAny(v.value, "\r\n") {
+				return &mailosaurError{Message: "The " + prefix + v.suffix + " value for the server [" + a.Name + "] contains a line break.", ErrorType: "invalid_request"}
+			}
+			fmt.Fprintf(&sb, "%s%s=%s\n", prefix, v.suffix, envValue(v.value))
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// envName turns a server name into an environment variable name, so
+// "Sign-up emails" becomes SIGN_UP_EMAILS.
+func envName(name string) string {
+	var sb strings.Builder
+	underscore := false
+	for _, r := range strings.ToUpper(name) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			if underscore && sb.Len() > 0 {
+				sb.WriteByte('_')
+			}
+			sb.WriteRune(r)
+			underscore = false
+		} else {
+			underscore = true
+		}
+	}
+	return sb.String()
+}
+
+// envValue quotes a value for an env file. Values with line breaks are
+// refused before this, since many env file readers cannot parse them even
+// when quoted.
+func envValue(value string) string {
+	if strings.ContainsAny(value, " \t\"'#$\\`") {
+		return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+	}
+	return value
+}
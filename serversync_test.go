@@ -0,0 +1,232 @@
+package mailosaur
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeServers struct {
+	servers  []*Server
+	requests []string
+	// updates are the bodies of PUT requests
+	updates []map[string]interface{}
+	// passwords replace the default of "pw-" followed by the server ID
+	passwords map[string]string
+}
+
+func (f *fakeServers) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/api/servers":
+		json.NewEncoder(w).Encode(&ServerListResult{Items: f.servers})
+	case r.Method == "POST" && r.URL.Path == "/api/servers":
+		var options ServerCreateOptions
+		json.NewDecoder(r.Body).Decode(&options)
+		server := &Server{Id: "new-" + strings.ToLower(options.Name), Name: options.Name}
+		f.servers = append(f.servers, server)
+		json.NewEncoder(w).Encode(server)
+	case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/password"):
+		id := strings.Split(r.URL.Path, "/")[3]
+		password, ok := f.passwords[id]
+		if !ok {
+			password = "pw-" + id
+		}
+		json.NewEncoder(w).Encode(map[string]string{"value": password})
+	case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/api/servers/"):
+		for _, server := range f.servers {
+			if server.Id == r.URL.Path[len("/api/servers/"):] {
+				json.NewEncoder(w).Encode(server)
+			}
+		}
+	case r.Method == "PUT":
+		var update map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&update)
+		f.updates = append(f.updates, update)
+		json.NewEncoder(w).Encode(&Server{Id: r.URL.Path[len("/api/servers/"):]})
+	case r.Method == "DELETE":
+		w.WriteHeader(204)
+	}
+}
+
+func TestServersGetByName(t *testing.T) {
+	f := &fakeServers{servers: []*Server{{Id: "s1", Name: "Signup"}, {Id: "s2", Name: "Billing"}, {Id: "s3", Name: "Billing"}}}
+	client := newTestClient(t, f)
+
+	server, err := client.Servers.GetByName("Signup")
+	assert.NoError(t, err)
+	assert.Equal(t, "s1", server.Id)
+
+	_, err = client.Servers.GetByName("Billing")
+	assert.EqualError(t, err, "Expected one server named [Billing] but found 2.")
+
+	_, err = client.Servers.GetByName("signup")
+	assert.EqualError(t, err, "No server named [signup] was found.")
+}
+
+func TestServersGetOrCreate(t *testing.T) {
+	f := &fakeServers{servers: []*Server{{Id: "s1", Name: "Signup"}}}
+	client := newTestClient(t, f)
+
+	server, err := client.Servers.GetOrCreate("Signup")
+	assert.NoError(t, err)
+	assert.Equal(t, "s1", server.Id)
+
+	server, err = client.Servers.GetOrCreate("Reset")
+	assert.NoError(t, err)
+	assert.Equal(t, "new-reset", server.Id)
+	assert.Contains(t, f.requests, "POST /api/servers")
+
+	// Once created, the same server is returned
+	f.requests = nil
+	server, err = client.Servers.GetOrCreate("Reset")
+	assert.NoError(t, err)
+	assert.Equal(t, "new-reset", server.Id)
+	assert.Equal(t, []string{"GET /api/servers"}, f.requests)
+}
+
+func TestServersPlanSync(t *testing.T) {
+	f := &fakeServers{servers: []*Server{{Id: "s1", Name: "Signup"}, {Id: "s2", Name: "Legacy"}}}
+	client := newTestClient(t, f)
+
+	desired := []ServerSpec{{Name: "Signup"}, {Name: "Password reset", Env: "RESET"}}
+
+	plan, err := client.Servers.PlanSync(desired, nil)
+	assert.NoError(t, err)
+	assert.True(t, plan.HasChanges())
+	assert.Equal(t, "  keep   Signup (s1)\n+ create Password reset\n1 to create, 0 to delete, 1 unchanged", plan.String())
+	assert.Equal(t, []string{"GET /api/servers"}, f.requests)
+
+	plan, err = client.Servers.PlanSync(desired, &ServerSyncOptions{Delete: true})
+	assert.NoError(t, err)
+	assert.Equal(t, SyncDelete, plan.Actions[2].Action)
+	assert.Equal(t, "- delete Legacy (s2)", plan.Actions[2].String())
+
+	_, err = client.Servers.PlanSync([]ServerSpec{{Name: "Signup"}, {Name: "Signup"}}, nil)
+	assert.EqualError(t, err, "The server [Signup] is listed more than once.")
+
+	_, err = client.Servers.PlanSync([]ServerSpec{{}}, nil)
+	assert.Error(t, err)
+}
+
+func TestServersSync(t *testing.T) {
+	f := &fakeServers{servers: []*Server{{Id: "s1", Name: "Signup"}, {Id: "s2", Name: "Legacy"}}}
+	client := newTestClient(t, f)
+
+	plan, err := client.Servers.Sync([]ServerSpec{{Name: "Signup"}, {Name: "Password reset", Env: "RESET"}}, nil)
+	assert.NoError(t, err)
+	assert.True(t, plan.Actions[1].Applied)
+	assert.Equal(t, "new-password reset", plan.Actions[1].Server.Id)
+	assert.NotContains(t, f.requests, "DELETE /api/servers/s2")
+
+	var env bytes.Buffer
+	assert.NoError(t, client.Servers.WriteEnv(&env, plan))
+	assert.Equal(t, "MAILOSAUR_SIGNUP_SERVER=s1\n"+
+		"MAILOSAUR_SIGNUP_PASSWORD=pw-s1\n"+
+		"RESET_SERVER='new-password reset'\n"+
+		"RESET_PASSWORD='pw-new-password reset'\n", env.String())
+
+	plan, err = client.Servers.Sync([]ServerSpec{{Name: "Signup"}}, &ServerSyncOptions{Delete: true})
+	assert.NoError(t, err)
+	assert.Contains(t, f.requests, "DELETE /api/servers/s2")
+}
+
+func TestServersWriteEnvDryRun(t *testing.T) {
+	f := &fakeServers{servers: []*Server{{Id: "s1", Name: "Sign-up emails"}}}
+	client := newTestClient(t, f)
+
+	plan, _ := client.Servers.PlanSync([]ServerSpec{{Name: "Sign-up emails"}, {Name: "New"}}, nil)
+
+	var env bytes.Buffer
+	assert.NoError(t, client.Servers.WriteEnv(&env, plan))
+	assert.Equal(t, "MAILOSAUR_SIGN_UP_EMAILS_SERVER=s1\nMAILOSAUR_SIGN_UP_EMAILS_PASSWORD=pw-s1\n", env.String())
+	assert.NotContains(t, f.requests, "POST /api/servers")
+}
+
+func TestLoadServerSyncConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlPath := filepath.Join(dir, "servers.yml")
+	os.WriteFile(yamlPath, []byte("servers:\n  - name: Signup\n  - name: Password reset\n    env: RESET\n"), 0644)
+
+	config, err := LoadServerSyncConfig(yamlPath)
+	assert.NoError(t, err)
+	assert.Equal(t, []ServerSpec{{Name: "Signup"}, {Name: "Password reset", Env: "RESET"}}, config.Servers)
+
+	jsonPath := filepath.Join(dir, "servers.json")
+	os.WriteFile(jsonPath, []byte(`{"servers": [{"name": "Signup"}]}`), 0644)
+
+	config, err = LoadServerSyncConfig(jsonPath)
+	assert.NoError(t, err)
+	assert.Equal(t, []ServerSpec{{Name: "Signup"}}, config.Servers)
+
+	os.WriteFile(jsonPath, []byte(`{"servers": [{"nmae": "Signup"}]}`), 0644)
+	_, err = LoadServerSyncConfig(jsonPath)
+	assert.Error(t, err)
+
+	_, err = LoadServerSyncConfig(filepath.Join(dir, "servers.toml"))
+	assert.Error(t, err)
+}
+
+func TestServersWriteEnvInvalid(t *testing.T) {
+	cases := map[string]struct {
+		servers   []*Server
+		passwords map[string]string
+		specs     []ServerSpec
+		message   string
+	}{
+		"no letters or digits": {
+			[]*Server{{Id: "s1", Name: "--"}},
+			nil,
+			[]ServerSpec{{Name: "--"}},
+			"The server [--] has no letters or digits to name its variables after, so it needs an env prefix.",
+		},
+		"colliding names": {
+			[]*Server{{Id: "s1", Name: "a-b"}, {Id: "s2", Name: "a b"}},
+			nil,
+			[]ServerSpec{{Name: "a-b"}, {Name: "a b"}},
+			"The servers [a-b] and [a b] would both write MAILOSAUR_A_B variables, so one of them needs a different env prefix.",
+		},
+		"colliding prefixes": {
+			[]*Server{{Id: "s1", Name: "Signup"}, {Id: "s2", Name: "Reset"}},
+			nil,
+			[]ServerSpec{{Name: "Signup", Env: "APP"}, {Name: "Reset", Env: "APP"}},
+			"The servers [Signup] and [Reset] would both write APP variables, so one of them needs a different env prefix.",
+		},
+		"line break": {
+			[]*Server{{Id: "s1", Name: "Signup"}, {Id: "s2", Name: "Reset"}},
+			map[string]string{"s2": "pw\nINJECTED=1"},
+			[]ServerSpec{{Name: "Signup"}, {Name: "Reset"}},
+			"The MAILOSAUR_RESET_PASSWORD value for the server [Reset] contains a line break.",
+		},
+	}
+
+	for name, c := range cases {
+		f := &fakeServers{servers: c.servers, passwords: c.passwords}
+		client := newTestClient(t, f)
+
+		plan, err := client.Servers.PlanSync(c.specs, nil)
+		assert.NoError(t, err, name)
+
+		var env bytes.Buffer
+		err = client.Servers.WriteEnv(&env, plan)
+		assert.EqualError(t, err, c.message, name)
+		assert.Equal(t, "", env.String(), name)
+	}
+
+	// Distinct names that only differ in punctuation are fine with a prefix
+	f := &fakeServers{servers: []*Server{{Id: "s1", Name: "a-b"}, {Id: "s2", Name: "a b"}}}
+	client := newTestClient(t, f)
+	plan, _ := client.Servers.PlanSync([]ServerSpec{{Name: "a-b"}, {Name: "a b", Env: "AB"}}, nil)
+
+	var env bytes.Buffer
+	assert.NoError(t, client.Servers.WriteEnv(&env, plan))
+	assert.Equal(t, "MAILOSAUR_A_B_SERVER=s1\nMAILOSAUR_A_B_PASSWORD=pw-s1\nAB_SERVER=s2\nAB_PASSWORD=pw-s2\n", env.String())
+}
@@ -0,0 +1,450 @@
+package mailosaur
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	SmsEncodingGsm7 = "GSM-7"
+	SmsEncodingUcs2 = "UCS-2"
+)
+
+var (
+	e164Pattern       = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+	phoneSeparators   = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "", " ", "")
+	smsPartPattern    = regexp.MustCompile(`^\s*\(?(\d{1,2})/(\d{1,2})\)?\s*`)
+	smsCodePattern    = regexp.MustCompile(`\b[0-9]{4,8}\b`)
+	smsKeywordPattern = regexp.MustCompile(`(?i)(code|otp|pin|passcode|password|verification)\D{0,20}?\b([0-9]{4,8})\b`)
+)
+
+// IsValidE164 reports whether the number is in E.164 format, i.e. a '+'
+// followed by a country code and subscriber number, up to 15 digits.
+func IsValidE164(number string) bool {
+	return e164Pattern.MatchString(number)
+}
+
+// NormalisePhoneNumber converts a phone number to E.164, removing spaces
+// and punctuation and replacing a leading international "00" with '+'.
+// Numbers in national format have their trunk prefix ('0') replaced with
+// defaultCountryCode, which may be empty if all numbers are international.
+func NormalisePhoneNumber(number string, defaultCountryCode string) (string, error) {
+	n := phoneSeparators.Replace(strings.TrimSpace(number))
+
+	switch {
+	case strings.HasPrefix(n, "+"):
+	case strings.HasPrefix(n, "00"):
+		n = "+" + n[2:]
+	case len(defaultCountryCode) > 0:
+		n = "+" + strings.TrimPrefix(defaultCountryCode, "+") + strings.TrimPrefix(n, "0")
+	}
+
+	if !IsValidE164(n) {
+		return "", &mailosaurError{
+			Message:   "The phone number [" + number + "] is not a valid E.164 number.",
+			ErrorType: "invalid_phone_number",
+		}
+	}
+
+	return n, nil
+}
+
+// SmsSearchCriteria returns search criteria for SMS messages sent to the
+// given number, which is normalised to E.164 first. Body may be empty.
+func SmsSearchCriteria(sentTo string, body string) (*SearchCriteria, error) {
+	number, err := NormalisePhoneNumber(sentTo, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &SearchCriteria{SentTo: number, Body: body}, nil
+}
+
+func (m *Message) IsSms() bool {
+	return strings.EqualFold(m.Type, "SMS")
+}
+
+// JoinSmsParts combines the segments of a multipart SMS into one message.
+// Parts are ordered by a leading "(1/3)" style marker when every part has
+// one, otherwise by the time they were received.
+func JoinSmsParts(parts []*Message) *Message {
+	if len(parts) == 0 {
+		return nil
+	}
+
+	ordered := make([]*Message, len(parts))
+	copy(ordered, parts)
+
+	indexes := make(map[*Message]int)
+	for _, p := range ordered {
+		if match := smsPartPattern.FindStringSubmatch(smsBody(p)); match != nil {
+			indexes[p], _ = strconv.Atoi(match[1])
+		}
+	}
+	marked := len(indexes) == len(ordered)
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if marked {
+			return indexes[ordered[i]] < indexes[ordered[j]]
+		}
+		return ordered[i].Received.Before(ordered[j].Received)
+	})
+
+	var body strings.Builder
+	content := &MessageContent{}
+	seen := map[string]bool{}
+
+	received := ordered[0].Received
+	for _, p := range ordered {
+		if p.Received.After(received) {
+			received = p.Received
+		}
+
+		b := smsBody(p)
+		if marked {
+			b = smsPartPattern.ReplaceAllString(b, "")
+		}
+		body.WriteString(b)
+
+		if p.Text != nil {
+			content.Links = append(content.Links, p.Text.Links...)
+			for _, c := range p.Text.Codes {
+				if !seen[c.Value] {
+					seen[c.Value] = true
+					content.Codes = append(content.Codes, c)
+				}
+			}
+		}
+	}
+	content.Body = body.String()
+
+	joined := *ordered[0]
+	joined.Text = content
+	joined.Received = received
+
+	return &joined
+}
+
+// SmsCode returns the one-time code in an SMS. Codes extracted by
+// Mailosaur are preferred, falling back to a 4-8 digit number following a
+// keyword such as "code" or "PIN", then to the first 4-8 digit number.
+func SmsCode(message *Message) (string, bool) {
+	if message.Text != nil && len(message.Text.Codes) > 0 {
+		return message.Text.Codes[0].Value, true
+	}
+
+	body := smsBody(message)
+
+	if match := smsKeywordPattern.FindStringSubmatch(body); match != nil {
+		return match[2], true
+	}
+
+	if code := smsCodePattern.FindString(body); len(code) > 0 {
+		return code, true
+	}
+
+	return "", false
+}
+
+type SmsSegmentInfo struct {
+	Encoding string
+	// Length is measured in the units of the encoding: septets for GSM-7,
+	// where extension characters such as '€' count twice, and UTF-16 code
+	// units for UCS-2.
+	Length   int
+	Segments int
+}
+
+const (
+	gsm7Basic     = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	gsm7Extension = "\f^{}\\[~]|€"
+)
+
+// SmsSegments returns the encoding, length and number of segments needed
+// to send the body. A single GSM-7 segment holds 160 septets, or 153 in a
+// multipart message; a UCS-2 segment holds 70 code units, or 67.
+func SmsSegments(body string) *SmsSegmentInfo {
+	var widths []int
+	gsm7 := true
+
+	for _, r := range body {
+		switch {
+		case strings.ContainsRune(gsm7Basic, r):
+			widths = append(widths, 1)
+		case strings.ContainsRune(gsm7Extension, r):
+			widths = append(widths, 2)
+		default:
+			gsm7 = false
+		}
+		if !gsm7 {
+			break
+		}
+	}
+
+	info := &SmsSegmentInfo{Encoding: SmsEncodingGsm7}
+	single, multi := 160, 153
+
+	if !gsm7 {
+		info.Encoding = SmsEncodingUcs2
+		single, multi = 70, 67
+		widths = widths[:0]
+		for _, r := range body {
+			widths = append(widths, len(utf16.Encode([]rune{r})))
+		}
+	}
+
+	for _, w := range widths {
+		info.Length += w
+	}
+
+	if info.Length <= single {
+		info.Segments = 1
+		return info
+	}
+
+	// Characters are never split across segments, so a segment may end
+	// a unit short of the limit
+	used := 0
+	info.Segments = 1
+	for _, w := range widths {
+		if used+w > multi {
+			info.Segments++
+			used = 0
+		}
+		used += w
+	}
+
+	return info
+}
+
+type SmsWaitOptions struct {
+	// Timeout in seconds, defaulting to 10
+	Timeout       int
+	ReceivedAfter time.Time
+	// Body, when set, must be contained in the (joined) message body
+	Body string
+	// Parts is the number of segments to wait for and join, defaulting to 1
+	Parts int
+}
+
+// WaitForSms waits for an SMS sent to one of the server's phone numbers.
+func (s *MessagesService) WaitForSms(server string, number string, options *SmsWaitOptions) (*Message, error) {
+	if options == nil {
+		options = &SmsWaitOptions{}
+	}
+
+	body := options.Body
+	if options.Parts > 1 {
+		// Parts may split the body, so it is checked after joining
+		body = ""
+	}
+
+	criteria, err := SmsSearchCriteria(number, body)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := options.Parts
+	if parts < 1 {
+		parts = 1
+	}
+	if err := s.client.CheckQuota(QuotaSms, parts); err != nil {
+		return nil, err
+	}
+
+	params := &MessageSearchParams{
+		Server:        server,
+		ReceivedAfter: options.ReceivedAfter,
+		Timeout:       options.Timeout,
+	}
+
+	var message *Message
+	if parts == 1 {
+		message, err = s.Get(params, criteria)
+	} else {
+		message, err = s.waitForSmsParts(params, criteria, options)
+	}
+
+	if err == nil {
+		s.client.consumeQuota(QuotaSms, parts)
+	}
+	return message, err
+}
+
+// smsPartWindow is the longest gap expected between the parts of one
+// multipart SMS.
+const smsPartWindow = time.Minute
+
+func (s *MessagesService) waitForSmsParts(params *MessageSearchParams, criteria *SearchCriteria, options *SmsWaitOptions) (*Message, error) {
+	if params.ReceivedAfter.IsZero() {
+		params.ReceivedAfter = time.Now().Add(-(1 * time.Hour))
+	}
+	if params.Timeout == 0 {
+		params.Timeout = 10
+	}
+
+	op := &Operation{Name: "messages.waitForSms", Target: params.Server, Criteria: criteria, Started: time.Now()}
+	message, err := s.pollSmsParts(params, criteria, options, op)
+	op.Err = err
+	if message != nil {
+		op.Results = 1
+	}
+	s.client.observe(op)
+
+	return message, err
+}
+
+func (s *MessagesService) pollSmsParts(params *MessageSearchParams, criteria *SearchCriteria, options *SmsWaitOptions, op *Operation) (*Message, error) {
+	for {
+		result, delayHeader, err := s.searchOnce(params, criteria)
+		op.Polls++
+		if err != nil {
+			return nil, err
+		}
+
+		// Newest first, so the most recent complete message wins
+		groups := groupSmsParts(result.Items)
+		for i := len(groups) - 1; i >= 0; i-- {
+			if !smsPartsComplete(groups[i], options.Parts) {
+				continue
+			}
+
+			parts := make([]*Message, 0, options.Parts)
+			for _, summary := range groups[i] {
+				message, err := s.GetById(summary.Id)
+				if err != nil {
+					return nil, err
+				}
+				parts = append(parts, message)
+			}
+
+			joined := JoinSmsParts(parts)
+			if strings.Contains(joined.Text.Body, options.Body) {
+				return joined, nil
+			}
+		}
+
+		delay := time.Duration(pollDelay(delayHeader, op.Polls-1)) * time.Millisecond
+		if time.Since(op.Started)+delay > time.Duration(params.Timeout)*time.Second {
+			return nil, &mailosaurError{
+				Message:   "An SMS of " + strconv.Itoa(options.Parts) + " parts sent to [" + criteria.SentTo + "] was not received within " + strconv.Itoa(params.Timeout) + "s",
+				ErrorType: "search_timeout",
+			}
+		}
+
+		time.Sleep(delay)
+	}
+}
+
+// groupSmsParts splits search results into the parts of each multipart
+// SMS, ordered by when each message was completed. Parts belong together
+// when they are from the same sender, carry the same "(n/m)" total and
+// arrive within smsPartWindow of each other. A marked part joins the
+// oldest such message that does not already have its index, so parts of
+// two messages sent close together are not mixed.
+func groupSmsParts(summaries []*MessageSummary) [][]*MessageSummary {
+	ordered := make([]*MessageSummary, len(summaries))
+	copy(ordered, summaries)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Received.Before(ordered[j].Received)
+	})
+
+	type group struct {
+		key     string
+		parts   []*MessageSummary
+		indexes map[int]bool
+	}
+	var groups []*group
+
+	for _, summary := range ordered {
+		sender := ""
+		if len(summary.From) > 0 {
+			sender = summary.From[0].Phone + summary.From[0].Email
+		}
+
+		index, total, _ := smsPartMarker(summary.Summary)
+		key := sender + "|" + strconv.Itoa(total)
+
+		var target *group
+		for _, g := range groups {
+			last := g.parts[len(g.parts)-1]
+			if g.key != key || summary.Received.Sub(last.Received) > smsPartWindow {
+				continue
+			}
+			if index > 0 && (g.indexes[index] || len(g.indexes) >= total) {
+				continue
+			}
+			target = g
+			break
+		}
+
+		if target == nil {
+			target = &group{key: key, indexes: map[int]bool{}}
+			groups = append(groups, target)
+		}
+
+		target.parts = append(target.parts, summary)
+		if index > 0 {
+			target.indexes[index] = true
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].parts[len(groups[i].parts)-1].Received.Before(groups[j].parts[len(groups[j].parts)-1].Received)
+	})
+
+	result := make([][]*MessageSummary, len(groups))
+	for i, g := range groups {
+		result[i] = g.parts
+	}
+	return result
+}
+
+// smsPartsComplete reports whether a group from groupSmsParts holds every
+// part of an SMS of the given number of parts. Marked parts must have a
+// total of parts and the indexes 1 to parts.
+func smsPartsComplete(group []*MessageSummary, parts int) bool {
+	if len(group) != parts {
+		return false
+	}
+
+	seen := map[int]bool{}
+	for _, p := range group {
+		index, total, ok := smsPartMarker(p.Summary)
+		if !ok {
+			continue
+		}
+		if total != parts || seen[index] {
+			return false
+		}
+		seen[index] = true
+	}
+
+	return len(seen) == 0 || len(seen) == parts
+}
+
+// smsPartMarker returns the index and total of a leading "(n/m)" marker.
+func smsPartMarker(body string) (int, int, bool) {
+	match := smsPartPattern.FindStringSubmatch(body)
+	if match == nil {
+		return 0, 0, false
+	}
+
+	index, _ := strconv.Atoi(match[1])
+	total, _ := strconv.Atoi(match[2])
+	if index < 1 || index > total {
+		return 0, 0, false
+	}
+	return index, total, true
+}
+
+func smsBody(message *Message) string {
+	if message.Text == nil {
+		return ""
+	}
+	return message.Text.Body
+}
@@ -0,0 +1,219 @@
+package mailosaur
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalisePhoneNumber(t *testing.T) {
+	cases := map[string]string{
+		"+44 7700 900123":   "+447700900123",
+		"0044 7700-900123":  "+447700900123",
+		"(+1) 415.555.0100": "+14155550100",
+	}
+	for input, expected := range cases {
+		actual, err := NormalisePhoneNumber(input, "")
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, actual, input)
+	}
+
+	actual, err := NormalisePhoneNumber("07700 900123", "44")
+	assert.NoError(t, err)
+	assert.Equal(t, "+447700900123", actual)
+
+	for _, input := range []string{"07700 900123", "+0123456789", "+1234", "+1234567890123456", "phone"} {
+		_, err := NormalisePhoneNumber(input, "")
+		assert.Error(t, err, input)
+		assert.Equal(t, "invalid_phone_number", err.(*mailosaurError).ErrorType)
+	}
+}
+
+func TestSmsSearchCriteria(t *testing.T) {
+	criteria, err := SmsSearchCriteria("+44 7700 900123", "Your code")
+	assert.NoError(t, err)
+	assert.Equal(t, "+447700900123", criteria.SentTo)
+	assert.Equal(t, "Your code", criteria.Body)
+
+	_, err = SmsSearchCriteria("12", "")
+	assert.Error(t, err)
+}
+
+func TestSmsSegments(t *testing.T) {
+	cases := []struct {
+		body     string
+		encoding string
+		length   int
+		segments int
+	}{
+		{"Hello", SmsEncodingGsm7, 5, 1},
+		{strings.Repeat("a", 160), SmsEncodingGsm7, 160, 1},
+		{strings.Repeat("a", 161), SmsEncodingGsm7, 161, 2},
+		{strings.Repeat("a", 306), SmsEncodingGsm7, 306, 2},
+		{strings.Repeat("a", 307), SmsEncodingGsm7, 307, 3},
+		{"Total €5", SmsEncodingGsm7, 9, 1},
+		// The '€' would straddle the segment boundary, so it moves to the next
+		{strings.Repeat("a", 152) + "€" + strings.Repeat("a", 10), SmsEncodingGsm7, 164, 2},
+		{"Привет", SmsEncodingUcs2, 6, 1},
+		{strings.Repeat("ж", 71), SmsEncodingUcs2, 71, 2},
+		{"Hi 👋", SmsEncodingUcs2, 5, 1},
+	}
+
+	for _, c := range cases {
+		info := SmsSegments(c.body)
+		assert.Equal(t, c.encoding, info.Encoding, c.body)
+		assert.Equal(t, c.length, info.Length, c.body)
+		assert.Equal(t, c.segments, info.Segments, c.body)
+	}
+}
+
+func TestJoinSmsParts(t *testing.T) {
+	received := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	part := func(body string, offset int, codes ...string) *Message {
+		m := &Message{Type: "SMS", Received: received.Add(time.Duration(offset) * time.Second), Text: &MessageContent{Body: body}}
+		for _, c := range codes {
+			m.Text.Codes = append(m.Text.Codes, &Code{Value: c})
+		}
+		return m
+	}
+
+	// Ordered by marker even though received out of order
+	joined := JoinSmsParts([]*Message{
+		part("(2/2) is 123456", 0, "123456"),
+		part("(1/2) Your code ", 1),
+	})
+	assert.Equal(t, "Your code is 123456", joined.Text.Body)
+	assert.Equal(t, "123456", joined.Text.Codes[0].Value)
+	assert.Equal(t, received.Add(time.Second), joined.Received)
+	assert.True(t, joined.IsSms())
+
+	// Ordered by time without markers
+	joined = JoinSmsParts([]*Message{part("world", 5), part("Hello ", 1)})
+	assert.Equal(t, "Hello world", joined.Text.Body)
+
+	assert.Nil(t, JoinSmsParts(nil))
+}
+
+func TestGroupSmsParts(t *testing.T) {
+	received := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	part := func(id string, from string, summary string, offset int) *MessageSummary {
+		return &MessageSummary{
+			Id:       id,
+			From:     []*MessageAddress{{Phone: from}},
+			Summary:  summary,
+			Received: received.Add(time.Duration(offset) * time.Second),
+		}
+	}
+
+	groups := groupSmsParts([]*MessageSummary{
+		// Newest first, as returned by a search
+		part("b2", "+15550002", "(2/2) b", 4),
+		part("a2", "+15550001", "(2/2) a", 3),
+		part("b1", "+15550002", "(1/2) b", 2),
+		part("a1", "+15550001", "(1/2) a", 1),
+		part("old", "+15550001", "older", -300),
+	})
+
+	assert.Equal(t, [][]string{{"old"}, {"a1", "a2"}, {"b1", "b2"}}, smsGroupIds(groups))
+
+	// Interleaved messages from the same sender join the oldest message
+	// that is missing the part
+	groups = groupSmsParts([]*MessageSummary{
+		part("x1", "+15550001", "(1/2) x", 1),
+		part("y1", "+15550001", "(1/2) y", 2),
+		part("x2", "+15550001", "(2/2) x", 3),
+	})
+	assert.Equal(t, [][]string{{"y1"}, {"x1", "x2"}}, smsGroupIds(groups))
+	assert.False(t, smsPartsComplete(groups[0], 2))
+	assert.True(t, smsPartsComplete(groups[1], 2))
+
+	// Parts of a longer message are never taken as a complete shorter one
+	groups = groupSmsParts([]*MessageSummary{
+		part("z1", "+15550001", "(1/3) z", 1),
+		part("z2", "+15550001", "(2/3) z", 2),
+		part("w2", "+15550001", "(2/2) w", 3),
+	})
+	assert.Equal(t, [][]string{{"z1", "z2"}, {"w2"}}, smsGroupIds(groups))
+	assert.False(t, smsPartsComplete(groups[0], 2))
+	assert.False(t, smsPartsComplete(groups[0], 3))
+	assert.False(t, smsPartsComplete([]*MessageSummary{part("z1", "", "(1/3) z", 1), part("z3", "", "(3/3) z", 2)}, 2))
+
+	// Unmarked parts are complete on count alone
+	assert.True(t, smsPartsComplete([]*MessageSummary{part("u1", "", "Hello", 1), part("u2", "", "world", 2)}, 2))
+}
+
+func smsGroupIds(groups [][]*MessageSummary) [][]string {
+	var ids [][]string
+	for _, g := range groups {
+		var group []string
+		for _, p := range g {
+			group = append(group, p.Id)
+		}
+		ids = append(ids, group)
+	}
+	return ids
+}
+
+func TestWaitForSmsParts(t *testing.T) {
+	received := time.Now().UTC()
+	messages := map[string]*Message{
+		"a1": {Id: "a1", Type: "SMS", Received: received, From: []*MessageAddress{{Phone: "+15550001"}}, Text: &MessageContent{Body: "(1/2) Your code "}},
+		"b1": {Id: "b1", Type: "SMS", Received: received.Add(time.Second), From: []*MessageAddress{{Phone: "+15550002"}}, Text: &MessageContent{Body: "(1/2) Other "}},
+		"a2": {Id: "a2", Type: "SMS", Received: received.Add(2 * time.Second), From: []*MessageAddress{{Phone: "+15550001"}}, Text: &MessageContent{Body: "(2/2) is 123456"}},
+	}
+
+	var searches int32
+	c := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/messages/search" {
+			// The second part arrives on the second poll
+			ids := []string{"b1", "a1"}
+			if atomic.AddInt32(&searches, 1) > 1 {
+				ids = []string{"a2", "b1", "a1"}
+			}
+
+			result := &MessageListResult{}
+			for _, id := range ids {
+				m := messages[id]
+				result.Items = append(result.Items, &MessageSummary{Id: m.Id, From: m.From, Received: m.Received, Summary: m.Text.Body})
+			}
+			w.Header().Set("x-ms-delay", "50")
+			json.NewEncoder(w).Encode(result)
+			return
+		}
+		json.NewEncoder(w).Encode(messages[strings.TrimPrefix(r.URL.Path, "/api/messages/")])
+	}))
+
+	started := time.Now()
+	message, err := c.Messages.WaitForSms("abcd1234", "+15550100", &SmsWaitOptions{Parts: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, "Your code is 123456", message.Text.Body)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&searches))
+
+	// The delay header is honoured rather than waiting a second
+	assert.True(t, time.Since(started) < 500*time.Millisecond)
+}
+
+func TestSmsCode(t *testing.T) {
+	code, ok := SmsCode(&Message{Text: &MessageContent{Body: "Use 9999", Codes: []*Code{{Value: "1234"}}}})
+	assert.True(t, ok)
+	assert.Equal(t, "1234", code)
+
+	code, ok = SmsCode(&Message{Text: &MessageContent{Body: "Order 20240101 shipped. Your verification code: 482913"}})
+	assert.True(t, ok)
+	assert.Equal(t, "482913", code)
+
+	code, ok = SmsCode(&Message{Text: &MessageContent{Body: "4829 is your login"}})
+	assert.True(t, ok)
+	assert.Equal(t, "4829", code)
+
+	_, ok = SmsCode(&Message{Text: &MessageContent{Body: "No code here"}})
+	assert.False(t, ok)
+
+	_, ok = SmsCode(&Message{})
+	assert.False(t, ok)
+}
@@ -0,0 +1,104 @@
+package mailosaur
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	SyncCreate = "create"
+	SyncUpdate = "update"
+	SyncDelete = "delete"
+	SyncKeep   = "keep"
+)
+
+// SyncAction is the part of a sync plan action that is the same for every
+// kind of resource.
+type SyncAction struct {
+	Action  string
+	Name    string
+	Applied bool
+}
+
+func (a *SyncAction) syncAction() *SyncAction {
+	return a
+}
+
+// describe formats the action for a plan, with the ID of the existing
+// resource and, for updates, what will change.
+func (a *SyncAction) describe(id string, change string) string {
+	switch a.Action {
+	case SyncCreate:
+		return "+ create " + a.Name
+	case SyncUpdate:
+		return "~ update " + a.Name + " (" + id + "): " + change
+	case SyncDelete:
+		return "- delete " + a.Name + " (" + id + ")"
+	default:
+		return "  keep   " + a.Name + " (" + id + ")"
+	}
+}
+
+type syncStep interface {
+	fmt.Stringer
+	syncAction() *SyncAction
+}
+
+func syncHasChanges(steps []syncStep) bool {
+	for _, s := range steps {
+		if s.syncAction().Action != SyncKeep {
+			return true
+		}
+	}
+	return false
+}
+
+// syncPlanString lists the actions followed by a count of each kind. The
+// update count is left out for resources that are never updated.
+func syncPlanString(steps []syncStep, updates bool) string {
+	var sb strings.Builder
+	counts := map[string]int{}
+	for _, s := range steps {
+		sb.WriteString(s.String())
+		sb.WriteString("\n")
+		counts[s.syncAction().Action]++
+	}
+
+	if updates {
+		fmt.Fprintf(&sb, "%d to create, %d to update, %d to delete, %d unchanged", counts[SyncCreate], counts[SyncUpdate], counts[SyncDelete], counts[SyncKeep])
+	} else {
+		fmt.Fprintf(&sb, "%d to create, %d to delete, %d unchanged", counts[SyncCreate], counts[SyncDelete], counts[SyncKeep])
+	}
+	return sb.String()
+}
+
+// checkSyncNames returns the set of desired names, which must be present
+// and unique.
+func checkSyncNames(kind string, names []string) (map[string]bool, error) {
+	seen := map[string]bool{}
+	for _, name := range names {
+		if len(name) == 0 {
+			return nil, &mailosaurError{Message: "Every desired " + kind + " must have a name.", ErrorType: "invalid_request"}
+		}
+		if seen[name] {
+			return nil, &mailosaurError{Message: "The " + kind + " [" + name + "] is listed more than once.", ErrorType: "invalid_request"}
+		}
+		seen[name] = true
+	}
+	return seen, nil
+}
+
+// matchSyncNames matches the first existing resource with each desired
+// name, returning its index by name. Any others are surplus.
+func matchSyncNames(desired map[string]bool, existing []string) (map[string]int, []int) {
+	byName := map[string]int{}
+	var surplus []int
+	for i, name := range existing {
+		if _, ok := byName[name]; ok || !desired[name] {
+			surplus = append(surplus, i)
+			continue
+		}
+		byName[name] = i
+	}
+	return byName, surplus
+}
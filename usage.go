@@ -39,3 +39,21 @@ func (s *UsageService) Transactions() (*UsageTransactionListResult, error) {
 	result, err := s.client.HttpGet(&UsageTransactionListResult{}, "api/usage/transactions")
 	return result.(*UsageTransactionListResult), err
 }
+
+// TransactionsBetween returns the transactions with a timestamp on or after
+// from and before to. A zero from or to leaves that end of the range open.
+func (s *UsageService) TransactionsBetween(from time.Time, to time.Time) (*UsageTransactionListResult, error) {
+	result, err := s.Transactions()
+	if err != nil {
+		return result, err
+	}
+
+	filtered := &UsageTransactionListResult{Items: []*UsageTransaction{}}
+	for _, t := range result.Items {
+		if (from.IsZero() || !t.Timestamp.Before(from)) && (to.IsZero() || t.Timestamp.Before(to)) {
+			filtered.Items = append(filtered.Items, t)
+		}
+	}
+
+	return filtered, nil
+}
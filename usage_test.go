@@ -3,6 +3,7 @@ package mailosaur
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -19,6 +20,7 @@ func init() {
 }
 
 func TestLimits(t *testing.T) {
+	skipUnlessLive(t)
 	result, err := client.Usage.Limits()
 	assert.NoError(t, err)
 
@@ -29,8 +31,23 @@ func TestLimits(t *testing.T) {
 }
 
 func TestTransactions(t *testing.T) {
+	skipUnlessLive(t)
 	result, err := client.Usage.Transactions()
 	assert.NoError(t, err)
 
 	assert.True(t, len(result.Items) > 1)
 }
+
+func TestTransactionsBetween(t *testing.T) {
+	skipUnlessLive(t)
+	all, err := client.Usage.Transactions()
+	assert.NoError(t, err)
+
+	from := all.Items[len(all.Items)-1].Timestamp
+	result, err := client.Usage.TransactionsBetween(from, time.Time{})
+	assert.NoError(t, err)
+
+	for _, item := range result.Items {
+		assert.False(t, item.Timestamp.Before(from))
+	}
+}